@@ -0,0 +1,123 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// defaultAttachmentMaxSize is the size, in bytes, above which files are not
+// mirrored when no `max-size` is configured. It is JIRA's default limit.
+const defaultAttachmentMaxSize = 10 << 20
+
+// attachmentConfig is the value of the `attachments` configuration parameter.
+type attachmentConfig struct {
+	// Sync is true if images and files uploaded to GitHub issues and comments
+	// should be mirrored as JIRA attachments.
+	Sync bool `json:"sync,omitempty" mapstructure:"sync"`
+	// MaxSize is the size, in bytes, above which files are not mirrored.
+	MaxSize int64 `json:"max-size,omitempty" mapstructure:"max-size"`
+	// RecordFile is the path to the file the record of mirrored files is saved
+	// to between runs.
+	RecordFile string `json:"record-file,omitempty" mapstructure:"record-file"`
+}
+
+// attachmentRecord records which files have been mirrored to which JIRA
+// issues, to avoid uploading them again. It is shared by every copy of the
+// configuration.
+type attachmentRecord struct {
+	lock sync.Mutex
+	// issues maps the key of each JIRA issue to a map from the URL of each
+	// file mirrored to it to the name of the attachment. The name is empty
+	// if the file was too large to be mirrored.
+	issues map[string]map[string]string
+}
+
+// IsAttachmentSyncEnabled returns whether files uploaded to GitHub issues and
+// comments are mirrored as JIRA attachments.
+func (c Config) IsAttachmentSyncEnabled() bool {
+	return c.attachments.Sync
+}
+
+// GetAttachmentMaxSize returns the size, in bytes, above which files are not mirrored.
+func (c Config) GetAttachmentMaxSize() int64 {
+	if c.attachments.MaxSize == 0 {
+		return defaultAttachmentMaxSize
+	}
+	return c.attachments.MaxSize
+}
+
+// GetMirroredAttachment returns the name of the attachment of a JIRA issue the
+// file at url was mirrored as, and whether the file was already handled. The
+// name is empty if the file was too large to be mirrored.
+func (c Config) GetMirroredAttachment(key, url string) (string, bool) {
+	c.attachmentRecord.lock.Lock()
+	defer c.attachmentRecord.lock.Unlock()
+
+	name, ok := c.attachmentRecord.issues[key][url]
+	return name, ok
+}
+
+// SetMirroredAttachment records that the file at url was mirrored to a JIRA
+// issue as the attachment with the given name.
+func (c Config) SetMirroredAttachment(key, url, name string) {
+	c.attachmentRecord.lock.Lock()
+	defer c.attachmentRecord.lock.Unlock()
+
+	if c.attachmentRecord.issues[key] == nil {
+		c.attachmentRecord.issues[key] = map[string]string{}
+	}
+	c.attachmentRecord.issues[key][url] = name
+}
+
+// SaveAttachmentRecord saves the record of mirrored files to the configured
+// record file, if there is one.
+func (c Config) SaveAttachmentRecord() error {
+	if c.attachments.RecordFile == "" {
+		return nil
+	}
+
+	c.attachmentRecord.lock.Lock()
+	b, err := json.MarshalIndent(c.attachmentRecord.issues, "", "  ")
+	c.attachmentRecord.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomically(c.attachments.RecordFile, b)
+}
+
+// validateAttachments checks the values of the `attachments` configuration
+// parameter, and loads the record file.
+func (c *Config) validateAttachments() error {
+	if err := c.cmdConfig.UnmarshalKey("attachments", &c.attachments); err != nil {
+		return fmt.Errorf("Attachments must be an object: %v", err)
+	}
+
+	if c.attachments.MaxSize < 0 {
+		return fmt.Errorf("Attachment max size must be positive; got %d", c.attachments.MaxSize)
+	}
+
+	c.attachmentRecord = &attachmentRecord{
+		issues: map[string]map[string]string{},
+	}
+
+	if c.attachments.RecordFile != "" {
+		b, err := ioutil.ReadFile(c.attachments.RecordFile)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Error reading attachment record file: %v", err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(b, &c.attachmentRecord.issues); err != nil {
+				c.log.Warnf("Ignoring invalid attachment record file %s: %v", c.attachments.RecordFile, err)
+			}
+			if c.attachmentRecord.issues == nil {
+				c.attachmentRecord.issues = map[string]map[string]string{}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Modes of the `mode` key of the `back-reference` configuration parameter.
+const (
+	// BackReferenceComment writes the back-reference as a comment.
+	BackReferenceComment = "comment"
+	// BackReferenceLabel writes the back-reference as a label.
+	BackReferenceLabel = "label"
+	// BackReferenceBody appends the back-reference to the body of the issue.
+	BackReferenceBody = "body"
+)
+
+// defaultBackReferenceTemplates are the templates used for each mode when no
+// `template` is configured.
+var defaultBackReferenceTemplates = map[string]string{
+	BackReferenceComment: "This issue is tracked in JIRA as [{{.Key}}]({{.URL}}).",
+	BackReferenceLabel:   "jira:{{.Key}}",
+	BackReferenceBody:    "---\nTracked in JIRA as [{{.Key}}]({{.URL}}).",
+}
+
+// backReferenceConfig is the value of the `back-reference` configuration parameter.
+type backReferenceConfig struct {
+	// Mode is how the back-reference is written to GitHub: BackReferenceComment,
+	// BackReferenceLabel, BackReferenceBody, or empty if it isn't.
+	Mode string `json:"mode,omitempty" mapstructure:"mode"`
+	// Template is the text/template the back-reference is generated from.
+	Template string `json:"template,omitempty" mapstructure:"template"`
+}
+
+// BackReference is the data the back-reference template is executed with.
+type BackReference struct {
+	// Key is the key of the JIRA issue, e.g. "SYNC-12".
+	Key string
+	// URL is the URL of the JIRA issue.
+	URL string
+}
+
+// IsBackReferenceEnabled returns whether the key of the JIRA issue mirroring
+// a GitHub issue is written back to GitHub.
+func (c Config) IsBackReferenceEnabled() bool {
+	return c.backReference.Mode != ""
+}
+
+// GetBackReferenceMode returns how the back-reference is written to GitHub.
+func (c Config) GetBackReferenceMode() string {
+	return c.backReference.Mode
+}
+
+// GetBackReference returns the back-reference to the JIRA issue with the
+// given key, generated from the configured template.
+func (c Config) GetBackReference(key string) (string, error) {
+	ref := BackReference{
+		Key: key,
+		URL: fmt.Sprintf("%s/browse/%s", strings.TrimSuffix(c.GetConfigString("jira-uri"), "/"), key),
+	}
+
+	var b bytes.Buffer
+	if err := c.backReferenceTemplate.Execute(&b, ref); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// validateBackReference checks the values of the `back-reference`
+// configuration parameter, and parses the template.
+func (c *Config) validateBackReference() error {
+	if err := c.cmdConfig.UnmarshalKey("back-reference", &c.backReference); err != nil {
+		return fmt.Errorf("Back-reference must be an object: %v", err)
+	}
+
+	text := c.backReference.Template
+	switch c.backReference.Mode {
+	case "":
+		return nil
+	case BackReferenceComment, BackReferenceLabel, BackReferenceBody:
+		if text == "" {
+			text = defaultBackReferenceTemplates[c.backReference.Mode]
+		}
+	default:
+		return fmt.Errorf("Back-reference mode must be %s, %s or %s; got %q",
+			BackReferenceComment, BackReferenceLabel, BackReferenceBody, c.backReference.Mode)
+	}
+
+	t, err := template.New("back-reference").Parse(text)
+	if err != nil {
+		return fmt.Errorf("Invalid back-reference template: %v", err)
+	}
+	c.backReferenceTemplate = t
+
+	// Catch references to fields which don't exist early.
+	ref, err := c.GetBackReference("SYNC-1")
+	if err != nil {
+		return fmt.Errorf("Invalid back-reference template: %v", err)
+	}
+	if ref == "" {
+		return fmt.Errorf("Back-reference template must not be empty")
+	}
+	if c.backReference.Mode == BackReferenceLabel && (len(ref) > 50 || strings.Contains(ref, ",")) {
+		return fmt.Errorf("Back-reference label must be at most 50 characters without commas; got %q", ref)
+	}
+
+	return nil
+}
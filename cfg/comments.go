@@ -0,0 +1,64 @@
+package cfg
+
+import "fmt"
+
+// Actions of the `deleted-comments` configuration parameter.
+const (
+	// DeletedCommentDelete deletes the JIRA comment.
+	DeletedCommentDelete = "delete"
+	// DeletedCommentRedact replaces the body of the JIRA comment with a marker.
+	DeletedCommentRedact = "redact"
+	// DeletedCommentMark appends a marker to the body of the JIRA comment.
+	DeletedCommentMark = "mark"
+)
+
+// deletedCommentConfig is the value of the `deleted-comments` configuration
+// parameter.
+type deletedCommentConfig struct {
+	// Action is what is done to the JIRA comments mirroring GitHub comments
+	// which were deleted: DeletedCommentDelete, DeletedCommentRedact,
+	// DeletedCommentMark, or empty if they are left as they are.
+	Action string `json:"action,omitempty" mapstructure:"action"`
+	// Hidden is true if comments which were hidden (minimized) on GitHub,
+	// e.g. as spam, should be treated as deleted.
+	Hidden bool `json:"hidden,omitempty" mapstructure:"hidden"`
+}
+
+// IsDeletedCommentSyncEnabled returns whether the JIRA comments mirroring
+// deleted GitHub comments are reconciled.
+func (c Config) IsDeletedCommentSyncEnabled() bool {
+	return c.deletedComments.Action != ""
+}
+
+// GetDeletedCommentAction returns what is done to the JIRA comments mirroring
+// deleted GitHub comments.
+func (c Config) GetDeletedCommentAction() string {
+	return c.deletedComments.Action
+}
+
+// IsHiddenCommentSyncEnabled returns whether GitHub comments which were hidden
+// are treated as deleted.
+func (c Config) IsHiddenCommentSyncEnabled() bool {
+	return c.deletedComments.Action != "" && c.deletedComments.Hidden
+}
+
+// validateDeletedComments checks the values of the `deleted-comments`
+// configuration parameter.
+func (c *Config) validateDeletedComments() error {
+	if err := c.cmdConfig.UnmarshalKey("deleted-comments", &c.deletedComments); err != nil {
+		return fmt.Errorf("Deleted comments must be an object: %v", err)
+	}
+
+	switch c.deletedComments.Action {
+	case "", DeletedCommentDelete, DeletedCommentRedact, DeletedCommentMark:
+	default:
+		return fmt.Errorf("Deleted comment action must be %s, %s or %s; got %q",
+			DeletedCommentDelete, DeletedCommentRedact, DeletedCommentMark, c.deletedComments.Action)
+	}
+
+	if c.deletedComments.Hidden && c.deletedComments.Action == "" {
+		return fmt.Errorf("Deleted comments must have an action to handle hidden comments")
+	}
+
+	return nil
+}
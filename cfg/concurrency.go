@@ -0,0 +1,75 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// defaultWorkers is the number of GitHub issues synchronized in parallel when
+// no `workers` is configured.
+const defaultWorkers = 1
+
+// concurrencyConfig is the value of the `concurrency` configuration parameter.
+type concurrencyConfig struct {
+	// Workers is the number of GitHub issues synchronized in parallel.
+	Workers int `json:"workers,omitempty" mapstructure:"workers"`
+	// GitHub and JIRA limit the requests made to each service.
+	GitHub ServiceLimits `json:"github,omitempty" mapstructure:"github"`
+	JIRA   ServiceLimits `json:"jira,omitempty" mapstructure:"jira"`
+}
+
+// ServiceLimits is the value of the `github` and `jira` keys of the
+// `concurrency` configuration parameter: how many requests can be made to
+// the service at once, and how fast. A zero value means no limit.
+type ServiceLimits struct {
+	// MaxRequests is the maximum number of requests in flight at once.
+	MaxRequests int `json:"max-requests,omitempty" mapstructure:"max-requests"`
+	// Rate is the number of requests per second, on average.
+	Rate float64 `json:"rate,omitempty" mapstructure:"rate"`
+	// Burst is the number of requests which can be made at once above Rate
+	// after a pause; it defaults to one second's worth of requests.
+	Burst int `json:"burst,omitempty" mapstructure:"burst"`
+}
+
+// GetWorkers returns the number of GitHub issues synchronized in parallel.
+func (c Config) GetWorkers() int {
+	if c.concurrency.Workers == 0 {
+		return defaultWorkers
+	}
+	return c.concurrency.Workers
+}
+
+// GetGitHubLimits returns the limits of the requests made to GitHub.
+func (c Config) GetGitHubLimits() ServiceLimits {
+	return c.concurrency.GitHub
+}
+
+// GetJIRALimits returns the limits of the requests made to JIRA.
+func (c Config) GetJIRALimits() ServiceLimits {
+	return c.concurrency.JIRA
+}
+
+// validateConcurrency checks the values of the `concurrency` configuration parameter.
+func (c *Config) validateConcurrency() error {
+	if err := c.cmdConfig.UnmarshalKey("concurrency", &c.concurrency); err != nil {
+		return fmt.Errorf("Concurrency must be an object: %v", err)
+	}
+
+	if c.concurrency.Workers < 0 {
+		return fmt.Errorf("Concurrency workers must be positive; got %d", c.concurrency.Workers)
+	}
+
+	if !c.concurrency.GitHub.valid() {
+		return errors.New("Concurrency limits of GitHub must not be negative")
+	}
+	if !c.concurrency.JIRA.valid() {
+		return errors.New("Concurrency limits of JIRA must not be negative")
+	}
+
+	return nil
+}
+
+// valid returns whether none of the limits is negative.
+func (l ServiceLimits) valid() bool {
+	return l.MaxRequests >= 0 && l.Rate >= 0 && l.Burst >= 0
+}
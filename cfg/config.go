@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
+	"path"
 	"strings"
 	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -20,6 +23,11 @@ import (
 	"golang.org/x/crypto/ssh/terminal"
 )
 
+// defaultSinceOverlap is how far before the latest update of the GitHub issues
+// synchronized successfully the `since` parameter is set when no
+// `since-overlap` is configured.
+const defaultSinceOverlap = 5 * time.Minute
+
 // dateFormat is the format used for the `since` configuration parameter
 const dateFormat = "2006-01-02T15:04:05-0700"
 
@@ -64,12 +72,152 @@ type Config struct {
 	// fieldIDs is the list of custom fields we pulled from the `fields` JIRA endpoint.
 	fieldIDs fields
 
-	// project represents the JIRA project the user has requested.
-	project jira.Project
+	// repos is the list of GitHub repositories to synchronize, taken either from the
+	// `repos` configuration parameter or from `repo-name` and `jira-project`.
+	repos []repository
+
+	// repo is the index in repos of the repository this configuration is scoped to (see Repos).
+	repo int
+
+	// orgs is the list of GitHub organizations and users whose repositories are
+	// discovered and added to repos on every run (see SetDiscoveredRepos).
+	orgs []Org
+
+	// projects maps the key of each configured JIRA project to the project itself.
+	projects map[string]jira.Project
+
+	// transitions is the list of rules mapping GitHub issue states to JIRA statuses.
+	transitions []TransitionRule
+
+	// issueTypes is the configuration of how the JIRA issue type is selected.
+	issueTypes issueTypeConfig
+
+	// labels is the configuration of how GitHub labels are mapped to JIRA
+	// labels and components.
+	labels labelConfig
+
+	// milestones is the configuration of how GitHub milestones are mirrored
+	// as JIRA fix versions.
+	milestones milestoneConfig
+
+	// milestoneNames is the list of names of the milestones of the repository
+	// the configuration is scoped to; see SetMilestoneNames.
+	milestoneNames []string
+
+	// users is the configuration of how GitHub users are mapped to JIRA users.
+	users userConfig
+
+	// userDirectory holds the user mapping and the results of looking up users
+	// in JIRA; it is shared by every copy of the configuration.
+	userDirectory *userDirectory
+
+	// priorities is the configuration of how the JIRA priority is selected.
+	priorities priorityConfig
+
+	// priorityOrder is the list of names of the JIRA priorities, from the
+	// highest to the lowest.
+	priorityOrder []string
+
+	// attachments is the configuration of how files uploaded to GitHub are
+	// mirrored as JIRA attachments.
+	attachments attachmentConfig
+
+	// attachmentRecord records the files which have been mirrored; it is
+	// shared by every copy of the configuration.
+	attachmentRecord *attachmentRecord
+
+	// pullRequests is the configuration of how pull requests are mirrored.
+	pullRequests pullRequestConfig
+
+	// backReference is the configuration of how the keys of JIRA issues are
+	// written back to GitHub, and backReferenceTemplate its parsed template.
+	backReference         backReferenceConfig
+	backReferenceTemplate *template.Template
+
+	// deletedComments is the configuration of how the JIRA comments mirroring
+	// deleted GitHub comments are handled.
+	deletedComments deletedCommentConfig
+
+	// orphans is the configuration of how JIRA issues whose GitHub issue is
+	// gone are reconciled, and orphanChecks records when they last were; it
+	// is shared by every copy of the configuration.
+	orphans      orphanConfig
+	orphanChecks *orphanChecks
+
+	// timeline is the configuration of which GitHub timeline events are
+	// mirrored as JIRA comments.
+	timeline timelineConfig
+
+	// taskLists is the configuration of how the task lists of GitHub issues
+	// are mirrored as JIRA sub-tasks.
+	taskLists taskListConfig
+
+	// references is the configuration of how references between GitHub
+	// issues are mirrored as JIRA issue links, and issueLinks records the
+	// links created during the current run; it is shared by every copy of
+	// the configuration.
+	references referenceConfig
+	issueLinks *issueLinks
+
+	// state is the configuration of where the state is kept, and stateStore
+	// is the state; it is shared by every copy of the configuration.
+	state      stateConfig
+	stateStore *stateStore
+
+	// concurrency is the configuration of how many GitHub issues are
+	// synchronized in parallel, and how requests to GitHub and JIRA are limited.
+	concurrency concurrencyConfig
+}
+
+// Org is a single entry of the `orgs` configuration parameter: a GitHub
+// organization or user whose repositories should all be synchronized,
+// subject to a set of filters.
+type Org struct {
+	// Owner is the login of the organization or user.
+	Owner string `json:"owner" mapstructure:"owner"`
+	// User is true if Owner is a user rather than an organization.
+	User bool `json:"user,omitempty" mapstructure:"user"`
+	// JIRAProject is the key of the JIRA project the discovered repositories are
+	// mirrored to; it defaults to the top-level `jira-project`.
+	JIRAProject string `json:"jira-project,omitempty" mapstructure:"jira-project"`
+	// Since is the `since` parameter newly discovered repositories start from; it
+	// defaults to the top-level `since`.
+	Since string `json:"since,omitempty" mapstructure:"since"`
+	// Include is a list of glob patterns; if set, a repository name must match one of them.
+	Include []string `json:"include,omitempty" mapstructure:"include"`
+	// Exclude is a list of glob patterns; a repository name must not match any of them.
+	Exclude []string `json:"exclude,omitempty" mapstructure:"exclude"`
+	// Topics is a list of topics; if set, a repository must have at least one of them.
+	Topics []string `json:"topics,omitempty" mapstructure:"topics"`
+	// Visibility is one of "all" (the default), "public" or "private".
+	Visibility string `json:"visibility,omitempty" mapstructure:"visibility"`
+	// Archived is true if archived repositories should be synchronized as well.
+	Archived bool `json:"archived,omitempty" mapstructure:"archived"`
+}
 
-	// since is the parsed value of the `since` configuration parameter, which is the earliest that
-	// a GitHub issue can have been updated to be retrieved.
+// repository is a single GitHub repository to synchronize, along with the JIRA
+// project its issues are mirrored to.
+type repository struct {
+	owner      string
+	name       string
+	projectKey string
+
+	// org is the owner of the entry in orgs this repository was discovered from, or
+	// the empty string if it was configured explicitly.
+	org string
+
+	// since is the parsed value of the `since` configuration parameter for this repository,
+	// which is the earliest that a GitHub issue can have been updated to be retrieved.
 	since time.Time
+
+	// retry holds the numbers of the GitHub issues which failed to be synchronized,
+	// and are retrieved again on the next run regardless of since.
+	retry []int
+}
+
+// fullName returns the name of the repository in the form owner/repo.
+func (r repository) fullName() string {
+	return fmt.Sprintf("%s/%s", r.owner, r.name)
 }
 
 // NewConfig creates a new, immutable configuration object. This object
@@ -98,32 +246,77 @@ func NewConfig(cmd *cobra.Command) (Config, error) {
 	return config, nil
 }
 
-// LoadJIRAConfig loads the JIRA configuration (project key,
-// custom field IDs) from a remote JIRA server.
+// LoadJIRAConfig loads the JIRA configuration (the project of each
+// configured repository, custom field IDs) from a remote JIRA server.
 func (c *Config) LoadJIRAConfig(client jira.Client) error {
-	proj, res, err := client.Project.Get(c.cmdConfig.GetString("jira-project"))
-	if err != nil {
-		c.log.Errorf("Error retrieving JIRA project; check key and credentials. Error: %v", err)
-		defer res.Body.Close()
-		body, err := ioutil.ReadAll(res.Body)
+	var keys []string
+	for _, repo := range c.repos {
+		keys = append(keys, repo.projectKey)
+	}
+	for _, org := range c.orgs {
+		keys = append(keys, org.JIRAProject)
+	}
+
+	for _, key := range keys {
+		if _, ok := c.projects[key]; ok {
+			continue
+		}
+		proj, res, err := client.Project.Get(key)
 		if err != nil {
-			c.log.Errorf("Error occured trying to read error body: %v", err)
-			return err
+			c.log.Errorf("Error retrieving JIRA project %s; check key and credentials. Error: %v", key, err)
+			return getErrorBody(*c, res)
 		}
-
-		c.log.Debugf("Error body: %s", body)
-		return errors.New(string(body))
+		c.projects[key] = *proj
 	}
-	c.project = *proj
 
+	var err error
 	c.fieldIDs, err = c.getFieldIDs(client)
 	if err != nil {
 		return err
 	}
 
+	if err := c.checkTransitions(client); err != nil {
+		return err
+	}
+
+	if err := c.checkIssueTypes(client); err != nil {
+		return err
+	}
+
+	if err := c.checkLabels(); err != nil {
+		return err
+	}
+
+	if err := c.checkUsers(client); err != nil {
+		return err
+	}
+
+	if err := c.checkPriorities(client); err != nil {
+		return err
+	}
+
+	if err := c.checkLinkTypes(client); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// getErrorBody reads the HTTP response body of a JIRA API response, and
+// returns an error object with the contents of the body. If an error occurs
+// during reading, that error is instead printed and returned.
+func getErrorBody(c Config, res *jira.Response) error {
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		c.log.Errorf("Error occured trying to read error body: %v", err)
+		return err
+	}
+
+	c.log.Debugf("Error body: %s", body)
+	return errors.New(string(body))
+}
+
 // GetConfigFile returns the file that Viper loaded the configuration from.
 func (c Config) GetConfigFile() string {
 	return c.cmdFile
@@ -140,9 +333,75 @@ func (c Config) IsBasicAuth() bool {
 	return c.basicAuth
 }
 
-// GetSinceParam returns the `since` configuration parameter, parsed as a time.Time.
+// GetSinceParam returns the `since` configuration parameter of the repository
+// this configuration is scoped to, parsed as a time.Time.
 func (c Config) GetSinceParam() time.Time {
-	return c.since
+	return c.repos[c.repo].since
+}
+
+// SetSinceParam updates the `since` configuration parameter of the repository
+// this configuration is scoped to. The new value is shared by every copy of
+// the configuration, and is written to the configuration file by SaveConfig.
+func (c Config) SetSinceParam(since time.Time) {
+	c.repos[c.repo].since = since
+}
+
+// GetSinceOverlap returns how far before the latest update of the GitHub
+// issues synchronized successfully the `since` parameter is set, to allow for
+// clock skew.
+func (c Config) GetSinceOverlap() time.Duration {
+	if !c.cmdConfig.IsSet("since-overlap") {
+		return defaultSinceOverlap
+	}
+	return c.cmdConfig.GetDuration("since-overlap")
+}
+
+// GetRetryIssues returns the numbers of the GitHub issues of the repository
+// this configuration is scoped to which failed to be synchronized.
+func (c Config) GetRetryIssues() []int {
+	return c.repos[c.repo].retry
+}
+
+// SetRetryIssues records the numbers of the GitHub issues of the repository
+// this configuration is scoped to which failed to be synchronized, so that
+// they are retried. Like `since`, they are written to the configuration file
+// by SaveConfig.
+func (c Config) SetRetryIssues(numbers []int) {
+	c.repos[c.repo].retry = numbers
+}
+
+// Repos returns one copy of the configuration for each configured GitHub
+// repository, scoped so that GetRepo, GetProject, GetProjectKey and
+// GetSinceParam refer to that repository. The logger of each copy is
+// tagged with the name of its repository.
+func (c Config) Repos() []Config {
+	configs := make([]Config, len(c.repos))
+	for i, repo := range c.repos {
+		configs[i] = c
+		configs[i].repo = i
+		configs[i].log = *c.log.WithField("repo", repo.fullName())
+	}
+	return configs
+}
+
+// WithLogOutput returns a copy of the configuration whose logger writes to out
+// rather than to the configured output, with the same level, format and fields.
+func (c Config) WithLogOutput(out io.Writer) Config {
+	logger := logrus.New()
+	logger.Out = out
+	logger.Hooks = c.log.Logger.Hooks
+	logger.Formatter = c.log.Logger.Formatter
+	logger.Level = c.log.Logger.Level
+	c.log = *logrus.NewEntry(logger).WithFields(c.log.Data)
+	return c
+}
+
+// WriteLog writes b, the output of the logger of a copy of the configuration
+// made by WithLogOutput, to the configured output.
+func (c Config) WriteLog(b []byte) {
+	if _, err := c.log.Logger.Out.Write(b); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write to log, %v\n", err)
+	}
 }
 
 // GetLogger returns the configured application logger.
@@ -195,22 +454,77 @@ func (c Config) GetFieldKey(key fieldKey) string {
 	return fmt.Sprintf("customfield_%s", c.GetFieldID(key))
 }
 
-// GetProject returns the JIRA project the user has configured.
+// GetOrgs returns the configured GitHub organizations and users whose
+// repositories are discovered on every run.
+func (c Config) GetOrgs() []Org {
+	return c.orgs
+}
+
+// SetDiscoveredRepos replaces the repositories previously discovered from org
+// with the repositories named in names, which are of the form owner/repo.
+// Repositories which were already discovered keep their `since` parameter;
+// repositories which are configured explicitly are left untouched.
+func (c *Config) SetDiscoveredRepos(org Org, names []string) {
+	previous := map[string]repository{}
+	var repos []repository
+	for _, repo := range c.repos {
+		if strings.EqualFold(repo.org, org.Owner) {
+			previous[strings.ToLower(repo.fullName())] = repo
+		} else {
+			repos = append(repos, repo)
+		}
+	}
+
+	// Org entries are validated in validateConfig, so this is safe
+	since, _ := time.Parse(dateFormat, org.Since)
+
+	for _, name := range names {
+		duplicate := false
+		for _, repo := range repos {
+			if strings.EqualFold(repo.fullName(), name) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		if repo, ok := previous[strings.ToLower(name)]; ok {
+			repos = append(repos, repo)
+			continue
+		}
+
+		c.log.Infof("Discovered new repository %s from %s", name, org.Owner)
+		parts := strings.Split(name, "/")
+		repos = append(repos, repository{
+			owner:      parts[0],
+			name:       parts[1],
+			projectKey: org.JIRAProject,
+			org:        org.Owner,
+			since:      since,
+		})
+	}
+
+	c.repos = repos
+}
+
+// GetProject returns the JIRA project of the repository this configuration is scoped to.
 func (c Config) GetProject() jira.Project {
-	return c.project
+	return c.projects[c.GetProjectKey()]
 }
 
-// GetProjectKey returns the JIRA key of the configured project.
+// GetProjectKey returns the JIRA key of the project of the repository this
+// configuration is scoped to.
 func (c Config) GetProjectKey() string {
-	return c.project.Key
+	return c.repos[c.repo].projectKey
 }
 
-// GetRepo returns the user/org name and the repo name of the configured GitHub repository.
+// GetRepo returns the user/org name and the repo name of the GitHub repository
+// this configuration is scoped to.
 func (c Config) GetRepo() (string, string) {
-	fullName := c.cmdConfig.GetString("repo-name")
-	parts := strings.Split(fullName, "/")
-	// We check that repo-name is two parts separated by a slash in NewConfig, so this is safe
-	return parts[0], parts[1]
+	repo := c.repos[c.repo]
+	return repo.owner, repo.name
 }
 
 // SetJIRAToken adds the JIRA OAuth tokens in the Viper configuration, ensuring that they
@@ -222,27 +536,80 @@ func (c Config) SetJIRAToken(token *oauth1.Token) {
 
 // configFile is a serializable representation of the current Viper configuration.
 type configFile struct {
-	LogLevel    string        `json:"log-level" mapstructure:"log-level"`
-	GithubToken string        `json:"github-token" mapstructure:"github-token"`
-	JIRAUser    string        `json:"jira-user" mapstructure:"jira-user"`
-	JIRAToken   string        `json:"jira-token" mapstructure:"jira-token"`
-	JIRASecret  string        `json:"jira-secret" mapstructure:"jira-secret"`
-	JIRAKey     string        `json:"jira-private-key-path" mapstructure:"jira-private-key-path"`
-	JIRACKey    string        `json:"jira-consumer-key" mapstructure:"jira-consumer-key"`
-	RepoName    string        `json:"repo-name" mapstructure:"repo-name"`
-	JIRAURI     string        `json:"jira-uri" mapstructure:"jira-uri"`
-	JIRAProject string        `json:"jira-project" mapstructure:"jira-project"`
-	Since       string        `json:"since" mapstructure:"since"`
-	Timeout     time.Duration `json:"timeout" mapstructure:"timeout"`
-}
-
-// SaveConfig updates the `since` parameter to now, then saves the configuration file.
+	LogLevel        string                `json:"log-level" mapstructure:"log-level"`
+	GithubToken     string                `json:"github-token" mapstructure:"github-token"`
+	JIRAUser        string                `json:"jira-user" mapstructure:"jira-user"`
+	JIRAToken       string                `json:"jira-token" mapstructure:"jira-token"`
+	JIRASecret      string                `json:"jira-secret" mapstructure:"jira-secret"`
+	JIRAKey         string                `json:"jira-private-key-path" mapstructure:"jira-private-key-path"`
+	JIRACKey        string                `json:"jira-consumer-key" mapstructure:"jira-consumer-key"`
+	RepoName        string                `json:"repo-name" mapstructure:"repo-name"`
+	JIRAURI         string                `json:"jira-uri" mapstructure:"jira-uri"`
+	JIRAProject     string                `json:"jira-project" mapstructure:"jira-project"`
+	Since           string                `json:"since" mapstructure:"since"`
+	SinceOverlap    time.Duration         `json:"since-overlap,omitempty" mapstructure:"since-overlap"`
+	Retry           []int                 `json:"retry,omitempty" mapstructure:"retry"`
+	Repos           []repoFile            `json:"repos,omitempty" mapstructure:"repos"`
+	Orgs            []Org                 `json:"orgs,omitempty" mapstructure:"orgs"`
+	Transitions     []TransitionRule      `json:"transitions,omitempty" mapstructure:"transitions"`
+	IssueTypes      *issueTypeConfig      `json:"issue-types,omitempty" mapstructure:"issue-types"`
+	Labels          *labelConfig          `json:"labels,omitempty" mapstructure:"labels"`
+	Milestones      *milestoneConfig      `json:"milestones,omitempty" mapstructure:"milestones"`
+	Users           *userConfig           `json:"users,omitempty" mapstructure:"users"`
+	Priorities      *priorityConfig       `json:"priorities,omitempty" mapstructure:"priorities"`
+	Attachments     *attachmentConfig     `json:"attachments,omitempty" mapstructure:"attachments"`
+	PullRequests    *pullRequestConfig    `json:"pull-requests,omitempty" mapstructure:"pull-requests"`
+	BackReference   *backReferenceConfig  `json:"back-reference,omitempty" mapstructure:"back-reference"`
+	DeletedComments *deletedCommentConfig `json:"deleted-comments,omitempty" mapstructure:"deleted-comments"`
+	Orphans         *orphanConfig         `json:"orphans,omitempty" mapstructure:"orphans"`
+	Timeline        *timelineConfig       `json:"timeline,omitempty" mapstructure:"timeline"`
+	TaskLists       *taskListConfig       `json:"task-lists,omitempty" mapstructure:"task-lists"`
+	References      *referenceConfig      `json:"references,omitempty" mapstructure:"references"`
+	State           *stateConfig          `json:"state,omitempty" mapstructure:"state"`
+	Concurrency     *concurrencyConfig    `json:"concurrency,omitempty" mapstructure:"concurrency"`
+	Timeout         time.Duration         `json:"timeout" mapstructure:"timeout"`
+}
+
+// repoFile is a serializable representation of a single entry of the `repos`
+// configuration parameter.
+type repoFile struct {
+	RepoName    string `json:"repo-name" mapstructure:"repo-name"`
+	JIRAProject string `json:"jira-project,omitempty" mapstructure:"jira-project"`
+	Since       string `json:"since,omitempty" mapstructure:"since"`
+	Retry       []int  `json:"retry,omitempty" mapstructure:"retry"`
+	// DiscoveredFrom is the owner of the entry of `orgs` the repository was
+	// discovered from, if any.
+	DiscoveredFrom string `json:"discovered-from,omitempty" mapstructure:"discovered-from"`
+}
+
+// SaveConfig saves the configuration file, with the `since` parameter of each
+// repository set to the value last given to SetSinceParam, and its `retry`
+// parameter to the value last given to SetRetryIssues.
 func (c *Config) SaveConfig() error {
-	c.cmdConfig.Set("since", time.Now().Format(dateFormat))
+	// A single repository configured with `repo-name` is saved as it was
+	// given; anything else is saved as a list in `repos`.
+	listRepos := c.cmdConfig.IsSet("repos") || len(c.orgs) > 0
+	if !listRepos {
+		c.cmdConfig.Set("since", c.repos[0].since.Format(dateFormat))
+		c.cmdConfig.Set("retry", c.repos[0].retry)
+	}
 
 	var cf configFile
 	c.cmdConfig.Unmarshal(&cf)
 
+	if listRepos {
+		cf.Repos = make([]repoFile, len(c.repos))
+		for i, repo := range c.repos {
+			cf.Repos[i] = repoFile{
+				RepoName:       repo.fullName(),
+				JIRAProject:    repo.projectKey,
+				Since:          repo.since.Format(dateFormat),
+				Retry:          repo.retry,
+				DiscoveredFrom: repo.org,
+			}
+		}
+	}
+
 	b, err := json.MarshalIndent(cf, "", "  ")
 	if err != nil {
 		return err
@@ -390,14 +757,6 @@ func (c *Config) validateConfig() error {
 		}
 	}
 
-	repo := c.cmdConfig.GetString("repo-name")
-	if repo == "" {
-		return errors.New("GitHub repository required")
-	}
-	if !strings.Contains(repo, "/") || len(strings.Split(repo, "/")) != 2 {
-		return errors.New("GitHub repository must be of form user/repo")
-	}
-
 	uri := c.cmdConfig.GetString("jira-uri")
 	if uri == "" {
 		return errors.New("JIRA URI required")
@@ -406,27 +765,206 @@ func (c *Config) validateConfig() error {
 		return errors.New("JIRA URI must be valid URI")
 	}
 
-	project := c.cmdConfig.GetString("jira-project")
-	if project == "" {
-		return errors.New("JIRA project required")
-	}
-
 	sinceStr := c.cmdConfig.GetString("since")
 	if sinceStr == "" {
-		c.cmdConfig.Set("since", "1970-01-01T00:00:00+0000")
+		sinceStr = "1970-01-01T00:00:00+0000"
+		c.cmdConfig.Set("since", sinceStr)
 	}
 
 	since, err := time.Parse(dateFormat, sinceStr)
 	if err != nil {
 		return errors.New("Since date must be in ISO-8601 format")
 	}
-	c.since = since
+
+	if c.GetSinceOverlap() < 0 {
+		return fmt.Errorf("Since overlap must not be negative; got %v", c.GetSinceOverlap())
+	}
+
+	project := c.cmdConfig.GetString("jira-project")
+
+	if err := c.cmdConfig.UnmarshalKey("orgs", &c.orgs); err != nil {
+		return fmt.Errorf("Orgs must be a list of organizations: %v", err)
+	}
+	for i, org := range c.orgs {
+		if err := validateOrg(&org, project, sinceStr); err != nil {
+			return err
+		}
+		c.orgs[i] = org
+	}
+
+	var repos []repoFile
+	if err := c.cmdConfig.UnmarshalKey("repos", &repos); err != nil {
+		return fmt.Errorf("Repos must be a list of repositories: %v", err)
+	}
+	if repoName := c.cmdConfig.GetString("repo-name"); len(repos) == 0 && repoName != "" {
+		if project == "" {
+			return errors.New("JIRA project required")
+		}
+		repos = []repoFile{{RepoName: repoName}}
+		if err := c.cmdConfig.UnmarshalKey("retry", &repos[0].Retry); err != nil {
+			return fmt.Errorf("Retry must be a list of issue numbers: %v", err)
+		}
+	}
+	if len(repos) == 0 && len(c.orgs) == 0 {
+		return errors.New("GitHub repository or organization required")
+	}
+
+	c.repos = nil
+	for _, repo := range repos {
+		if repo.DiscoveredFrom != "" && !c.hasOrg(repo.DiscoveredFrom) {
+			c.log.Debugf("Dropping repository %s; it was discovered from %s, which is no longer configured", repo.RepoName, repo.DiscoveredFrom)
+			continue
+		}
+
+		parts := strings.Split(repo.RepoName, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("GitHub repository %q must be of form user/repo", repo.RepoName)
+		}
+
+		projectKey := repo.JIRAProject
+		if projectKey == "" {
+			projectKey = project
+		}
+		if projectKey == "" {
+			return fmt.Errorf("JIRA project required for repository %s", repo.RepoName)
+		}
+
+		repoSince := since
+		if repo.Since != "" {
+			repoSince, err = time.Parse(dateFormat, repo.Since)
+			if err != nil {
+				return fmt.Errorf("Since date of repository %s must be in ISO-8601 format", repo.RepoName)
+			}
+		}
+
+		c.repos = append(c.repos, repository{
+			owner:      parts[0],
+			name:       parts[1],
+			projectKey: projectKey,
+			org:        repo.DiscoveredFrom,
+			since:      repoSince,
+			retry:      repo.Retry,
+		})
+	}
+	c.projects = map[string]jira.Project{}
+
+	if err := c.validateTransitions(); err != nil {
+		return err
+	}
+
+	if err := c.validateIssueTypes(); err != nil {
+		return err
+	}
+
+	if err := c.validateLabels(); err != nil {
+		return err
+	}
+
+	if err := c.validateMilestones(); err != nil {
+		return err
+	}
+
+	if err := c.validateUsers(); err != nil {
+		return err
+	}
+
+	if err := c.validatePriorities(); err != nil {
+		return err
+	}
+
+	if err := c.validateAttachments(); err != nil {
+		return err
+	}
+
+	if err := c.validatePullRequests(); err != nil {
+		return err
+	}
+
+	if err := c.validateBackReference(); err != nil {
+		return err
+	}
+
+	if err := c.validateDeletedComments(); err != nil {
+		return err
+	}
+
+	if err := c.validateOrphans(); err != nil {
+		return err
+	}
+
+	if err := c.validateTimeline(); err != nil {
+		return err
+	}
+
+	if err := c.validateTaskLists(); err != nil {
+		return err
+	}
+
+	if err := c.validateReferences(); err != nil {
+		return err
+	}
+
+	if err := c.validateState(); err != nil {
+		return err
+	}
+
+	if err := c.validateConcurrency(); err != nil {
+		return err
+	}
 
 	c.log.Debug("All config variables are valid!")
 
 	return nil
 }
 
+// validateOrg checks the values of an entry of the `orgs` configuration
+// parameter, and fills in the defaults of its JIRA project and `since`.
+func validateOrg(org *Org, project, since string) error {
+	if org.Owner == "" {
+		return errors.New("GitHub organization owner required")
+	}
+
+	if org.JIRAProject == "" {
+		org.JIRAProject = project
+	}
+	if org.JIRAProject == "" {
+		return fmt.Errorf("JIRA project required for organization %s", org.Owner)
+	}
+
+	if org.Since == "" {
+		org.Since = since
+	}
+	if _, err := time.Parse(dateFormat, org.Since); err != nil {
+		return fmt.Errorf("Since date of organization %s must be in ISO-8601 format", org.Owner)
+	}
+
+	for _, pattern := range append(org.Include, org.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("Invalid repository pattern %q for organization %s", pattern, org.Owner)
+		}
+	}
+
+	switch org.Visibility {
+	case "":
+		org.Visibility = "all"
+	case "all", "public", "private":
+	default:
+		return fmt.Errorf("Visibility of organization %s must be one of all, public or private", org.Owner)
+	}
+
+	return nil
+}
+
+// hasOrg returns whether owner is configured in the `orgs` configuration parameter.
+func (c Config) hasOrg(owner string) bool {
+	for _, org := range c.orgs {
+		if strings.EqualFold(org.Owner, owner) {
+			return true
+		}
+	}
+	return false
+}
+
 // jiraField represents field metadata in JIRA. For an example of its
 // structure, make a request to `${jira-uri}/rest/api/2/field`.
 type jiraField struct {
@@ -0,0 +1,135 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// defaultIssueType is the JIRA issue type used when no rule matches and no
+// default is configured.
+const defaultIssueType = "Task"
+
+// issueTypeConfig is the value of the `issue-types` configuration parameter.
+type issueTypeConfig struct {
+	// Default is the issue type used when no rule matches.
+	Default string `json:"default,omitempty" mapstructure:"default"`
+	// Update is true if the type of existing JIRA issues should be changed
+	// when the rules select a different type.
+	Update bool `json:"update,omitempty" mapstructure:"update"`
+	// Rules are checked in order; the first one to match selects the type.
+	Rules []IssueTypeRule `json:"rules,omitempty" mapstructure:"rules"`
+}
+
+// IssueTypeRule is a single rule of the `issue-types` configuration
+// parameter. A rule matches a GitHub issue if every condition it sets matches.
+type IssueTypeRule struct {
+	// Label matches issues which have this label.
+	Label string `json:"label,omitempty" mapstructure:"label"`
+	// TitlePrefix matches issues whose title starts with this prefix.
+	TitlePrefix string `json:"title-prefix,omitempty" mapstructure:"title-prefix"`
+	// Template matches issues whose body contains this text, such as a
+	// heading that only an issue template or issue form produces.
+	Template string `json:"template,omitempty" mapstructure:"template"`
+	// Type is the name of the JIRA issue type selected by the rule.
+	Type string `json:"type" mapstructure:"type"`
+}
+
+// GetIssueTypeRules returns the configured rules for selecting the JIRA issue type.
+func (c Config) GetIssueTypeRules() []IssueTypeRule {
+	return c.issueTypes.Rules
+}
+
+// GetDefaultIssueType returns the JIRA issue type used when no rule matches.
+func (c Config) GetDefaultIssueType() string {
+	if c.issueTypes.Default == "" {
+		return defaultIssueType
+	}
+	return c.issueTypes.Default
+}
+
+// IsIssueTypeUpdated returns whether the type of existing JIRA issues should
+// be changed to follow the issue type rules.
+func (c Config) IsIssueTypeUpdated() bool {
+	return c.issueTypes.Update
+}
+
+// validateIssueTypes checks the values of the `issue-types` configuration
+// parameter which can be checked without talking to JIRA.
+func (c *Config) validateIssueTypes() error {
+	if err := c.cmdConfig.UnmarshalKey("issue-types", &c.issueTypes); err != nil {
+		return fmt.Errorf("Issue types must be an object: %v", err)
+	}
+
+	for _, rule := range c.issueTypes.Rules {
+		if rule.Type == "" {
+			return errors.New("Issue type rules must have a type")
+		}
+		if rule.Label == "" && rule.TitlePrefix == "" && rule.Template == "" {
+			return fmt.Errorf("Issue type rule for %s must have a label, title-prefix or template", rule.Type)
+		}
+	}
+
+	return nil
+}
+
+// checkIssueTypes checks that the default issue type and every issue type
+// named in the `issue-types` configuration parameter can be created in each
+// configured JIRA project.
+func (c Config) checkIssueTypes(client jira.Client) error {
+	if c.issueTypes.Default == "" && len(c.issueTypes.Rules) == 0 && c.pullRequests.IssueType == "" && !c.IsTaskListSyncEnabled() {
+		return nil
+	}
+
+	c.log.Debug("Checking issue types.")
+
+	keys := make([]string, 0, len(c.projects))
+	for key := range c.projects {
+		keys = append(keys, key)
+	}
+
+	meta, res, err := client.Issue.GetCreateMeta(strings.Join(keys, ","))
+	if err != nil {
+		c.log.Errorf("Error retrieving JIRA create metadata: %v", err)
+		return getErrorBody(c, res)
+	}
+
+	types := []string{c.GetDefaultIssueType()}
+	if c.pullRequests.IssueType != "" {
+		types = append(types, c.pullRequests.IssueType)
+	}
+	for _, rule := range c.issueTypes.Rules {
+		types = append(types, rule.Type)
+	}
+
+	for _, key := range keys {
+		project := meta.GetProjectWithKey(key)
+		if project == nil {
+			return fmt.Errorf("could not find create metadata of JIRA project %s; check that the user can create issues in it", key)
+		}
+		for _, name := range types {
+			issueType := project.GetIssueTypeWithName(name)
+			if issueType == nil {
+				return fmt.Errorf("could not find issue type %q in JIRA project %s; check that it is named correctly", name, key)
+			}
+			if issueType.Subtasks {
+				return fmt.Errorf("issue type %q in JIRA project %s is a sub-task type", name, key)
+			}
+		}
+
+		if !c.IsTaskListSyncEnabled() {
+			continue
+		}
+		issueType := project.GetIssueTypeWithName(c.GetTaskIssueType())
+		if issueType == nil {
+			return fmt.Errorf("could not find sub-task issue type %q in JIRA project %s; check that it is named correctly", c.GetTaskIssueType(), key)
+		}
+		if !issueType.Subtasks {
+			return fmt.Errorf("issue type %q in JIRA project %s is not a sub-task type", c.GetTaskIssueType(), key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// labelConfig is the value of the `labels` configuration parameter.
+type labelConfig struct {
+	// PassThrough is true if GitHub labels which no rule matches should be
+	// added to the JIRA issue as JIRA labels of the same name.
+	PassThrough bool `json:"pass-through,omitempty" mapstructure:"pass-through"`
+	// Rules map individual GitHub labels to JIRA labels and components.
+	Rules []LabelRule `json:"rules,omitempty" mapstructure:"rules"`
+}
+
+// LabelRule is a single rule of the `labels` configuration parameter. It maps
+// a GitHub label to a JIRA label, a JIRA component, or both.
+type LabelRule struct {
+	// Label is the name of the GitHub label, compared case-insensitively.
+	Label string `json:"label" mapstructure:"label"`
+	// JIRALabel is the JIRA label the GitHub label is renamed to.
+	JIRALabel string `json:"jira-label,omitempty" mapstructure:"jira-label"`
+	// Component is the name of the JIRA component the GitHub label is routed to.
+	Component string `json:"component,omitempty" mapstructure:"component"`
+}
+
+// IsLabelSyncEnabled returns whether GitHub labels are mapped to JIRA labels
+// and components, rather than only recorded in the GitHub Labels field.
+func (c Config) IsLabelSyncEnabled() bool {
+	return c.labels.PassThrough || len(c.labels.Rules) > 0
+}
+
+// IsLabelPassThrough returns whether GitHub labels which no rule matches are
+// added to JIRA as labels of the same name.
+func (c Config) IsLabelPassThrough() bool {
+	return c.labels.PassThrough
+}
+
+// GetLabelRule returns the rule for the given GitHub label, if there is one.
+func (c Config) GetLabelRule(label string) (LabelRule, bool) {
+	for _, rule := range c.labels.Rules {
+		if strings.EqualFold(rule.Label, label) {
+			return rule, true
+		}
+	}
+	return LabelRule{}, false
+}
+
+// validateLabels checks the values of the `labels` configuration parameter
+// which can be checked without talking to JIRA.
+func (c *Config) validateLabels() error {
+	if err := c.cmdConfig.UnmarshalKey("labels", &c.labels); err != nil {
+		return fmt.Errorf("Labels must be an object: %v", err)
+	}
+
+	for _, rule := range c.labels.Rules {
+		if rule.Label == "" {
+			return errors.New("Label rules must have a label")
+		}
+		if rule.JIRALabel == "" && rule.Component == "" {
+			return fmt.Errorf("Label rule for %s must have a jira-label or component", rule.Label)
+		}
+		if strings.ContainsAny(rule.JIRALabel, " \t\n") {
+			return fmt.Errorf("JIRA label %q for %s cannot contain spaces", rule.JIRALabel, rule.Label)
+		}
+	}
+
+	return nil
+}
+
+// checkLabels checks that every component named in the `labels`
+// configuration parameter exists in each configured JIRA project, which are
+// loaded with their components.
+func (c Config) checkLabels() error {
+	for key, project := range c.projects {
+		for _, rule := range c.labels.Rules {
+			if rule.Component == "" {
+				continue
+			}
+			found := false
+			for _, component := range project.Components {
+				if strings.EqualFold(component.Name, rule.Component) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("could not find component %q in JIRA project %s; check that it is named correctly", rule.Component, key)
+			}
+		}
+	}
+
+	return nil
+}
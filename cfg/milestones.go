@@ -0,0 +1,53 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// milestoneConfig is the value of the `milestones` configuration parameter.
+type milestoneConfig struct {
+	// Sync is true if GitHub milestones should be mirrored as JIRA fix versions.
+	Sync bool `json:"sync,omitempty" mapstructure:"sync"`
+	// Release is true if the JIRA version of a closed milestone should be
+	// marked released, and unreleased again if the milestone is reopened.
+	Release bool `json:"release,omitempty" mapstructure:"release"`
+}
+
+// IsMilestoneSyncEnabled returns whether GitHub milestones are mirrored as
+// JIRA fix versions.
+func (c Config) IsMilestoneSyncEnabled() bool {
+	return c.milestones.Sync
+}
+
+// IsMilestoneReleaseEnabled returns whether the JIRA versions of closed
+// milestones are marked released.
+func (c Config) IsMilestoneReleaseEnabled() bool {
+	return c.milestones.Release
+}
+
+// GetMilestoneNames returns the names of the milestones of the repository the
+// configuration is scoped to, as last given to SetMilestoneNames.
+func (c Config) GetMilestoneNames() []string {
+	return c.milestoneNames
+}
+
+// SetMilestoneNames records the names of the milestones of the repository the
+// configuration is scoped to, so that the fix versions mirrored from them can
+// be told apart from those set in JIRA.
+func (c *Config) SetMilestoneNames(names []string) {
+	c.milestoneNames = names
+}
+
+// validateMilestones checks the values of the `milestones` configuration parameter.
+func (c *Config) validateMilestones() error {
+	if err := c.cmdConfig.UnmarshalKey("milestones", &c.milestones); err != nil {
+		return fmt.Errorf("Milestones must be an object: %v", err)
+	}
+
+	if c.milestones.Release && !c.milestones.Sync {
+		return errors.New("Milestones must be synchronized to release them")
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultOrphanInterval is how often orphaned JIRA issues are looked for when
+// no `interval` is configured.
+const defaultOrphanInterval = 24 * time.Hour
+
+// defaultOrphanLabel is the label added to orphaned JIRA issues when no
+// `label` is configured.
+const defaultOrphanLabel = "github-orphan"
+
+// orphanConfig is the value of the `orphans` configuration parameter.
+type orphanConfig struct {
+	// Sync is true if JIRA issues whose GitHub issue was transferred, deleted
+	// or converted to a discussion should be reconciled.
+	Sync bool `json:"sync,omitempty" mapstructure:"sync"`
+	// Interval is how often orphaned JIRA issues are looked for.
+	Interval time.Duration `json:"interval,omitempty" mapstructure:"interval"`
+	// Status is the JIRA status the issues whose GitHub issue was deleted are
+	// moved to. If empty, they are labeled instead.
+	Status string `json:"status,omitempty" mapstructure:"status"`
+	// Resolution is the JIRA resolution set when moving them to Status.
+	Resolution string `json:"resolution,omitempty" mapstructure:"resolution"`
+	// Via lists the statuses or transitions to go through to reach Status,
+	// as in a transition rule.
+	Via []string `json:"via,omitempty" mapstructure:"via"`
+	// Label is the label added to the issues which can't be relinked or closed.
+	Label string `json:"label,omitempty" mapstructure:"label"`
+}
+
+// orphanChecks records when orphaned JIRA issues were last looked for in each
+// JIRA project. It is shared by every copy of the configuration.
+type orphanChecks struct {
+	lock sync.Mutex
+	last map[string]time.Time
+}
+
+// IsOrphanSyncEnabled returns whether orphaned JIRA issues are reconciled.
+func (c Config) IsOrphanSyncEnabled() bool {
+	return c.orphans.Sync
+}
+
+// IsOrphanCheckDue returns whether orphaned JIRA issues should be looked for
+// in the given JIRA project: if they haven't been for the configured interval,
+// in this process or, if the state is kept, in previous runs.
+func (c Config) IsOrphanCheckDue(project string) bool {
+	c.orphanChecks.lock.Lock()
+	last, ok := c.orphanChecks.last[project]
+	c.orphanChecks.lock.Unlock()
+
+	if !ok {
+		last, ok = c.getStateOrphanCheck(project)
+	}
+	return !ok || time.Since(last) >= c.getOrphanInterval()
+}
+
+// SetOrphanChecked records that orphaned JIRA issues were looked for in the
+// given JIRA project at the given time, in the state as well if it is kept.
+func (c Config) SetOrphanChecked(project string, t time.Time) {
+	c.orphanChecks.lock.Lock()
+	c.orphanChecks.last[project] = t
+	c.orphanChecks.lock.Unlock()
+
+	c.setStateOrphanCheck(project, t)
+}
+
+// GetOrphanTransitionRule returns the transition rule moving the JIRA issues
+// whose GitHub issue was deleted to the configured status, and false if they
+// should be labeled instead.
+func (c Config) GetOrphanTransitionRule() (TransitionRule, bool) {
+	if c.orphans.Status == "" {
+		return TransitionRule{}, false
+	}
+	return TransitionRule{
+		State:      "deleted",
+		Status:     c.orphans.Status,
+		Resolution: c.orphans.Resolution,
+		Via:        c.orphans.Via,
+	}, true
+}
+
+// GetOrphanLabel returns the label added to orphaned JIRA issues.
+func (c Config) GetOrphanLabel() string {
+	if c.orphans.Label == "" {
+		return defaultOrphanLabel
+	}
+	return c.orphans.Label
+}
+
+func (c Config) getOrphanInterval() time.Duration {
+	if c.orphans.Interval == 0 {
+		return defaultOrphanInterval
+	}
+	return c.orphans.Interval
+}
+
+// validateOrphans checks the values of the `orphans` configuration parameter.
+func (c *Config) validateOrphans() error {
+	if err := c.cmdConfig.UnmarshalKey("orphans", &c.orphans); err != nil {
+		return fmt.Errorf("Orphans must be an object: %v", err)
+	}
+
+	if c.orphans.Interval < 0 {
+		return fmt.Errorf("Orphan interval must be positive; got %v", c.orphans.Interval)
+	}
+	if c.orphans.Status == "" && (c.orphans.Resolution != "" || len(c.orphans.Via) > 0) {
+		return errors.New("Orphan resolution and via require a status")
+	}
+
+	c.orphanChecks = &orphanChecks{
+		last: map[string]time.Time{},
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// priorityConfig is the value of the `priorities` configuration parameter.
+type priorityConfig struct {
+	// Conflict selects the priority when several rules match: "highest",
+	// "lowest", "first" or "ignore".
+	Conflict string `json:"conflict,omitempty" mapstructure:"conflict"`
+	// Rules map GitHub labels to JIRA priorities.
+	Rules []PriorityRule `json:"rules,omitempty" mapstructure:"rules"`
+}
+
+// PriorityRule is a single rule of the `priorities` configuration parameter.
+// It maps a GitHub label to a JIRA priority.
+type PriorityRule struct {
+	// Label is the name of the GitHub label, compared case-insensitively.
+	Label string `json:"label" mapstructure:"label"`
+	// Priority is the name of the JIRA priority selected by the rule.
+	Priority string `json:"priority" mapstructure:"priority"`
+}
+
+// Ways of resolving conflicts between several matching priority rules.
+const (
+	// PriorityConflictHighest selects the highest of the matching priorities.
+	PriorityConflictHighest = "highest"
+	// PriorityConflictLowest selects the lowest of the matching priorities.
+	PriorityConflictLowest = "lowest"
+	// PriorityConflictFirst selects the priority of the first matching rule.
+	PriorityConflictFirst = "first"
+	// PriorityConflictIgnore leaves the priority as it is.
+	PriorityConflictIgnore = "ignore"
+)
+
+// GetPriorityRules returns the configured rules mapping GitHub labels to JIRA priorities.
+func (c Config) GetPriorityRules() []PriorityRule {
+	return c.priorities.Rules
+}
+
+// GetPriorityConflict returns how a conflict between several matching priority
+// rules is resolved; see the PriorityConflict constants.
+func (c Config) GetPriorityConflict() string {
+	if c.priorities.Conflict == "" {
+		return PriorityConflictHighest
+	}
+	return c.priorities.Conflict
+}
+
+// GetPriorityRank returns the position of a JIRA priority in the list of
+// priorities of the JIRA instance, from the highest (0) to the lowest.
+func (c Config) GetPriorityRank(name string) int {
+	for i, priority := range c.priorityOrder {
+		if strings.EqualFold(priority, name) {
+			return i
+		}
+	}
+	return len(c.priorityOrder)
+}
+
+// validatePriorities checks the values of the `priorities` configuration
+// parameter which can be checked without talking to JIRA.
+func (c *Config) validatePriorities() error {
+	if err := c.cmdConfig.UnmarshalKey("priorities", &c.priorities); err != nil {
+		return fmt.Errorf("Priorities must be an object: %v", err)
+	}
+
+	switch c.priorities.Conflict {
+	case "", PriorityConflictHighest, PriorityConflictLowest, PriorityConflictFirst, PriorityConflictIgnore:
+	default:
+		return fmt.Errorf("Priority conflict must be highest, lowest, first or ignore; got %q", c.priorities.Conflict)
+	}
+
+	for _, rule := range c.priorities.Rules {
+		if rule.Label == "" {
+			return errors.New("Priority rules must have a label")
+		}
+		if rule.Priority == "" {
+			return fmt.Errorf("Priority rule for %s must have a priority", rule.Label)
+		}
+	}
+
+	return nil
+}
+
+// checkPriorities checks that every priority named in the `priorities`
+// configuration parameter exists on the JIRA server, and records the order
+// of the priorities.
+func (c *Config) checkPriorities(client jira.Client) error {
+	if len(c.priorities.Rules) == 0 {
+		return nil
+	}
+
+	c.log.Debug("Checking priorities.")
+
+	req, err := client.NewRequest("GET", "/rest/api/2/priority", nil)
+	if err != nil {
+		return err
+	}
+	priorities := new([]jira.Priority)
+	if _, err := client.Do(req, priorities); err != nil {
+		return err
+	}
+
+	// JIRA lists the priorities from the highest to the lowest.
+	c.priorityOrder = make([]string, len(*priorities))
+	for i, priority := range *priorities {
+		c.priorityOrder[i] = priority.Name
+	}
+
+	for _, rule := range c.priorities.Rules {
+		if c.GetPriorityRank(rule.Priority) == len(c.priorityOrder) {
+			return fmt.Errorf("could not find JIRA priority %q used in priorities; check that it is named correctly", rule.Priority)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Modes of the `sync` key of the `pull-requests` configuration parameter.
+const (
+	// PullRequestSyncIssues mirrors pull requests as JIRA issues of their own.
+	PullRequestSyncIssues = "issues"
+	// PullRequestSyncLinks adds a remote link to each pull request on the JIRA
+	// issues mirroring the GitHub issues it closes.
+	PullRequestSyncLinks = "links"
+)
+
+// pullRequestConfig is the value of the `pull-requests` configuration parameter.
+type pullRequestConfig struct {
+	// Sync is how pull requests are mirrored: PullRequestSyncIssues,
+	// PullRequestSyncLinks, or empty if they aren't.
+	Sync string `json:"sync,omitempty" mapstructure:"sync"`
+	// IssueType is the JIRA issue type pull requests are mirrored as when no
+	// issue type rule matches them.
+	IssueType string `json:"issue-type,omitempty" mapstructure:"issue-type"`
+}
+
+// IsPullRequestSyncEnabled returns whether pull requests are mirrored at all.
+func (c Config) IsPullRequestSyncEnabled() bool {
+	return c.pullRequests.Sync != ""
+}
+
+// GetPullRequestSync returns how pull requests are mirrored: PullRequestSyncIssues,
+// PullRequestSyncLinks, or the empty string if they aren't.
+func (c Config) GetPullRequestSync() string {
+	return c.pullRequests.Sync
+}
+
+// GetPullRequestIssueType returns the JIRA issue type pull requests are
+// mirrored as when no issue type rule matches them.
+func (c Config) GetPullRequestIssueType() string {
+	if c.pullRequests.IssueType == "" {
+		return c.GetDefaultIssueType()
+	}
+	return c.pullRequests.IssueType
+}
+
+// validatePullRequests checks the values of the `pull-requests` configuration
+// parameter.
+func (c *Config) validatePullRequests() error {
+	if err := c.cmdConfig.UnmarshalKey("pull-requests", &c.pullRequests); err != nil {
+		return fmt.Errorf("Pull requests must be an object: %v", err)
+	}
+
+	switch c.pullRequests.Sync {
+	case "", PullRequestSyncIssues, PullRequestSyncLinks:
+	default:
+		return fmt.Errorf("Pull request sync must be %s or %s; got %q", PullRequestSyncIssues, PullRequestSyncLinks, c.pullRequests.Sync)
+	}
+
+	if c.pullRequests.IssueType != "" && c.pullRequests.Sync != PullRequestSyncIssues {
+		return errors.New("Pull request issue type requires pull requests to be synced as issues")
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// defaultReferenceLinkType is the JIRA issue link type of plain references
+// when no `link-type` is configured.
+const defaultReferenceLinkType = "Relates"
+
+// defaultReferenceKeywords are the keywords used when none are configured.
+var defaultReferenceKeywords = []ReferenceKeyword{
+	{Keyword: "duplicate of", LinkType: "Duplicate"},
+	{Keyword: "duplicates", LinkType: "Duplicate"},
+	{Keyword: "blocks", LinkType: "Blocks"},
+	{Keyword: "blocked by", LinkType: "Blocks", Inward: true},
+	{Keyword: "depends on", LinkType: "Blocks", Inward: true},
+}
+
+// ReferenceKeyword is a single entry of the `keywords` of the `references`
+// configuration parameter. It maps the keyword preceding a reference to a
+// GitHub issue, e.g. "duplicate of #67", to the JIRA issue link type linking
+// the JIRA issues.
+type ReferenceKeyword struct {
+	// Keyword is the keyword, matched case-insensitively.
+	Keyword string `json:"keyword" mapstructure:"keyword"`
+	// LinkType is the name of the JIRA issue link type.
+	LinkType string `json:"link-type" mapstructure:"link-type"`
+	// Inward is true if the referenced issue is related to the issue by the
+	// outward description of the link type, e.g. for "blocked by #12", #12
+	// blocks the issue. Otherwise the issue is related to the referenced one.
+	Inward bool `json:"inward,omitempty" mapstructure:"inward"`
+}
+
+// referenceConfig is the value of the `references` configuration parameter.
+type referenceConfig struct {
+	// Sync is true if references between mirrored GitHub issues should be
+	// mirrored as JIRA issue links, and rewritten to JIRA keys.
+	Sync bool `json:"sync,omitempty" mapstructure:"sync"`
+	// LinkType is the name of the JIRA issue link type of references which
+	// aren't preceded by a keyword. If empty, they aren't linked.
+	LinkType *string `json:"link-type,omitempty" mapstructure:"link-type"`
+	// Keywords are the keywords mapping references to other link types.
+	Keywords []ReferenceKeyword `json:"keywords,omitempty" mapstructure:"keywords"`
+}
+
+// issueLinks records the JIRA issue links created during the current run. It
+// is shared by every copy of the configuration, so that issues synchronized in
+// parallel which reference each other don't both create the link.
+type issueLinks struct {
+	lock sync.Mutex
+	// links holds the name of each link type, in lowercase, followed by the
+	// keys of the issues it links, in sorted order.
+	links map[string]bool
+}
+
+// IsReferenceSyncEnabled returns whether references between mirrored GitHub
+// issues are mirrored as JIRA issue links, and rewritten to JIRA keys.
+func (c Config) IsReferenceSyncEnabled() bool {
+	return c.references.Sync
+}
+
+// GetReferenceLinkType returns the name of the JIRA issue link type of
+// references which aren't preceded by a keyword, or the empty string if they
+// shouldn't be linked.
+func (c Config) GetReferenceLinkType() string {
+	if c.references.LinkType == nil {
+		return defaultReferenceLinkType
+	}
+	return *c.references.LinkType
+}
+
+// GetReferenceKeywords returns the keywords mapping references to JIRA issue
+// link types.
+func (c Config) GetReferenceKeywords() []ReferenceKeyword {
+	if len(c.references.Keywords) == 0 {
+		return defaultReferenceKeywords
+	}
+	return c.references.Keywords
+}
+
+// GetReferenceKeyword returns the keyword matching the given one, and false
+// if there is none.
+func (c Config) GetReferenceKeyword(keyword string) (ReferenceKeyword, bool) {
+	keyword = strings.Join(strings.Fields(keyword), " ")
+	for _, k := range c.GetReferenceKeywords() {
+		if strings.EqualFold(k.Keyword, keyword) {
+			return k, true
+		}
+	}
+	return ReferenceKeyword{}, false
+}
+
+// ClaimIssueLink records that a JIRA issue link of the given type between the
+// issues with the given keys, in either direction, is being created. It
+// returns false if it already was during this run, and shouldn't be created.
+func (c Config) ClaimIssueLink(linkType, key1, key2 string) bool {
+	c.issueLinks.lock.Lock()
+	defer c.issueLinks.lock.Unlock()
+
+	name := issueLinkName(linkType, key1, key2)
+	if c.issueLinks.links[name] {
+		return false
+	}
+	c.issueLinks.links[name] = true
+	return true
+}
+
+// ReleaseIssueLink forgets a JIRA issue link claimed with ClaimIssueLink, if
+// it couldn't be created.
+func (c Config) ReleaseIssueLink(linkType, key1, key2 string) {
+	c.issueLinks.lock.Lock()
+	defer c.issueLinks.lock.Unlock()
+
+	delete(c.issueLinks.links, issueLinkName(linkType, key1, key2))
+}
+
+// ResetIssueLinks forgets the JIRA issue links created, at the start of a run,
+// so that links removed in JIRA since are created again.
+func (c Config) ResetIssueLinks() {
+	c.issueLinks.lock.Lock()
+	defer c.issueLinks.lock.Unlock()
+
+	c.issueLinks.links = map[string]bool{}
+}
+
+// issueLinkName returns the key of issueLinks for a link of the given type
+// between the issues with the given keys.
+func issueLinkName(linkType, key1, key2 string) string {
+	if key2 < key1 {
+		key1, key2 = key2, key1
+	}
+	return fmt.Sprintf("%s %s %s", strings.ToLower(linkType), key1, key2)
+}
+
+// validateReferences checks the values of the `references` configuration parameter.
+func (c *Config) validateReferences() error {
+	if err := c.cmdConfig.UnmarshalKey("references", &c.references); err != nil {
+		return fmt.Errorf("References must be an object: %v", err)
+	}
+
+	for _, k := range c.references.Keywords {
+		if strings.TrimSpace(k.Keyword) == "" {
+			return errors.New("Reference keyword required for each keyword")
+		}
+		if k.LinkType == "" {
+			return fmt.Errorf("Reference link type required for keyword %q", k.Keyword)
+		}
+	}
+
+	c.issueLinks = &issueLinks{
+		links: map[string]bool{},
+	}
+
+	return nil
+}
+
+// checkLinkTypes checks that every issue link type named in the `references`
+// and `task-lists` configuration parameters exists on the JIRA server.
+func (c Config) checkLinkTypes(client jira.Client) error {
+	var names []string
+	if c.IsTaskListSyncEnabled() {
+		names = append(names, c.GetTaskLinkType())
+	}
+	if c.IsReferenceSyncEnabled() {
+		if name := c.GetReferenceLinkType(); name != "" {
+			names = append(names, name)
+		}
+		for _, k := range c.GetReferenceKeywords() {
+			names = append(names, k.LinkType)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	c.log.Debug("Checking issue link types.")
+
+	req, err := client.NewRequest("GET", "/rest/api/2/issueLinkType", nil)
+	if err != nil {
+		return err
+	}
+	result := struct {
+		IssueLinkTypes []jira.IssueLinkType `json:"issueLinkTypes"`
+	}{}
+	if _, err := client.Do(req, &result); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		found := false
+		for _, t := range result.IssueLinkTypes {
+			if strings.EqualFold(t.Name, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("could not find JIRA issue link type %q; check that it is named correctly", name)
+		}
+	}
+
+	return nil
+}
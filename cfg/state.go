@@ -0,0 +1,452 @@
+package cfg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stateVersion is the version of the format of the state file. A state file
+// of another version is rebuilt.
+const stateVersion = 1
+
+// stateConfig is the value of the `state` configuration parameter.
+type stateConfig struct {
+	// File is the path to the file the state is saved to between runs. If
+	// empty, no state is kept.
+	File string `json:"file,omitempty" mapstructure:"file"`
+}
+
+// stateFile is the serializable representation of the state.
+type stateFile struct {
+	Version int `json:"version"`
+	// Issues maps the ID of each mirrored GitHub issue to its state.
+	Issues map[int]*issueState `json:"issues"`
+	// IssueIDs maps the full name of GitHub issues, e.g.
+	// "coreos/issue-sync#12" in lowercase, to their ID. It holds the issues
+	// which were synchronized or referenced, whether they are mirrored or not.
+	IssueIDs map[string]int `json:"issue-ids,omitempty"`
+	// OrphanChecks maps the key of each JIRA project to when orphaned JIRA
+	// issues were last looked for in it.
+	OrphanChecks map[string]time.Time `json:"orphan-checks,omitempty"`
+}
+
+// issueState is the state of a mirrored GitHub issue.
+type issueState struct {
+	// Key is the key of the JIRA issue mirroring it.
+	Key string `json:"key"`
+	// Hash is the hash of the content of the GitHub issue when it was last
+	// mirrored, or empty if it should be compared again.
+	Hash string `json:"hash,omitempty"`
+	// Comments maps the ID of each mirrored GitHub comment to its state.
+	Comments map[int]commentState `json:"comments,omitempty"`
+	// CommentsUpdated is the latest update time of the GitHub comments when
+	// they were last mirrored, if they all were.
+	CommentsUpdated *time.Time `json:"comments-updated,omitempty"`
+	// BackReferencePending is true if the JIRA issue was created, but its
+	// key hasn't been written back to the GitHub issue yet.
+	BackReferencePending bool `json:"back-reference-pending,omitempty"`
+}
+
+// commentState is the state of a mirrored GitHub comment.
+type commentState struct {
+	// ID is the ID of the JIRA comment mirroring it.
+	ID string `json:"id"`
+	// Hash is the hash of the body of the GitHub comment when it was last
+	// mirrored, or empty if it should be compared again.
+	Hash string `json:"hash,omitempty"`
+}
+
+// stateStore records which JIRA issues and comments mirror which GitHub
+// issues and comments, and the hash of their content when they were last
+// mirrored. It is shared by every copy of the configuration.
+type stateStore struct {
+	lock sync.Mutex
+	file stateFile
+	// rebuild is true if the state should be rebuilt from JIRA.
+	rebuild bool
+}
+
+// IsStateEnabled returns whether the state is kept in the configured state file.
+func (c Config) IsStateEnabled() bool {
+	return c.state.File != ""
+}
+
+// IsStateRebuildDue returns whether the state should be rebuilt from JIRA,
+// because there was no state file, it was invalid, or --rebuild-state was given.
+// The state isn't rebuilt in dry-run mode.
+func (c Config) IsStateRebuildDue() bool {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	return c.isStateWritable() && c.stateStore.rebuild
+}
+
+// IsCompareForced returns whether --compare-all was given: the content of every
+// GitHub issue and comment retrieved is compared to JIRA, regardless of the
+// hashes and comment update times recorded in the state.
+func (c Config) IsCompareForced() bool {
+	return c.cmdConfig.GetBool("compare-all")
+}
+
+// GetMappingFingerprint returns a hash of the configuration which changes how
+// the content of GitHub issues and comments is mirrored to JIRA, so that the
+// hashes recorded in the state no longer match once it changes.
+func (c Config) GetMappingFingerprint() string {
+	mapping := struct {
+		IssueTypes    issueTypeConfig
+		Labels        labelConfig
+		Milestones    milestoneConfig
+		EmailLookup   bool
+		DefaultUsers  []string
+		Mentions      bool
+		Users         map[string]string
+		Priorities    priorityConfig
+		Attachments   bool
+		PullRequests  pullRequestConfig
+		BackReference backReferenceConfig
+		References    referenceConfig
+	}{
+		IssueTypes:    c.issueTypes,
+		Labels:        c.labels,
+		Milestones:    c.milestones,
+		EmailLookup:   c.users.EmailLookup,
+		DefaultUsers:  []string{c.users.DefaultReporter, c.users.DefaultAssignee},
+		Mentions:      c.users.Mentions,
+		Priorities:    c.priorities,
+		Attachments:   c.attachments.Sync,
+		PullRequests:  c.pullRequests,
+		BackReference: c.backReference,
+		References:    c.references,
+	}
+	if c.userDirectory != nil {
+		mapping.Users = c.userDirectory.mapping
+	}
+
+	// The fields are only ever read, and maps are marshalled in key order.
+	b, _ := json.Marshal(mapping)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// ResetState forgets every mirrored issue and comment, to rebuild the state.
+func (c Config) ResetState() {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	c.stateStore.file.Issues = map[int]*issueState{}
+}
+
+// SetStateRebuilt records that the state was rebuilt from JIRA.
+func (c Config) SetStateRebuilt() {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	c.stateStore.rebuild = false
+}
+
+// GetMirroredIssue returns the key of the JIRA issue mirroring the GitHub
+// issue with the given ID, and the hash of its content when it was last
+// mirrored. It returns false if the issue isn't known.
+func (c Config) GetMirroredIssue(id int) (string, string, bool) {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	issue, ok := c.stateStore.file.Issues[id]
+	if !ok {
+		return "", "", false
+	}
+	return issue.Key, issue.Hash, true
+}
+
+// SetMirroredIssue records that the GitHub issue with the given ID is mirrored
+// by the JIRA issue with the given key, with the given hash of its content.
+// The comments of the issue are forgotten if the key changed.
+func (c Config) SetMirroredIssue(id int, key, hash string) {
+	if !c.isStateWritable() {
+		return
+	}
+
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	issue, ok := c.stateStore.file.Issues[id]
+	if !ok || issue.Key != key {
+		issue = &issueState{Key: key}
+		c.stateStore.file.Issues[id] = issue
+	}
+	issue.Hash = hash
+}
+
+// ForgetMirroredIssue forgets the GitHub issue with the given ID and its comments.
+func (c Config) ForgetMirroredIssue(id int) {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	delete(c.stateStore.file.Issues, id)
+}
+
+// GetMirroredComment returns the ID of the JIRA comment mirroring the GitHub
+// comment with the given ID, of the issue with the given ID, and the hash of
+// its body when it was last mirrored. It returns false if the comment isn't known.
+func (c Config) GetMirroredComment(issueID, id int) (string, string, bool) {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	issue, ok := c.stateStore.file.Issues[issueID]
+	if !ok {
+		return "", "", false
+	}
+	comment, ok := issue.Comments[id]
+	return comment.ID, comment.Hash, ok
+}
+
+// GetMirroredComments returns a map from the ID of each known GitHub comment
+// of the issue with the given ID to the ID of the JIRA comment mirroring it.
+func (c Config) GetMirroredComments(issueID int) map[int]string {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	comments := map[int]string{}
+	if issue, ok := c.stateStore.file.Issues[issueID]; ok {
+		for id, comment := range issue.Comments {
+			comments[id] = comment.ID
+		}
+	}
+	return comments
+}
+
+// SetMirroredComment records that the GitHub comment with the given ID, of
+// the issue with the given ID, is mirrored by the JIRA comment with the given
+// ID, with the given hash of its body. It does nothing if the issue isn't known.
+func (c Config) SetMirroredComment(issueID, id int, jiraID, hash string) {
+	if !c.isStateWritable() {
+		return
+	}
+
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	issue, ok := c.stateStore.file.Issues[issueID]
+	if !ok {
+		return
+	}
+	if issue.Comments == nil {
+		issue.Comments = map[int]commentState{}
+	}
+	issue.Comments[id] = commentState{ID: jiraID, Hash: hash}
+}
+
+// GetCommentsUpdated returns the latest update time of the comments of the
+// GitHub issue with the given ID when they were last all mirrored, or the zero
+// time if they should all be compared again.
+func (c Config) GetCommentsUpdated(issueID int) time.Time {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	issue, ok := c.stateStore.file.Issues[issueID]
+	if !ok || issue.CommentsUpdated == nil {
+		return time.Time{}
+	}
+	return *issue.CommentsUpdated
+}
+
+// SetCommentsUpdated records the latest update time of the comments of the
+// GitHub issue with the given ID, once they have all been mirrored. It does
+// nothing if the issue isn't known.
+func (c Config) SetCommentsUpdated(issueID int, updated time.Time) {
+	if !c.isStateWritable() {
+		return
+	}
+
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	if issue, ok := c.stateStore.file.Issues[issueID]; ok {
+		issue.CommentsUpdated = &updated
+	}
+}
+
+// IsBackReferencePending returns whether the key of the JIRA issue mirroring
+// the GitHub issue with the given ID is still to be written back to it.
+func (c Config) IsBackReferencePending(issueID int) bool {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	issue, ok := c.stateStore.file.Issues[issueID]
+	return ok && issue.BackReferencePending
+}
+
+// SetBackReferencePending records whether the key of the JIRA issue mirroring
+// the GitHub issue with the given ID is still to be written back to it. It
+// does nothing if the issue isn't known.
+func (c Config) SetBackReferencePending(issueID int, pending bool) {
+	if !c.isStateWritable() {
+		return
+	}
+
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	if issue, ok := c.stateStore.file.Issues[issueID]; ok {
+		issue.BackReferencePending = pending
+	}
+}
+
+// ForgetMirroredComment forgets the GitHub comment with the given ID, of the
+// issue with the given ID.
+func (c Config) ForgetMirroredComment(issueID, id int) {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	if issue, ok := c.stateStore.file.Issues[issueID]; ok {
+		delete(issue.Comments, id)
+	}
+}
+
+// GetIssueID returns the ID of the GitHub issue with the given number in the
+// given repository ("owner/repo"), as last recorded with SetIssueID, and
+// false if it isn't known.
+func (c Config) GetIssueID(repo string, number int) (int, bool) {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	id, ok := c.stateStore.file.IssueIDs[issueName(repo, number)]
+	return id, ok
+}
+
+// SetIssueID records the ID of the GitHub issue with the given number in the
+// given repository ("owner/repo"). It is recorded even if the state isn't
+// kept, for the rest of the run, since it never changes.
+func (c Config) SetIssueID(repo string, number, id int) {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	if c.stateStore.file.IssueIDs == nil {
+		c.stateStore.file.IssueIDs = map[string]int{}
+	}
+	c.stateStore.file.IssueIDs[issueName(repo, number)] = id
+}
+
+// issueName returns the key of IssueIDs for the GitHub issue with the given
+// number in the given repository.
+func issueName(repo string, number int) string {
+	return fmt.Sprintf("%s#%d", strings.ToLower(repo), number)
+}
+
+// getStateOrphanCheck returns when orphaned JIRA issues were last looked for
+// in the given JIRA project, as recorded in the state, and false if it isn't.
+func (c Config) getStateOrphanCheck(project string) (time.Time, bool) {
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	last, ok := c.stateStore.file.OrphanChecks[project]
+	return last, ok
+}
+
+// setStateOrphanCheck records in the state when orphaned JIRA issues were last
+// looked for in the given JIRA project.
+func (c Config) setStateOrphanCheck(project string, t time.Time) {
+	if !c.isStateWritable() {
+		return
+	}
+
+	c.stateStore.lock.Lock()
+	defer c.stateStore.lock.Unlock()
+
+	if c.stateStore.file.OrphanChecks == nil {
+		c.stateStore.file.OrphanChecks = map[string]time.Time{}
+	}
+	c.stateStore.file.OrphanChecks[project] = t
+}
+
+// isStateWritable returns whether mirrored issues and comments are recorded:
+// if the state is kept, and nothing is actually mirrored in dry-run mode.
+func (c Config) isStateWritable() bool {
+	return c.IsStateEnabled() && !c.IsDryRun()
+}
+
+// SaveState saves the state to the configured state file, if there is one.
+// The file is replaced atomically, so that it is never left half-written.
+func (c Config) SaveState() error {
+	if !c.IsStateEnabled() {
+		return nil
+	}
+
+	c.stateStore.lock.Lock()
+	b, err := json.Marshal(c.stateStore.file)
+	c.stateStore.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomically(c.state.File, b)
+}
+
+// writeFileAtomically replaces the file at path with one holding b, readable
+// only by its owner, by writing a temporary file and renaming it, so that the
+// file is never left half-written.
+func writeFileAtomically(path string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// validateState checks the values of the `state` configuration parameter, and
+// loads the state file. The state is rebuilt if the file doesn't exist or is
+// invalid, or if --rebuild-state is given.
+func (c *Config) validateState() error {
+	if err := c.cmdConfig.UnmarshalKey("state", &c.state); err != nil {
+		return fmt.Errorf("State must be an object: %v", err)
+	}
+
+	c.stateStore = &stateStore{
+		file: stateFile{
+			Version: stateVersion,
+			Issues:  map[int]*issueState{},
+		},
+		rebuild: c.cmdConfig.GetBool("rebuild-state"),
+	}
+
+	if !c.IsStateEnabled() || c.stateStore.rebuild {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(c.state.File)
+	if os.IsNotExist(err) {
+		c.stateStore.rebuild = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error reading state file: %v", err)
+	}
+
+	var state stateFile
+	if err := json.Unmarshal(b, &state); err != nil || state.Version != stateVersion || state.Issues == nil {
+		c.log.Warnf("Rebuilding invalid state file %s", c.state.File)
+		c.stateStore.rebuild = true
+		return nil
+	}
+	c.stateStore.file = state
+
+	return nil
+}
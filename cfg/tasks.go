@@ -0,0 +1,101 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// defaultTaskIssueType is the JIRA issue type of the sub-tasks created for
+// task list items when no `issue-type` is configured.
+const defaultTaskIssueType = "Sub-task"
+
+// defaultTaskLinkType is the JIRA issue link type used to link issues to the
+// issues referenced in their task lists when no `link-type` is configured.
+const defaultTaskLinkType = "Blocks"
+
+// taskListConfig is the value of the `task-lists` configuration parameter.
+type taskListConfig struct {
+	// Sync is true if the items of the task lists of GitHub issues should be
+	// mirrored as JIRA sub-tasks.
+	Sync bool `json:"sync,omitempty" mapstructure:"sync"`
+	// IssueType is the JIRA issue type of the sub-tasks. It must be a sub-task
+	// issue type.
+	IssueType string `json:"issue-type,omitempty" mapstructure:"issue-type"`
+	// Status is the JIRA status the sub-tasks of checked items are moved to.
+	Status string `json:"status,omitempty" mapstructure:"status"`
+	// Resolution is the JIRA resolution set when moving them to Status.
+	Resolution string `json:"resolution,omitempty" mapstructure:"resolution"`
+	// Via lists the statuses or transitions to go through to reach Status,
+	// as in a transition rule.
+	Via []string `json:"via,omitempty" mapstructure:"via"`
+	// ReopenStatus is the JIRA status the sub-tasks of items which are
+	// unchecked are moved back to. If empty, they are left as they are.
+	ReopenStatus string `json:"reopen-status,omitempty" mapstructure:"reopen-status"`
+	// LinkType is the name of the JIRA issue link type linking the issues
+	// referenced in task lists to the issue, as its children.
+	LinkType string `json:"link-type,omitempty" mapstructure:"link-type"`
+}
+
+// IsTaskListSyncEnabled returns whether the items of the task lists of GitHub
+// issues are mirrored as JIRA sub-tasks.
+func (c Config) IsTaskListSyncEnabled() bool {
+	return c.taskLists.Sync
+}
+
+// GetTaskIssueType returns the JIRA issue type of the sub-tasks created for
+// task list items.
+func (c Config) GetTaskIssueType() string {
+	if c.taskLists.IssueType == "" {
+		return defaultTaskIssueType
+	}
+	return c.taskLists.IssueType
+}
+
+// GetTaskDoneRule returns the transition rule moving the sub-tasks of checked
+// task list items to the configured status.
+func (c Config) GetTaskDoneRule() TransitionRule {
+	return TransitionRule{
+		State:      "checked",
+		Status:     c.taskLists.Status,
+		Resolution: c.taskLists.Resolution,
+		Via:        c.taskLists.Via,
+	}
+}
+
+// GetTaskReopenRule returns the transition rule moving the sub-tasks of
+// unchecked task list items back to the configured status, and false if they
+// should be left as they are.
+func (c Config) GetTaskReopenRule() (TransitionRule, bool) {
+	if c.taskLists.ReopenStatus == "" {
+		return TransitionRule{}, false
+	}
+	return TransitionRule{
+		State:  "unchecked",
+		Status: c.taskLists.ReopenStatus,
+	}, true
+}
+
+// GetTaskLinkType returns the name of the JIRA issue link type linking the
+// issues referenced in task lists to the issue.
+func (c Config) GetTaskLinkType() string {
+	if c.taskLists.LinkType == "" {
+		return defaultTaskLinkType
+	}
+	return c.taskLists.LinkType
+}
+
+// validateTaskLists checks the values of the `task-lists` configuration parameter.
+func (c *Config) validateTaskLists() error {
+	if err := c.cmdConfig.UnmarshalKey("task-lists", &c.taskLists); err != nil {
+		return fmt.Errorf("Task lists must be an object: %v", err)
+	}
+
+	if c.taskLists.Sync && c.taskLists.Status == "" {
+		return errors.New("Task list status required to mirror task lists")
+	}
+	if c.taskLists.Status == "" && (c.taskLists.Resolution != "" || len(c.taskLists.Via) > 0) {
+		return errors.New("Task list resolution and via require a status")
+	}
+
+	return nil
+}
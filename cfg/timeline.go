@@ -0,0 +1,72 @@
+package cfg
+
+import "fmt"
+
+// TimelineEvents are the GitHub timeline event types which can be mirrored.
+var TimelineEvents = []string{
+	"closed", "reopened",
+	"labeled", "unlabeled",
+	"assigned", "unassigned",
+	"milestoned", "demilestoned",
+	"renamed",
+	"referenced", "cross-referenced",
+	"marked_as_duplicate", "unmarked_as_duplicate",
+}
+
+// defaultTimelineEvents are the event types mirrored when no `events` are
+// configured.
+var defaultTimelineEvents = []string{
+	"closed", "reopened", "assigned", "unassigned", "cross-referenced", "marked_as_duplicate",
+}
+
+// timelineConfig is the value of the `timeline` configuration parameter.
+type timelineConfig struct {
+	// Sync is true if events of the timeline of GitHub issues should be
+	// mirrored as JIRA comments.
+	Sync bool `json:"sync,omitempty" mapstructure:"sync"`
+	// Events are the types of events which are mirrored.
+	Events []string `json:"events,omitempty" mapstructure:"events"`
+}
+
+// IsTimelineSyncEnabled returns whether events of the timeline of GitHub
+// issues are mirrored as JIRA comments.
+func (c Config) IsTimelineSyncEnabled() bool {
+	return c.timeline.Sync
+}
+
+// IsTimelineEventMirrored returns whether timeline events of the given type
+// are mirrored.
+func (c Config) IsTimelineEventMirrored(event string) bool {
+	events := c.timeline.Events
+	if len(events) == 0 {
+		events = defaultTimelineEvents
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTimeline checks the values of the `timeline` configuration parameter.
+func (c *Config) validateTimeline() error {
+	if err := c.cmdConfig.UnmarshalKey("timeline", &c.timeline); err != nil {
+		return fmt.Errorf("Timeline must be an object: %v", err)
+	}
+
+	for _, event := range c.timeline.Events {
+		found := false
+		for _, e := range TimelineEvents {
+			if e == event {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Timeline event %q is not supported; supported events are %v", event, TimelineEvents)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package cfg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// TransitionRule is a single entry of the `transitions` configuration
+// parameter. It maps a GitHub issue state, and optionally the reason the
+// issue is in that state, to the JIRA status the mirrored issue should be
+// moved to.
+type TransitionRule struct {
+	// State is the GitHub issue state, "open" or "closed".
+	State string `json:"state" mapstructure:"state"`
+	// Reason is the GitHub `state_reason` ("completed", "not_planned" or
+	// "reopened"), or for pull requests, their review or merge state
+	// ("approved", "changes_requested" or "merged"). If empty, the rule
+	// applies regardless of the reason.
+	Reason string `json:"reason,omitempty" mapstructure:"reason"`
+	// Status is the name of the JIRA status the issue is transitioned to.
+	Status string `json:"status" mapstructure:"status"`
+	// Resolution is the name of the JIRA resolution set when transitioning,
+	// if the transition allows one.
+	Resolution string `json:"resolution,omitempty" mapstructure:"resolution"`
+	// Via is a list of intermediate statuses or transition names to go through
+	// when no transition leads directly to Status from the current status.
+	Via []string `json:"via,omitempty" mapstructure:"via"`
+}
+
+// GetTransitionRules returns every configured transition rule for the given
+// GitHub issue state.
+func (c Config) GetTransitionRules(state string) []TransitionRule {
+	var rules []TransitionRule
+	for _, rule := range c.transitions {
+		if rule.State == state {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// GetTransitionRule returns the transition rule which applies to a GitHub
+// issue in the given state for the given reason. A rule for that exact reason
+// is preferred over one which applies to any reason.
+func (c Config) GetTransitionRule(state, reason string) (TransitionRule, bool) {
+	var fallback *TransitionRule
+	for i, rule := range c.transitions {
+		if rule.State != state {
+			continue
+		}
+		if rule.Reason == reason && reason != "" {
+			return rule, true
+		}
+		if rule.Reason == "" && fallback == nil {
+			fallback = &c.transitions[i]
+		}
+	}
+	if fallback == nil {
+		return TransitionRule{}, false
+	}
+	return *fallback, true
+}
+
+// validateTransitions checks the values of the `transitions` configuration
+// parameter which can be checked without talking to JIRA.
+func (c *Config) validateTransitions() error {
+	if err := c.cmdConfig.UnmarshalKey("transitions", &c.transitions); err != nil {
+		return fmt.Errorf("Transitions must be a list of transition rules: %v", err)
+	}
+
+	for _, rule := range c.transitions {
+		switch rule.State {
+		case "open", "closed":
+		default:
+			return fmt.Errorf("Transition state must be open or closed; got %q", rule.State)
+		}
+
+		switch rule.Reason {
+		case "", "completed", "not_planned", "reopened":
+		case "merged", "approved", "changes_requested":
+			// The state of a pull request; see `pull-requests`.
+		default:
+			return fmt.Errorf("Transition reason must be completed, not_planned, reopened, merged, approved or changes_requested; got %q", rule.Reason)
+		}
+
+		if rule.Status == "" {
+			return fmt.Errorf("Transition status required for state %s", rule.State)
+		}
+	}
+
+	return nil
+}
+
+// checkTransitions checks that every status and resolution named in the
+// `transitions`, `orphans` and `task-lists` configuration parameters exists on
+// the JIRA server.
+func (c Config) checkTransitions(client jira.Client) error {
+	rules := c.transitions[:len(c.transitions):len(c.transitions)]
+	if rule, ok := c.GetOrphanTransitionRule(); ok {
+		rules = append(rules, rule)
+	}
+	if c.IsTaskListSyncEnabled() {
+		rules = append(rules, c.GetTaskDoneRule())
+		if rule, ok := c.GetTaskReopenRule(); ok {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	c.log.Debug("Checking transition statuses and resolutions.")
+
+	req, err := client.NewRequest("GET", "/rest/api/2/status", nil)
+	if err != nil {
+		return err
+	}
+	statuses := new([]jira.Status)
+	if _, err := client.Do(req, statuses); err != nil {
+		return err
+	}
+
+	req, err = client.NewRequest("GET", "/rest/api/2/resolution", nil)
+	if err != nil {
+		return err
+	}
+	resolutions := new([]jira.Resolution)
+	if _, err := client.Do(req, resolutions); err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		// Via may name transitions rather than statuses, so only Status can be checked.
+		found := false
+		for _, status := range *statuses {
+			if strings.EqualFold(status.Name, rule.Status) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("could not find JIRA status %q used in transitions; check that it is named correctly", rule.Status)
+		}
+
+		if rule.Resolution == "" {
+			continue
+		}
+		found = false
+		for _, resolution := range *resolutions {
+			if strings.EqualFold(resolution.Name, rule.Resolution) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("could not find JIRA resolution %q used in transitions; check that it is named correctly", rule.Resolution)
+		}
+	}
+
+	return nil
+}
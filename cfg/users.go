@@ -0,0 +1,325 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// defaultUserCacheTTL is how long the result of looking up a GitHub user in
+// JIRA is reused when no `cache-ttl` is configured.
+const defaultUserCacheTTL = 24 * time.Hour
+
+// defaultProfileCacheTTL is how long the profile of a GitHub user is reused
+// when no `profile-cache-ttl` is configured.
+const defaultProfileCacheTTL = time.Hour
+
+// userConfig is the value of the `users` configuration parameter.
+type userConfig struct {
+	// MappingFile is the path to a JSON file mapping GitHub logins to JIRA
+	// usernames.
+	MappingFile string `json:"mapping-file,omitempty" mapstructure:"mapping-file"`
+	// EmailLookup is true if GitHub users which aren't in the mapping file
+	// should be looked up in JIRA by the public email of their GitHub profile.
+	EmailLookup bool `json:"email-lookup,omitempty" mapstructure:"email-lookup"`
+	// DefaultReporter is the JIRA username used as reporter when the author
+	// of a GitHub issue can't be mapped.
+	DefaultReporter string `json:"default-reporter,omitempty" mapstructure:"default-reporter"`
+	// DefaultAssignee is the JIRA username used as assignee when none of the
+	// assignees of a GitHub issue can be mapped.
+	DefaultAssignee string `json:"default-assignee,omitempty" mapstructure:"default-assignee"`
+	// Mentions is true if @mentions in the bodies of issues and comments
+	// should be converted to JIRA mentions of the users they map to, or to
+	// links to the GitHub profiles of users which can't be mapped.
+	Mentions bool `json:"mentions,omitempty" mapstructure:"mentions"`
+	// CacheFile is the path to the file the results of email lookups are
+	// saved to between runs.
+	CacheFile string `json:"cache-file,omitempty" mapstructure:"cache-file"`
+	// CacheTTL is how long the result of an email lookup is reused.
+	CacheTTL time.Duration `json:"cache-ttl,omitempty" mapstructure:"cache-ttl"`
+	// ProfileCacheFile is the path to the file the profiles of GitHub users
+	// are saved to between runs.
+	ProfileCacheFile string `json:"profile-cache-file,omitempty" mapstructure:"profile-cache-file"`
+	// ProfileCacheTTL is how long the profile of a GitHub user is reused.
+	ProfileCacheTTL time.Duration `json:"profile-cache-ttl,omitempty" mapstructure:"profile-cache-ttl"`
+}
+
+// userDirectory maps GitHub logins to JIRA usernames. It is shared by every
+// copy of the configuration, so lookups are reused across repositories and,
+// in daemon mode, across runs.
+type userDirectory struct {
+	// mapping is the content of the mapping file, keyed by lowercase login.
+	mapping map[string]string
+
+	lock sync.Mutex
+	// cache holds the results of email lookups, keyed by lowercase login.
+	cache map[string]cachedUser
+	// profiles holds the profiles of GitHub users, keyed by lowercase login.
+	profiles map[string]GitHubProfile
+}
+
+// cachedUser is the result of looking up a GitHub user in JIRA.
+type cachedUser struct {
+	// JIRAUser is the JIRA username found, or empty if there was no match.
+	JIRAUser string `json:"jira-user"`
+	// Updated is when the lookup was made.
+	Updated time.Time `json:"updated"`
+}
+
+// GitHubProfile holds the fields of the profile of a GitHub user which are
+// mirrored to JIRA: in the headers of comments, and to map the user to a JIRA
+// user by email.
+type GitHubProfile struct {
+	Login   string `json:"login"`
+	Name    string `json:"name,omitempty"`
+	Email   string `json:"email,omitempty"`
+	HTMLURL string `json:"html-url,omitempty"`
+	// Missing is true if there is no GitHub user with this login.
+	Missing bool `json:"missing,omitempty"`
+	// Updated is when the profile was retrieved.
+	Updated time.Time `json:"updated"`
+}
+
+// IsUserMappingEnabled returns whether GitHub users are mapped to JIRA users
+// to set the reporter and assignee of JIRA issues.
+func (c Config) IsUserMappingEnabled() bool {
+	return c.users.MappingFile != "" || c.users.EmailLookup
+}
+
+// IsEmailLookupEnabled returns whether GitHub users which aren't in the
+// mapping file are looked up in JIRA by email.
+func (c Config) IsEmailLookupEnabled() bool {
+	return c.users.EmailLookup
+}
+
+// IsMentionSyncEnabled returns whether @mentions in the bodies of issues and
+// comments are converted to JIRA mentions or links to GitHub profiles.
+func (c Config) IsMentionSyncEnabled() bool {
+	return c.users.Mentions
+}
+
+// GetDefaultReporter returns the JIRA username used as reporter when the
+// author of a GitHub issue can't be mapped.
+func (c Config) GetDefaultReporter() string {
+	return c.users.DefaultReporter
+}
+
+// GetDefaultAssignee returns the JIRA username used as assignee when none of
+// the assignees of a GitHub issue can be mapped.
+func (c Config) GetDefaultAssignee() string {
+	return c.users.DefaultAssignee
+}
+
+// GetMappedUser returns the JIRA username the mapping file maps a GitHub
+// login to, if any.
+func (c Config) GetMappedUser(login string) (string, bool) {
+	name, ok := c.userDirectory.mapping[strings.ToLower(login)]
+	return name, ok
+}
+
+// GetCachedUser returns the JIRA username a GitHub login was found to match
+// by a previous email lookup, if that lookup hasn't expired. An empty username
+// means the lookup found no match.
+func (c Config) GetCachedUser(login string) (string, bool) {
+	c.userDirectory.lock.Lock()
+	defer c.userDirectory.lock.Unlock()
+
+	user, ok := c.userDirectory.cache[strings.ToLower(login)]
+	if !ok || time.Since(user.Updated) > c.getUserCacheTTL() {
+		return "", false
+	}
+	return user.JIRAUser, true
+}
+
+// SetCachedUser records the result of looking up a GitHub login in JIRA.
+func (c Config) SetCachedUser(login, jiraUser string) {
+	c.userDirectory.lock.Lock()
+	defer c.userDirectory.lock.Unlock()
+
+	c.userDirectory.cache[strings.ToLower(login)] = cachedUser{
+		JIRAUser: jiraUser,
+		Updated:  time.Now(),
+	}
+}
+
+// GetCachedProfile returns the profile of a GitHub user, if it was retrieved
+// recently enough to be reused.
+func (c Config) GetCachedProfile(login string) (GitHubProfile, bool) {
+	c.userDirectory.lock.Lock()
+	defer c.userDirectory.lock.Unlock()
+
+	profile, ok := c.userDirectory.profiles[strings.ToLower(login)]
+	if !ok || time.Since(profile.Updated) > c.getProfileCacheTTL() {
+		return GitHubProfile{}, false
+	}
+	return profile, true
+}
+
+// SetCachedProfile records the profile of a GitHub user, retrieved now.
+func (c Config) SetCachedProfile(profile GitHubProfile) {
+	c.userDirectory.lock.Lock()
+	defer c.userDirectory.lock.Unlock()
+
+	profile.Updated = time.Now()
+	c.userDirectory.profiles[strings.ToLower(profile.Login)] = profile
+}
+
+// SaveUserCache saves the results of email lookups and the profiles of GitHub
+// users to the configured cache files, if there are any. Expired entries are
+// dropped first. The files are only readable by their owner, since they hold
+// email addresses, and are replaced atomically.
+func (c Config) SaveUserCache() error {
+	c.userDirectory.lock.Lock()
+	for login, user := range c.userDirectory.cache {
+		if time.Since(user.Updated) > c.getUserCacheTTL() {
+			delete(c.userDirectory.cache, login)
+		}
+	}
+	for login, profile := range c.userDirectory.profiles {
+		if time.Since(profile.Updated) > c.getProfileCacheTTL() {
+			delete(c.userDirectory.profiles, login)
+		}
+	}
+	c.userDirectory.lock.Unlock()
+
+	if c.users.CacheFile != "" {
+		c.userDirectory.lock.Lock()
+		b, err := json.MarshalIndent(c.userDirectory.cache, "", "  ")
+		c.userDirectory.lock.Unlock()
+		if err != nil {
+			return err
+		}
+
+		if err := writeFileAtomically(c.users.CacheFile, b); err != nil {
+			return err
+		}
+	}
+
+	if c.users.ProfileCacheFile != "" {
+		c.userDirectory.lock.Lock()
+		b, err := json.MarshalIndent(c.userDirectory.profiles, "", "  ")
+		c.userDirectory.lock.Unlock()
+		if err != nil {
+			return err
+		}
+
+		if err := writeFileAtomically(c.users.ProfileCacheFile, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getUserCacheTTL returns how long the result of an email lookup is reused.
+func (c Config) getUserCacheTTL() time.Duration {
+	if c.users.CacheTTL == 0 {
+		return defaultUserCacheTTL
+	}
+	return c.users.CacheTTL
+}
+
+// getProfileCacheTTL returns how long the profile of a GitHub user is reused.
+func (c Config) getProfileCacheTTL() time.Duration {
+	if c.users.ProfileCacheTTL == 0 {
+		return defaultProfileCacheTTL
+	}
+	return c.users.ProfileCacheTTL
+}
+
+// validateUsers checks the values of the `users` configuration parameter,
+// and loads the mapping file and the cache files.
+func (c *Config) validateUsers() error {
+	if err := c.cmdConfig.UnmarshalKey("users", &c.users); err != nil {
+		return fmt.Errorf("Users must be an object: %v", err)
+	}
+
+	if c.users.CacheTTL < 0 {
+		return fmt.Errorf("User cache TTL must be positive; got %v", c.users.CacheTTL)
+	}
+
+	if c.users.ProfileCacheTTL < 0 {
+		return fmt.Errorf("Profile cache TTL must be positive; got %v", c.users.ProfileCacheTTL)
+	}
+
+	c.userDirectory = &userDirectory{
+		mapping:  map[string]string{},
+		cache:    map[string]cachedUser{},
+		profiles: map[string]GitHubProfile{},
+	}
+
+	if c.users.MappingFile != "" {
+		b, err := ioutil.ReadFile(c.users.MappingFile)
+		if err != nil {
+			return fmt.Errorf("Error reading user mapping file: %v", err)
+		}
+		mapping := map[string]string{}
+		if err := json.Unmarshal(b, &mapping); err != nil {
+			return fmt.Errorf("User mapping file must be an object mapping GitHub logins to JIRA usernames: %v", err)
+		}
+		for login, name := range mapping {
+			c.userDirectory.mapping[strings.ToLower(login)] = name
+		}
+	}
+
+	if c.users.CacheFile != "" {
+		b, err := ioutil.ReadFile(c.users.CacheFile)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Error reading user cache file: %v", err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(b, &c.userDirectory.cache); err != nil {
+				c.log.Warnf("Ignoring invalid user cache file %s: %v", c.users.CacheFile, err)
+			}
+			if c.userDirectory.cache == nil {
+				c.userDirectory.cache = map[string]cachedUser{}
+			}
+		}
+	}
+
+	if c.users.ProfileCacheFile != "" {
+		b, err := ioutil.ReadFile(c.users.ProfileCacheFile)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("Error reading profile cache file: %v", err)
+		}
+		if err == nil {
+			if err := json.Unmarshal(b, &c.userDirectory.profiles); err != nil {
+				c.log.Warnf("Ignoring invalid profile cache file %s: %v", c.users.ProfileCacheFile, err)
+			}
+			if c.userDirectory.profiles == nil {
+				c.userDirectory.profiles = map[string]GitHubProfile{}
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkUsers checks that the default reporter and assignee exist in JIRA.
+func (c Config) checkUsers(client jira.Client) error {
+	for _, name := range []string{c.users.DefaultReporter, c.users.DefaultAssignee} {
+		if name == "" {
+			continue
+		}
+
+		req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/user?username=%s", url.QueryEscape(name)), nil)
+		if err != nil {
+			return err
+		}
+		if res, err := client.Do(req, nil); err != nil {
+			if res != nil && res.StatusCode == 404 {
+				return fmt.Errorf("could not find JIRA user %q; check that it is named correctly", name)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
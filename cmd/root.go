@@ -42,6 +42,9 @@ var RootCmd = &cobra.Command{
 		}
 
 		for {
+			if err := lib.DiscoverRepos(&config, ghClient); err != nil {
+				log.Error(err)
+			}
 			if err := lib.CompareIssues(config, ghClient, jiraClient); err != nil {
 				log.Error(err)
 			}
@@ -49,6 +52,15 @@ var RootCmd = &cobra.Command{
 				if err := config.SaveConfig(); err != nil {
 					log.Error(err)
 				}
+				if err := config.SaveUserCache(); err != nil {
+					log.Error(err)
+				}
+				if err := config.SaveAttachmentRecord(); err != nil {
+					log.Error(err)
+				}
+				if err := config.SaveState(); err != nil {
+					log.Error(err)
+				}
 			}
 			if !config.IsDaemon() {
 				return nil
@@ -71,4 +83,6 @@ func init() {
 	RootCmd.PersistentFlags().BoolP("dry-run", "d", false, "Print out actions to be taken, but do not execute them")
 	RootCmd.PersistentFlags().DurationP("timeout", "T", time.Minute, "Set the maximum timeout on all API calls")
 	RootCmd.PersistentFlags().Duration("period", 1*time.Hour, "How often to synchronize; set to 0 for one-shot mode")
+	RootCmd.PersistentFlags().Bool("rebuild-state", false, "Rebuild the state file from JIRA before synchronizing")
+	RootCmd.PersistentFlags().Bool("compare-all", false, "Compare every issue and comment to JIRA, even if it hasn't changed since it was last mirrored")
 }
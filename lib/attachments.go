@@ -0,0 +1,109 @@
+package lib
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// attachmentURLRegex matches the URLs of files uploaded to GitHub issues and
+// comments: images, and other files attached to the issue.
+var attachmentURLRegex = regexp.MustCompile(`https://(?:(?:private-)?user-images\.githubusercontent\.com/\d+/[^\s)"'<>\]]+|github\.com/user-attachments/(?:assets|files)/[^\s)"'<>\]]+|github\.com/[\w.-]+/[\w.-]+/files/\d+/[^\s)"'<>\]]+)`)
+
+// attachmentExtensions are the extensions given to the files whose URL has none,
+// by content type. Other content types are looked up with the mime package.
+var attachmentExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/svg+xml":   ".svg",
+	"video/mp4":       ".mp4",
+	"text/plain":      ".txt",
+	"application/zip": ".zip",
+}
+
+// MirrorAttachments downloads the files uploaded to GitHub which are referenced
+// in body, and uploads them as attachments of the JIRA issue, so that jiraBody
+// can link to them. Files which were already mirrored to the issue are skipped,
+// as are files larger than the configured maximum size. A file which can't be
+// downloaded is skipped until the next run, and the link to GitHub is kept.
+func MirrorAttachments(config cfg.Config, jIssue jira.Issue, body string, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	if !config.IsAttachmentSyncEnabled() || jIssue.Key == "" {
+		return nil
+	}
+
+	existing := map[string]bool{}
+	if jIssue.Fields != nil {
+		for _, a := range jIssue.Fields.Attachments {
+			existing[a.Filename] = true
+		}
+	}
+
+	for _, url := range attachmentURLRegex.FindAllString(body, -1) {
+		if _, ok := config.GetMirroredAttachment(jIssue.Key, url); ok {
+			continue
+		}
+
+		file, err := ghClient.DownloadAttachment(url, config.GetAttachmentMaxSize())
+		if err == clients.ErrAttachmentTooLarge {
+			log.Warnf("Not mirroring %s to JIRA issue %s: it is larger than %d bytes", url, jIssue.Key, config.GetAttachmentMaxSize())
+			config.SetMirroredAttachment(jIssue.Key, url, "")
+			continue
+		}
+		if err != nil {
+			log.Warnf("Could not download %s; it will not be mirrored to JIRA issue %s", url, jIssue.Key)
+			continue
+		}
+
+		name := attachmentName(url, file.ContentType)
+		if existing[name] {
+			log.Debugf("JIRA issue %s already has an attachment named %s", jIssue.Key, name)
+			config.SetMirroredAttachment(jIssue.Key, url, name)
+			continue
+		}
+
+		if _, err := jClient.AddAttachment(jIssue, name, file.Data); err != nil {
+			return err
+		}
+
+		log.Debugf("Mirrored %s to JIRA issue %s as %s", url, jIssue.Key, name)
+
+		existing[name] = true
+		config.SetMirroredAttachment(jIssue.Key, url, name)
+	}
+
+	return nil
+}
+
+// attachmentName returns the name a file uploaded to GitHub is attached to a
+// JIRA issue as. Files attached to an issue keep their name, prefixed with
+// their ID so that names are unique; images are named after their ID. If the
+// name has no extension, one is derived from the content type.
+func attachmentName(url, contentType string) string {
+	url = strings.SplitN(url, "?", 2)[0]
+	name := path.Base(url)
+	if dir := path.Base(path.Dir(url)); path.Base(path.Dir(path.Dir(url))) == "files" {
+		name = fmt.Sprintf("%s-%s", dir, name)
+	}
+
+	if path.Ext(name) != "" {
+		return name
+	}
+
+	contentType = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	if ext, ok := attachmentExtensions[contentType]; ok {
+		return name + ext
+	}
+	if exts, err := mime.ExtensionsByType(contentType); err == nil && len(exts) > 0 {
+		return name + exts[0]
+	}
+	return name
+}
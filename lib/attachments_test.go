@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAttachmentRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A record file holding null must load as an empty record.
+	file := filepath.Join(dir, "attachments.json")
+	if err := ioutil.WriteFile(file, []byte("null"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := newTestConfig(t, map[string]interface{}{
+		"attachments": map[string]interface{}{"sync": true, "record-file": file},
+	}, nil)
+
+	url := "https://user-images.githubusercontent.com/1/screenshot.png"
+	if _, ok := config.GetMirroredAttachment("SYNC-1", url); ok {
+		t.Errorf("file is recorded before being mirrored")
+	}
+	config.SetMirroredAttachment("SYNC-1", url, "screenshot.png")
+	if name, ok := config.GetMirroredAttachment("SYNC-1", url); !ok || name != "screenshot.png" {
+		t.Errorf("got recorded attachment %q, %t; want screenshot.png", name, ok)
+	}
+
+	if err := config.SaveAttachmentRecord(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]map[string]string
+	if err := json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]string{"SYNC-1": {url: "screenshot.png"}}
+	if !reflect.DeepEqual(saved, want) {
+		t.Errorf("got saved record %v; want %v", saved, want)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("got %d files in the directory; want only the record", len(files))
+	}
+}
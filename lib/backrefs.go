@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// WriteBackReference writes the key of the JIRA issue mirroring a GitHub issue
+// back to the GitHub issue, as a comment, a label or text appended to its body,
+// unless the GitHub issue already has it.
+func WriteBackReference(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient clients.GitHubClient) error {
+	log := config.GetLogger()
+
+	ref, err := config.GetBackReference(jIssue.Key)
+	if err != nil {
+		return err
+	}
+
+	owner, repo := config.GetRepo()
+	number := ghIssue.GetNumber()
+
+	switch config.GetBackReferenceMode() {
+	case cfg.BackReferenceComment:
+		if ghIssue.GetComments() > 0 {
+			comments, err := ghClient.ListComments(owner, repo, ghIssue, time.Time{})
+			if err != nil {
+				return err
+			}
+			for _, c := range comments {
+				if strings.Contains(c.GetBody(), ref) {
+					log.Debugf("GitHub issue #%d already has a comment referencing %s", number, jIssue.Key)
+					return nil
+				}
+			}
+		}
+		err = ghClient.CreateComment(owner, repo, number, ref)
+	case cfg.BackReferenceLabel:
+		for _, l := range ghIssue.Labels {
+			if strings.EqualFold(l.GetName(), ref) {
+				log.Debugf("GitHub issue #%d already has label %s", number, ref)
+				return nil
+			}
+		}
+		err = ghClient.AddLabel(owner, repo, number, ref)
+	case cfg.BackReferenceBody:
+		if strings.Contains(ghIssue.GetBody(), ref) {
+			log.Debugf("Body of GitHub issue #%d already references %s", number, jIssue.Key)
+			return nil
+		}
+		body := strings.TrimRight(ghIssue.GetBody(), "\r\n")
+		if body != "" {
+			body += "\n\n"
+		}
+		err = ghClient.EditBody(owner, repo, number, body+ref)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Wrote back-reference to JIRA issue %s on GitHub issue #%d", jIssue.Key, number)
+
+	return nil
+}
+
+// writePendingBackReference writes the back-reference to a JIRA issue on the
+// GitHub issue it mirrors if the run which created the JIRA issue failed
+// before writing it, as recorded in the state.
+func writePendingBackReference(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient clients.GitHubClient) error {
+	if !config.IsBackReferencePending(ghIssue.GetID()) {
+		return nil
+	}
+	if err := WriteBackReference(config, ghIssue, jIssue, ghClient); err != nil {
+		return err
+	}
+	config.SetBackReferencePending(ghIssue.GetID(), false)
+	return nil
+}
+
+// isBackReferenceComment returns whether a GitHub comment is the
+// back-reference to the JIRA issue with the given key, which isn't mirrored.
+func isBackReferenceComment(config cfg.Config, comment github.IssueComment, key string) bool {
+	if config.GetBackReferenceMode() != cfg.BackReferenceComment {
+		return false
+	}
+	ref, err := config.GetBackReference(key)
+	return err == nil && strings.TrimSpace(comment.GetBody()) == ref
+}
+
+// stripBackReference removes the back-reference to the JIRA issue with the
+// given key from the body of a GitHub issue, so that it isn't mirrored.
+func stripBackReference(config cfg.Config, body, key string) string {
+	if config.GetBackReferenceMode() != cfg.BackReferenceBody {
+		return body
+	}
+	ref, err := config.GetBackReference(key)
+	if err != nil {
+		return body
+	}
+	return strings.TrimRight(strings.Replace(body, ref, "", 1), "\r\n")
+}
@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestWritePendingBackReference(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{
+		"state":          map[string]string{"file": testStateFile(t)},
+		"back-reference": map[string]string{"mode": "label"},
+	}, nil)
+
+	tests := []struct {
+		name    string
+		pending bool
+		want    []string
+	}{
+		{"pending", true, []string{"AddLabel coreos/issue-sync#1 jira:SYNC-1"}},
+		{"written", false, nil},
+	}
+
+	for _, test := range tests {
+		config.SetMirroredIssue(1, "SYNC-1", "")
+		config.SetBackReferencePending(1, test.pending)
+		ghIssue := github.Issue{ID: github.Int(1), Number: github.Int(1)}
+		gh := &fakeGHClient{}
+
+		// The second run finds the back-reference written.
+		for run := 0; run < 2; run++ {
+			if err := writePendingBackReference(config, ghIssue, testJIRAIssue(config, "SYNC-1", 1), gh); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+		}
+
+		if !reflect.DeepEqual(gh.calls, test.want) {
+			t.Errorf("%s: got GitHub calls %q; want %q", test.name, gh.calls, test.want)
+		}
+		if config.IsBackReferencePending(1) {
+			t.Errorf("%s: back-reference still pending", test.name)
+		}
+	}
+}
@@ -2,8 +2,13 @@ package clients
 
 import (
 	"context"
+	"errors"
 	"fmt"
-
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff"
@@ -16,36 +21,123 @@ import (
 // use. It allows us to swap in other implementations, such as a dry run
 // clients, or mock clients for testing.
 type GitHubClient interface {
-	ListIssues() ([]github.Issue, error)
-	ListComments(issue github.Issue) ([]*github.IssueComment, error)
+	ListIssues(owner, repo string, since time.Time) ([]github.Issue, error)
+	ListComments(owner, repo string, issue github.Issue, since time.Time) ([]*github.IssueComment, error)
+	ListHiddenComments(owner, repo string, number int) ([]int, error)
+	ListTimeline(owner, repo string, number int) ([]TimelineEvent, error)
+	ListRepositories(owner string, user bool) ([]Repository, error)
+	GetIssue(owner, repo string, number int) (github.Issue, error)
+	GetStateReason(owner, repo string, number int) (string, error)
+	GetPullRequest(owner, repo string, number int) (github.PullRequest, error)
+	ListReviews(owner, repo string, number int) ([]github.PullRequestReview, error)
+	ListMilestones(owner, repo string) ([]github.Milestone, error)
 	GetUser(login string) (github.User, error)
+	DownloadAttachment(url string, maxSize int64) (Attachment, error)
 	GetRateLimits() (github.RateLimits, error)
+	CreateComment(owner, repo string, number int, body string) error
+	AddLabel(owner, repo string, number int, label string) error
+	EditBody(owner, repo string, number int, body string) error
+}
+
+// Repository is a GitHub repository, along with the fields the GitHub
+// API library we use doesn't know about yet.
+type Repository struct {
+	github.Repository
+	Archived *bool    `json:"archived,omitempty"`
+	Topics   []string `json:"topics,omitempty"`
+}
+
+// Attachment is a file uploaded to GitHub, such as an image embedded in the
+// body of an issue.
+type Attachment struct {
+	Data        []byte
+	ContentType string
 }
 
+// ErrAttachmentTooLarge is returned by DownloadAttachment when the file is
+// larger than the maximum size.
+var ErrAttachmentTooLarge = errors.New("attachment is too large")
+
+// ErrIssueDeleted is returned by GetIssue when the issue was deleted.
+var ErrIssueDeleted = errors.New("issue was deleted")
+
+// ErrIssueNotFound is returned by GetIssue when the issue doesn't exist, or
+// the user can't see it.
+var ErrIssueNotFound = errors.New("issue not found")
+
+// ErrUserNotFound is returned by GetUser when there is no user with the login.
+var ErrUserNotFound = errors.New("user not found")
+
+// TimelineEvent is an event of the timeline of a GitHub issue. Unlike
+// github.Timeline, it includes the issue or pull request a cross-reference
+// comes from, and the reason an issue was closed.
+type TimelineEvent struct {
+	ID          int          `json:"id,omitempty"`
+	Event       string       `json:"event"`
+	Actor       *github.User `json:"actor,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	CommitID    string       `json:"commit_id,omitempty"`
+	StateReason string       `json:"state_reason,omitempty"`
+	Label       *struct {
+		Name string `json:"name"`
+	} `json:"label,omitempty"`
+	Assignee  *github.User `json:"assignee,omitempty"`
+	Milestone *struct {
+		Title string `json:"title"`
+	} `json:"milestone,omitempty"`
+	Rename *struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"rename,omitempty"`
+	Source *struct {
+		Issue *struct {
+			Number      int                      `json:"number"`
+			Title       string                   `json:"title"`
+			HTMLURL     string                   `json:"html_url"`
+			PullRequest *github.PullRequestLinks `json:"pull_request,omitempty"`
+			Repository  *struct {
+				FullName string `json:"full_name"`
+			} `json:"repository,omitempty"`
+		} `json:"issue,omitempty"`
+	} `json:"source,omitempty"`
+}
+
+// mediaTypeTimelinePreview is the media type required to retrieve the
+// timeline of an issue from the GitHub API.
+const mediaTypeTimelinePreview = "application/vnd.github.mockingbird-preview+json"
+
+// mediaTypeTopicsPreview is the media type required to retrieve the
+// topics of a repository from the GitHub API.
+const mediaTypeTopicsPreview = "application/vnd.github.mercy-preview+json"
+
 // realGHClient is a standard GitHub clients, that actually makes all of the
 // requests against the GitHub REST API. It is the canonical implementation
 // of GitHubClient.
 type realGHClient struct {
-	config cfg.Config
-	client github.Client
+	config  cfg.Config
+	client  *github.Client
+	limiter *limiter
+	// downloads is the client attachments are downloaded with. Unlike
+	// client, it doesn't add the GitHub token to every request.
+	downloads *http.Client
 }
 
-// ListIssues returns the list of GitHub issues since the last run of the tool.
-func (g realGHClient) ListIssues() ([]github.Issue, error) {
+// ListIssues returns the list of issues on the GitHub repository owner/repo
+// which have been updated since the provided time. Pull requests are only
+// included if they are synchronized.
+func (g realGHClient) ListIssues(owner, repo string, since time.Time) ([]github.Issue, error) {
 	log := g.config.GetLogger()
 
 	ctx := context.Background()
 
-	user, repo := g.config.GetRepo()
-
 	// Set it so that it will run the loop once, and it'll be updated in the loop.
 	pages := 1
 	var issues []github.Issue
 
 	for page := 1; page <= pages; page++ {
 		is, res, err := g.request(func() (interface{}, *github.Response, error) {
-			return g.client.Issues.ListByRepo(ctx, user, repo, &github.IssueListByRepoOptions{
-				Since:     g.config.GetSinceParam(),
+			return g.client.Issues.ListByRepo(ctx, owner, repo, &github.IssueListByRepoOptions{
+				Since:     since,
 				State:     "all",
 				Sort:      "created",
 				Direction: "asc",
@@ -67,7 +159,7 @@ func (g realGHClient) ListIssues() ([]github.Issue, error) {
 		var issuePage []github.Issue
 		for _, v := range issuePointers {
 			// If PullRequestLinks is not nil, it's a Pull Request
-			if v.PullRequestLinks == nil {
+			if v.PullRequestLinks == nil || g.config.IsPullRequestSyncEnabled() {
 				issuePage = append(issuePage, *v)
 			}
 		}
@@ -76,47 +168,434 @@ func (g realGHClient) ListIssues() ([]github.Issue, error) {
 		issues = append(issues, issuePage...)
 	}
 
-	log.Debug("Collected all GitHub issues")
+	log.Debugf("Collected all GitHub issues from %s/%s", owner, repo)
 
 	return issues, nil
 }
 
-// ListComments returns the list of all comments on a GitHub issue in
-// ascending order of creation.
-func (g realGHClient) ListComments(issue github.Issue) ([]*github.IssueComment, error) {
+// ListComments returns the list of comments on a GitHub issue of the
+// repository owner/repo which have been updated since the provided time, in
+// ascending order of creation. Every comment is returned if since is zero.
+func (g realGHClient) ListComments(owner, repo string, issue github.Issue, since time.Time) ([]*github.IssueComment, error) {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	// Set it so that it will run the loop once, and it'll be updated in the loop.
+	pages := 1
+	var comments []*github.IssueComment
+
+	for page := 1; page <= pages; page++ {
+		c, res, err := g.request(func() (interface{}, *github.Response, error) {
+			return g.client.Issues.ListComments(ctx, owner, repo, issue.GetNumber(), &github.IssueListCommentsOptions{
+				Sort:      "created",
+				Direction: "asc",
+				Since:     since,
+				ListOptions: github.ListOptions{
+					Page:    page,
+					PerPage: 100,
+				},
+			})
+		})
+		if err != nil {
+			log.Errorf("Error retrieving GitHub comments for issue #%d. Error: %v.", issue.GetNumber(), err)
+			return nil, err
+		}
+		commentPage, ok := c.([]*github.IssueComment)
+		if !ok {
+			log.Errorf("Get GitHub comments did not return comments! Got: %v", c)
+			return nil, fmt.Errorf("Get GitHub comments failed: expected []*github.IssueComment; got %T", c)
+		}
+
+		pages = res.LastPage
+		comments = append(comments, commentPage...)
+	}
+
+	return comments, nil
+}
+
+// hiddenCommentsQuery is the GraphQL query listing the comments of an issue or
+// pull request along with whether they are hidden. The REST API doesn't tell.
+const hiddenCommentsQuery = `query($owner: String!, $name: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    issueOrPullRequest(number: $number) {
+      ... on Issue { comments(first: 100, after: $cursor) { ...comments } }
+      ... on PullRequest { comments(first: 100, after: $cursor) { ...comments } }
+    }
+  }
+}
+fragment comments on IssueCommentConnection {
+  nodes { databaseId isMinimized }
+  pageInfo { hasNextPage endCursor }
+}`
+
+// hiddenCommentsResponse is the response to hiddenCommentsQuery.
+type hiddenCommentsResponse struct {
+	Data struct {
+		Repository struct {
+			IssueOrPullRequest struct {
+				Comments struct {
+					Nodes []struct {
+						DatabaseID  int  `json:"databaseId"`
+						IsMinimized bool `json:"isMinimized"`
+					} `json:"nodes"`
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+				} `json:"comments"`
+			} `json:"issueOrPullRequest"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// ListHiddenComments returns the IDs of the comments on an issue of the GitHub
+// repository owner/repo which were hidden (minimized), e.g. as spam.
+func (g realGHClient) ListHiddenComments(owner, repo string, number int) ([]int, error) {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	var ids []int
+	var cursor *string
+	for {
+		payload := map[string]interface{}{
+			"query": hiddenCommentsQuery,
+			"variables": map[string]interface{}{
+				"owner":  owner,
+				"name":   repo,
+				"number": number,
+				"cursor": cursor,
+			},
+		}
+
+		var response hiddenCommentsResponse
+		_, _, err := g.request(func() (interface{}, *github.Response, error) {
+			req, err := g.client.NewRequest("POST", graphQLURL(g.client.BaseURL), payload)
+			if err != nil {
+				return nil, nil, err
+			}
+			response = hiddenCommentsResponse{}
+			res, err := g.client.Do(ctx, req, &response)
+			return nil, res, err
+		})
+		if err != nil {
+			log.Errorf("Error listing hidden comments of GitHub issue #%d. Error: %v", number, err)
+			return nil, err
+		}
+		if len(response.Errors) > 0 {
+			log.Errorf("Error listing hidden comments of GitHub issue #%d. Error: %s", number, response.Errors[0].Message)
+			return nil, fmt.Errorf("list hidden GitHub comments failed: %s", response.Errors[0].Message)
+		}
+
+		comments := response.Data.Repository.IssueOrPullRequest.Comments
+		for _, c := range comments.Nodes {
+			if c.IsMinimized {
+				ids = append(ids, c.DatabaseID)
+			}
+		}
+
+		if !comments.PageInfo.HasNextPage {
+			break
+		}
+		cursor = &comments.PageInfo.EndCursor
+	}
+
+	return ids, nil
+}
+
+// graphQLURL returns the URL of the GraphQL API of the GitHub server whose
+// REST API is at base. GitHub Enterprise serves its REST API under /api/v3/
+// and its GraphQL API at /api/graphql; github.com serves both at the root.
+func graphQLURL(base *url.URL) string {
+	u := *base
+	if p := strings.TrimSuffix(u.Path, "/"); strings.HasSuffix(p, "/api/v3") {
+		u.Path = strings.TrimSuffix(p, "/v3") + "/graphql"
+	} else {
+		u.Path = "/graphql"
+	}
+	return u.String()
+}
+
+// ListTimeline returns every event of the timeline of an issue of the GitHub
+// repository owner/repo, in chronological order.
+func (g realGHClient) ListTimeline(owner, repo string, number int) ([]TimelineEvent, error) {
 	log := g.config.GetLogger()
 
 	ctx := context.Background()
-	user, repo := g.config.GetRepo()
-	c, _, err := g.request(func() (interface{}, *github.Response, error) {
-		return g.client.Issues.ListComments(ctx, user, repo, issue.GetNumber(), &github.IssueListCommentsOptions{
-			Sort:      "created",
-			Direction: "asc",
+
+	// Set it so that it will run the loop once, and it'll be updated in the loop.
+	pages := 1
+	var events []TimelineEvent
+
+	for page := 1; page <= pages; page++ {
+		var eventPage []TimelineEvent
+		_, res, err := g.request(func() (interface{}, *github.Response, error) {
+			u := fmt.Sprintf("repos/%s/%s/issues/%d/timeline?page=%d&per_page=100", owner, repo, number, page)
+			req, err := g.client.NewRequest("GET", u, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			req.Header.Set("Accept", mediaTypeTimelinePreview)
+			eventPage = nil
+			res, err := g.client.Do(ctx, req, &eventPage)
+			return nil, res, err
 		})
+		if err != nil {
+			log.Errorf("Error retrieving timeline of GitHub issue #%d. Error: %v", number, err)
+			return nil, err
+		}
+
+		events = append(events, eventPage...)
+		pages = res.LastPage
+	}
+
+	return events, nil
+}
+
+// ListRepositories returns every repository owned by the GitHub organization
+// (or user, if user is true) `owner` which the token has access to.
+func (g realGHClient) ListRepositories(owner string, user bool) ([]Repository, error) {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	u := fmt.Sprintf("orgs/%s/repos?type=all", owner)
+	if user {
+		u = fmt.Sprintf("users/%s/repos?type=owner", owner)
+	}
+
+	// Set it so that it will run the loop once, and it'll be updated in the loop.
+	pages := 1
+	var repos []Repository
+
+	for page := 1; page <= pages; page++ {
+		var repoPage []Repository
+		_, res, err := g.request(func() (interface{}, *github.Response, error) {
+			req, err := g.client.NewRequest("GET", fmt.Sprintf("%s&per_page=100&page=%d", u, page), nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			req.Header.Set("Accept", mediaTypeTopicsPreview)
+
+			repoPage = nil
+			res, err := g.client.Do(ctx, req, &repoPage)
+			return repoPage, res, err
+		})
+		if err != nil {
+			log.Errorf("Error listing GitHub repositories of %s. Error: %v", owner, err)
+			return nil, err
+		}
+
+		pages = res.LastPage
+		repos = append(repos, repoPage...)
+	}
+
+	log.Debugf("Collected %d GitHub repositories from %s", len(repos), owner)
+
+	return repos, nil
+}
+
+// GetIssue returns a single issue of the GitHub repository owner/repo. If the
+// issue was transferred to another repository, the issue in that repository is
+// returned. If the issue was deleted, it returns ErrIssueDeleted, and if it
+// can't be found, e.g. because it was converted to a discussion, ErrIssueNotFound.
+func (g realGHClient) GetIssue(owner, repo string, number int) (github.Issue, error) {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	var gone error
+	i, _, err := g.request(func() (interface{}, *github.Response, error) {
+		gone = nil
+		i, res, err := g.client.Issues.Get(ctx, owner, repo, number)
+		// Retrying would not help; don't return an error here.
+		if res != nil && res.StatusCode == http.StatusGone {
+			gone = ErrIssueDeleted
+			return nil, res, nil
+		}
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			gone = ErrIssueNotFound
+			return nil, res, nil
+		}
+		return i, res, err
 	})
 	if err != nil {
-		log.Errorf("Error retrieving GitHub comments for issue #%d. Error: %v.", issue.GetNumber(), err)
-		return nil, err
+		log.Errorf("Error retrieving GitHub issue #%d. Error: %v", number, err)
+		return github.Issue{}, err
+	}
+	if gone != nil {
+		return github.Issue{}, gone
 	}
-	comments, ok := c.([]*github.IssueComment)
+	issue, ok := i.(*github.Issue)
 	if !ok {
-		log.Errorf("Get GitHub comments did not return comments! Got: %v", c)
-		return nil, fmt.Errorf("Get GitHub comments failed: expected []*github.IssueComment; got %T", c)
+		log.Errorf("Get GitHub issue did not return issue! Got: %v", i)
+		return github.Issue{}, fmt.Errorf("get GitHub issue failed: expected *github.Issue; got %T", i)
 	}
 
-	return comments, nil
+	return *issue, nil
+}
+
+// GetStateReason returns the reason a GitHub issue of the repository owner/repo
+// is in its current state: "completed" or "not_planned" for closed issues,
+// "reopened" for reopened issues, and the empty string otherwise. The GitHub
+// API library we use doesn't know about this field, so the issue is requested
+// separately.
+func (g realGHClient) GetStateReason(owner, repo string, number int) (string, error) {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	issue := struct {
+		StateReason *string `json:"state_reason"`
+	}{}
+	_, _, err := g.request(func() (interface{}, *github.Response, error) {
+		req, err := g.client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/issues/%d", owner, repo, number), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := g.client.Do(ctx, req, &issue)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error retrieving state reason of GitHub issue #%d. Error: %v", number, err)
+		return "", err
+	}
+
+	if issue.StateReason == nil {
+		return "", nil
+	}
+	return *issue.StateReason, nil
 }
 
-// GetUser returns a GitHub user from its login.
+// GetPullRequest returns a single pull request of the GitHub repository owner/repo.
+func (g realGHClient) GetPullRequest(owner, repo string, number int) (github.PullRequest, error) {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	p, _, err := g.request(func() (interface{}, *github.Response, error) {
+		return g.client.PullRequests.Get(ctx, owner, repo, number)
+	})
+	if err != nil {
+		log.Errorf("Error retrieving GitHub pull request #%d. Error: %v", number, err)
+		return github.PullRequest{}, err
+	}
+	pull, ok := p.(*github.PullRequest)
+	if !ok {
+		log.Errorf("Get GitHub pull request did not return pull request! Got: %v", p)
+		return github.PullRequest{}, fmt.Errorf("get GitHub pull request failed: expected *github.PullRequest; got %T", p)
+	}
+
+	return *pull, nil
+}
+
+// ListReviews returns every review of a pull request of the GitHub repository
+// owner/repo, in the order they were submitted.
+func (g realGHClient) ListReviews(owner, repo string, number int) ([]github.PullRequestReview, error) {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	// Set it so that it will run the loop once, and it'll be updated in the loop.
+	pages := 1
+	var reviews []github.PullRequestReview
+
+	for page := 1; page <= pages; page++ {
+		rs, res, err := g.request(func() (interface{}, *github.Response, error) {
+			return g.client.PullRequests.ListReviews(ctx, owner, repo, number, &github.ListOptions{
+				Page:    page,
+				PerPage: 100,
+			})
+		})
+		if err != nil {
+			log.Errorf("Error listing reviews of GitHub pull request #%d. Error: %v", number, err)
+			return nil, err
+		}
+		reviewPointers, ok := rs.([]*github.PullRequestReview)
+		if !ok {
+			log.Errorf("Get GitHub reviews did not return reviews! Got: %v", rs)
+			return nil, fmt.Errorf("get GitHub reviews failed: expected []*github.PullRequestReview; got %T", rs)
+		}
+
+		for _, v := range reviewPointers {
+			reviews = append(reviews, *v)
+		}
+
+		pages = res.LastPage
+	}
+
+	return reviews, nil
+}
+
+// ListMilestones returns every milestone, open or closed, of the GitHub
+// repository owner/repo.
+func (g realGHClient) ListMilestones(owner, repo string) ([]github.Milestone, error) {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	// Set it so that it will run the loop once, and it'll be updated in the loop.
+	pages := 1
+	var milestones []github.Milestone
+
+	for page := 1; page <= pages; page++ {
+		ms, res, err := g.request(func() (interface{}, *github.Response, error) {
+			return g.client.Issues.ListMilestones(ctx, owner, repo, &github.MilestoneListOptions{
+				State: "all",
+				ListOptions: github.ListOptions{
+					Page:    page,
+					PerPage: 100,
+				},
+			})
+		})
+		if err != nil {
+			log.Errorf("Error listing GitHub milestones of %s/%s. Error: %v", owner, repo, err)
+			return nil, err
+		}
+		milestonePointers, ok := ms.([]*github.Milestone)
+		if !ok {
+			log.Errorf("Get GitHub milestones did not return milestones! Got: %v", ms)
+			return nil, fmt.Errorf("get GitHub milestones failed: expected []*github.Milestone; got %T", ms)
+		}
+
+		for _, v := range milestonePointers {
+			milestones = append(milestones, *v)
+		}
+
+		pages = res.LastPage
+	}
+
+	log.Debugf("Collected %d GitHub milestones from %s/%s", len(milestones), owner, repo)
+
+	return milestones, nil
+}
+
+// GetUser returns a GitHub user from its login. If there is no such user, it
+// returns ErrUserNotFound.
 func (g realGHClient) GetUser(login string) (github.User, error) {
 	log := g.config.GetLogger()
 
+	var missing bool
 	u, _, err := g.request(func() (interface{}, *github.Response, error) {
-		return g.client.Users.Get(context.Background(), login)
+		missing = false
+		u, res, err := g.client.Users.Get(context.Background(), login)
+		// Retrying would not help; don't return an error here.
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			missing = true
+			return nil, res, nil
+		}
+		return u, res, err
 	})
-
 	if err != nil {
 		log.Errorf("Error retrieving GitHub user %s. Error: %v", login, err)
+		return github.User{}, err
+	}
+	if missing {
+		return github.User{}, ErrUserNotFound
 	}
 
 	user, ok := u.(*github.User)
@@ -128,6 +607,66 @@ func (g realGHClient) GetUser(login string) (github.User, error) {
 	return *user, nil
 }
 
+// githubHost is the host GitHub attachments are served from when they
+// require the GitHub token; they may redirect to other hosts.
+const githubHost = "github.com"
+
+// DownloadAttachment downloads a file uploaded to GitHub, as long as it is no
+// larger than maxSize bytes; otherwise, it returns ErrAttachmentTooLarge.
+// The GitHub token is only sent to github.com, and is dropped when the
+// download is redirected to the host the file is stored on.
+func (g realGHClient) DownloadAttachment(url string, maxSize int64) (Attachment, error) {
+	log := g.config.GetLogger()
+
+	var data []byte
+	var tooLarge bool
+	_, res, err := g.request(func() (interface{}, *github.Response, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Accept", "*/*")
+		if req.URL.Host == githubHost {
+			req.Header.Set("Authorization", "token "+g.config.GetConfigString("github-token"))
+		}
+
+		// The http package doesn't forward the Authorization header on
+		// redirects to other domains.
+		r, err := g.downloads.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer r.Body.Close()
+		res := &github.Response{Response: r}
+		if r.StatusCode != http.StatusOK {
+			return nil, res, fmt.Errorf("GET %s: %s", url, r.Status)
+		}
+
+		// Retrying would not help if the file is too large; don't return
+		// an error then. One byte past the limit is read to tell whether
+		// it is exceeded.
+		tooLarge = r.ContentLength > maxSize
+		if tooLarge {
+			return nil, res, nil
+		}
+		data, err = ioutil.ReadAll(io.LimitReader(r.Body, maxSize+1))
+		tooLarge = int64(len(data)) > maxSize
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error downloading GitHub attachment %s. Error: %v", url, err)
+		return Attachment{}, err
+	}
+	if tooLarge {
+		return Attachment{}, ErrAttachmentTooLarge
+	}
+
+	return Attachment{
+		Data:        data,
+		ContentType: res.Header.Get("Content-Type"),
+	}, nil
+}
+
 // GetRateLimits returns the current rate limits on the GitHub API. This is a
 // simple and lightweight request that can also be used simply for testing the API.
 func (g realGHClient) GetRateLimits() (github.RateLimits, error) {
@@ -151,6 +690,159 @@ func (g realGHClient) GetRateLimits() (github.RateLimits, error) {
 	return *rate, nil
 }
 
+// CreateComment posts a comment with the given body on an issue of the
+// GitHub repository owner/repo.
+func (g realGHClient) CreateComment(owner, repo string, number int, body string) error {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	_, _, err := g.request(func() (interface{}, *github.Response, error) {
+		return g.client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{
+			Body: github.String(body),
+		})
+	})
+	if err != nil {
+		log.Errorf("Error creating comment on GitHub issue #%d. Error: %v", number, err)
+		return err
+	}
+
+	return nil
+}
+
+// AddLabel adds a label to an issue of the GitHub repository owner/repo; the
+// label is created if the repository doesn't have it yet.
+func (g realGHClient) AddLabel(owner, repo string, number int, label string) error {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	_, _, err := g.request(func() (interface{}, *github.Response, error) {
+		return g.client.Issues.AddLabelsToIssue(ctx, owner, repo, number, []string{label})
+	})
+	if err != nil {
+		log.Errorf("Error adding label %s to GitHub issue #%d. Error: %v", label, number, err)
+		return err
+	}
+
+	return nil
+}
+
+// EditBody replaces the body of an issue of the GitHub repository owner/repo.
+func (g realGHClient) EditBody(owner, repo string, number int, body string) error {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	_, _, err := g.request(func() (interface{}, *github.Response, error) {
+		return g.client.Issues.Edit(ctx, owner, repo, number, &github.IssueRequest{
+			Body: github.String(body),
+		})
+	})
+	if err != nil {
+		log.Errorf("Error editing body of GitHub issue #%d. Error: %v", number, err)
+		return err
+	}
+
+	return nil
+}
+
+// dryrunGHClient is an implementation of GitHubClient which performs all
+// GET requests the same as the realGHClient, but does not perform any
+// unsafe requests which may modify server data, instead printing out the
+// actions it is asked to perform without making the request.
+type dryrunGHClient struct {
+	realGHClient
+}
+
+// CreateComment prints the comment that would be posted on the GitHub issue.
+func (g dryrunGHClient) CreateComment(owner, repo string, number int, body string) error {
+	log := g.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Create comment on GitHub issue %s/%s#%d:", owner, repo, number)
+	log.Infof("  Body: %s", body)
+	log.Info("")
+
+	return nil
+}
+
+// AddLabel prints the label that would be added to the GitHub issue.
+func (g dryrunGHClient) AddLabel(owner, repo string, number int, label string) error {
+	log := g.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Add label to GitHub issue %s/%s#%d:", owner, repo, number)
+	log.Infof("  Label: %s", label)
+	log.Info("")
+
+	return nil
+}
+
+// EditBody prints the body that the GitHub issue would be updated with.
+func (g dryrunGHClient) EditBody(owner, repo string, number int, body string) error {
+	log := g.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Edit body of GitHub issue %s/%s#%d:", owner, repo, number)
+	log.Infof("  Body: %s", body)
+	log.Info("")
+
+	return nil
+}
+
+// cachingGHClient is an implementation of GitHubClient which wraps another
+// one, and caches the profiles of the GitHub users it gets in the
+// configuration, which is shared by the whole run, for the configured time.
+// Only the fields of the profile which are mirrored are kept. Logins which
+// don't exist are cached as well.
+type cachingGHClient struct {
+	GitHubClient
+	config cfg.Config
+}
+
+// GetUser returns a GitHub user from its login, from the cache if the user was
+// retrieved recently enough.
+func (g cachingGHClient) GetUser(login string) (github.User, error) {
+	log := g.config.GetLogger()
+
+	if profile, ok := g.config.GetCachedProfile(login); ok {
+		log.Debugf("Using cached profile of GitHub user %s", login)
+		if profile.Missing {
+			return github.User{}, ErrUserNotFound
+		}
+		user := github.User{Login: github.String(profile.Login)}
+		if profile.Name != "" {
+			user.Name = github.String(profile.Name)
+		}
+		if profile.Email != "" {
+			user.Email = github.String(profile.Email)
+		}
+		if profile.HTMLURL != "" {
+			user.HTMLURL = github.String(profile.HTMLURL)
+		}
+		return user, nil
+	}
+
+	user, err := g.GitHubClient.GetUser(login)
+	if err == ErrUserNotFound {
+		g.config.SetCachedProfile(cfg.GitHubProfile{Login: login, Missing: true})
+		return github.User{}, err
+	}
+	if err != nil {
+		return github.User{}, err
+	}
+
+	g.config.SetCachedProfile(cfg.GitHubProfile{
+		Login:   user.GetLogin(),
+		Name:    user.GetName(),
+		Email:   user.GetEmail(),
+		HTMLURL: user.GetHTMLURL(),
+	})
+
+	return user, nil
+}
+
 const retryBackoffRoundRatio = time.Millisecond / time.Nanosecond
 
 // request takes an API function from the GitHub library
@@ -158,6 +850,7 @@ const retryBackoffRoundRatio = time.Millisecond / time.Nanosecond
 // returns the expected value and the GitHub API response, as well as a nil
 // error. If it continues to fail until a maximum time is reached, it returns
 // a nil result as well as the returned HTTP response and a timeout error.
+// Each attempt waits for the configured GitHub limits.
 func (g realGHClient) request(f func() (interface{}, *github.Response, error)) (interface{}, *github.Response, error) {
 	log := g.config.GetLogger()
 
@@ -165,6 +858,9 @@ func (g realGHClient) request(f func() (interface{}, *github.Response, error)) (
 	var res *github.Response
 
 	op := func() error {
+		release := g.limiter.acquire()
+		defer release()
+
 		var err error
 		ret, res, err = f()
 		return err
@@ -189,6 +885,7 @@ func (g realGHClient) request(f func() (interface{}, *github.Response, error)) (
 // run. For example, a dry-run clients may be created which does
 // not make any requests that would change anything on the server,
 // but instead simply prints out the actions that it's asked to take.
+// Either way, the profiles of GitHub users are cached (see cachingGHClient).
 func NewGitHubClient(config cfg.Config) (GitHubClient, error) {
 	var ret GitHubClient
 
@@ -201,10 +898,26 @@ func NewGitHubClient(config cfg.Config) (GitHubClient, error) {
 	tc := oauth2.NewClient(ctx, ts)
 
 	client := github.NewClient(tc)
-
-	ret = realGHClient{
-		config: config,
-		client: *client,
+	limiter := newLimiter(config.GetGitHubLimits())
+
+	if config.IsDryRun() {
+		ret = dryrunGHClient{realGHClient{
+			config:    config,
+			client:    client,
+			limiter:   limiter,
+			downloads: &http.Client{},
+		}}
+	} else {
+		ret = realGHClient{
+			config:    config,
+			client:    client,
+			limiter:   limiter,
+			downloads: &http.Client{},
+		}
+	}
+	ret = cachingGHClient{
+		GitHubClient: ret,
+		config:       config,
 	}
 
 	// Make a request so we can check that we can connect fine.
@@ -0,0 +1,267 @@
+package clients
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+	"github.com/spf13/cobra"
+)
+
+// countingGHClient is a GitHubClient which knows of the given users, and
+// counts the calls to GetUser. The other methods panic.
+type countingGHClient struct {
+	GitHubClient
+
+	users map[string]github.User
+	calls int
+}
+
+func (g *countingGHClient) GetUser(login string) (github.User, error) {
+	g.calls++
+	user, ok := g.users[login]
+	if !ok {
+		return github.User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// newTestConfig returns a configuration for the repository
+// coreos/issue-sync mirrored to the JIRA project SYNC, with the given
+// configuration parameters added, saved to dir. Nothing is logged.
+func newTestConfig(t *testing.T, dir string, settings map[string]interface{}) cfg.Config {
+	all := map[string]interface{}{
+		"github-token": "token",
+		"jira-user":    "user",
+		"jira-pass":    "pass",
+		"jira-uri":     "https://jira.example.com",
+		"repo-name":    "coreos/issue-sync",
+		"jira-project": "SYNC",
+		"log-level":    "panic",
+		"timeout":      "100ms",
+	}
+	for k, v := range settings {
+		all[k] = v
+	}
+	b, err := json.Marshal(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(file, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("config", file, "")
+	cmd.Flags().Bool("dry-run", false, "")
+
+	config, err := cfg.NewConfig(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config.WithLogOutput(ioutil.Discard)
+}
+
+func TestCachingGetUser(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	expired := map[string]cfg.GitHubProfile{
+		"gone": {Login: "gone", Updated: time.Now().Add(-2 * time.Hour)},
+	}
+	b, err := json.Marshal(expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "profiles.json")
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := newTestConfig(t, dir, map[string]interface{}{
+		"users": map[string]interface{}{
+			"profile-cache-file": file,
+			"profile-cache-ttl":  "1h",
+		},
+	})
+	wrapped := &countingGHClient{users: map[string]github.User{
+		"alice": {Login: github.String("alice"), Name: github.String("Alice")},
+	}}
+	client := cachingGHClient{GitHubClient: wrapped, config: config}
+
+	tests := []struct {
+		login     string
+		wantName  string
+		wantErr   error
+		wantCalls int
+	}{
+		{"alice", "Alice", nil, 1},
+		{"alice", "Alice", nil, 1},
+		{"missing", "", ErrUserNotFound, 2},
+		{"missing", "", ErrUserNotFound, 2},
+	}
+
+	for i, test := range tests {
+		user, err := client.GetUser(test.login)
+		if err != test.wantErr {
+			t.Errorf("%d: %s: got error %v; want %v", i, test.login, err, test.wantErr)
+		}
+		if user.GetName() != test.wantName {
+			t.Errorf("%d: %s: got name %q; want %q", i, test.login, user.GetName(), test.wantName)
+		}
+		if wrapped.calls != test.wantCalls {
+			t.Errorf("%d: %s: wrapped client called %d times; want %d", i, test.login, wrapped.calls, test.wantCalls)
+		}
+	}
+
+	if err := config.SaveUserCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("profile cache file has mode %v; want 0600", mode)
+	}
+
+	b, err = ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := map[string]cfg.GitHubProfile{}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved["gone"]; ok {
+		t.Errorf("expired profile was saved")
+	}
+	if saved["alice"].Name != "Alice" {
+		t.Errorf("got saved profile of alice %+v", saved["alice"])
+	}
+	if !saved["missing"].Missing {
+		t.Errorf("got saved profile of missing %+v", saved["missing"])
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("got %d files in the directory; want the configuration and the cache", len(files))
+	}
+}
+
+func TestGraphQLURL(t *testing.T) {
+	tests := []struct {
+		base string
+		want string
+	}{
+		{"https://api.github.com/", "https://api.github.com/graphql"},
+		{"https://github.example.com/api/v3/", "https://github.example.com/api/graphql"},
+		{"https://github.example.com/api/v3", "https://github.example.com/api/graphql"},
+		{"https://example.com/github/api/v3/", "https://example.com/github/api/graphql"},
+	}
+
+	for _, test := range tests {
+		base, err := url.Parse(test.base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := graphQLURL(base); got != test.want {
+			t.Errorf("%s: got %s; want %s", test.base, got, test.want)
+		}
+	}
+}
+
+// hostTransport is an http.RoundTripper serving a redirect from github.com to
+// user-images.githubusercontent.com, and the file there, and recording the
+// Authorization header sent to each host.
+type hostTransport struct {
+	auth map[string]string
+}
+
+func (h *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.auth[req.URL.Host] = req.Header.Get("Authorization")
+
+	res := &http.Response{
+		Request: req,
+		Header:  http.Header{},
+		Body:    ioutil.NopCloser(strings.NewReader("")),
+	}
+	switch req.URL.Host {
+	case "github.com":
+		res.StatusCode = http.StatusFound
+		res.Header.Set("Location", "https://user-images.githubusercontent.com/1/image.png?token=signed")
+	case "user-images.githubusercontent.com":
+		res.StatusCode = http.StatusOK
+		res.Header.Set("Content-Type", "image/png")
+		res.Body = ioutil.NopCloser(strings.NewReader("image"))
+	default:
+		res.StatusCode = http.StatusNotFound
+	}
+	return res, nil
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := newTestConfig(t, dir, nil)
+
+	tests := []struct {
+		url      string
+		maxSize  int64
+		wantErr  error
+		wantAuth map[string]string
+	}{
+		{"https://github.com/user-attachments/assets/1", 10, nil, map[string]string{
+			"github.com":                        "token token",
+			"user-images.githubusercontent.com": "",
+		}},
+		{"https://user-images.githubusercontent.com/1/image.png", 10, nil, map[string]string{
+			"user-images.githubusercontent.com": "",
+		}},
+		{"https://github.com/user-attachments/assets/1", 2, ErrAttachmentTooLarge, map[string]string{
+			"github.com":                        "token token",
+			"user-images.githubusercontent.com": "",
+		}},
+	}
+
+	for _, test := range tests {
+		transport := &hostTransport{auth: map[string]string{}}
+		client := realGHClient{
+			config:    config,
+			limiter:   newLimiter(cfg.ServiceLimits{}),
+			downloads: &http.Client{Transport: transport},
+		}
+
+		file, err := client.DownloadAttachment(test.url, test.maxSize)
+		if err != test.wantErr {
+			t.Errorf("%s: got error %v; want %v", test.url, err, test.wantErr)
+		}
+		if err == nil && (string(file.Data) != "image" || file.ContentType != "image/png") {
+			t.Errorf("%s: got %q of type %s", test.url, file.Data, file.ContentType)
+		}
+		if !reflect.DeepEqual(transport.auth, test.wantAuth) {
+			t.Errorf("%s: got Authorization headers %v; want %v", test.url, transport.auth, test.wantAuth)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package clients
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 
@@ -19,8 +21,9 @@ import (
 // commentDateFormat is the format used in the headers of JIRA comments.
 const commentDateFormat = "15:04 PM, January 2 2006"
 
-// maxJQLIssueLength is the maximum number of GitHub issues we can
-// use before we need to stop using JQL and filter issues ourself.
+// maxJQLIssueLength is the maximum number of values in a single JQL `in`
+// clause; longer lists are searched a chunk at a time, since too long a query
+// gets a 414 Request-URI Too Large.
 const maxJQLIssueLength = 100
 
 // getErrorBody reads the HTTP response body of a JIRA API response,
@@ -45,12 +48,313 @@ func getErrorBody(config cfg.Config, res *jira.Response) error {
 // as well as swap in other implementations, such as for dry run
 // or test mocking.
 type JIRAClient interface {
-	ListIssues(ids []int) ([]jira.Issue, error)
+	ListIssues(project string, ids []int) ([]jira.Issue, error)
+	ListMirroredIssues(project string) ([]jira.Issue, error)
+	ListIssuesByKey(keys []string) ([]jira.Issue, error)
 	GetIssue(key string) (jira.Issue, error)
 	CreateIssue(issue jira.Issue) (jira.Issue, error)
 	UpdateIssue(issue jira.Issue) (jira.Issue, error)
 	CreateComment(issue jira.Issue, comment github.IssueComment, github GitHubClient) (jira.Comment, error)
 	UpdateComment(issue jira.Issue, id string, comment github.IssueComment, github GitHubClient) (jira.Comment, error)
+	AddComment(issue jira.Issue, body string) (jira.Comment, error)
+	SetCommentBody(issue jira.Issue, id string, body string) error
+	DeleteComment(issue jira.Issue, id string) error
+	GetTransitions(issue jira.Issue) ([]Transition, error)
+	DoTransition(issue jira.Issue, transition Transition, resolution string) error
+	ListVersions(project string) ([]jira.Version, error)
+	CreateVersion(project string, version jira.Version) (jira.Version, error)
+	UpdateVersion(version jira.Version) (jira.Version, error)
+	SearchUsers(query string) ([]jira.User, error)
+	AddAttachment(issue jira.Issue, name string, data []byte) (jira.Attachment, error)
+	ListRemoteLinks(issue jira.Issue) ([]RemoteLink, error)
+	SetRemoteLink(issue jira.Issue, link RemoteLink) error
+	LinkIssues(linkType string, from, to jira.Issue) error
+}
+
+// Transition is a workflow transition which can be performed on a JIRA
+// issue. Unlike jira.Transition, it includes the status it leads to.
+type Transition struct {
+	ID     string                          `json:"id"`
+	Name   string                          `json:"name"`
+	To     jira.Status                     `json:"to"`
+	Fields map[string]jira.TransitionField `json:"fields"`
+}
+
+// transitionPayload is the body of a request to perform a transition,
+// optionally setting the resolution of the issue.
+type transitionPayload struct {
+	Transition jira.TransitionPayload `json:"transition"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+}
+
+// getTransitions retrieves the transitions which can currently be performed
+// on a JIRA issue. It is shared by realJIRAClient and dryrunJIRAClient.
+func getTransitions(config cfg.Config, client jira.Client, issue jira.Issue, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error)) ([]Transition, error) {
+	log := config.GetLogger()
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/transitions?expand=transitions.fields", issue.Key), nil)
+	if err != nil {
+		log.Errorf("Error creating transitions request: %v", err)
+		return nil, err
+	}
+
+	result := struct {
+		Transitions []Transition `json:"transitions"`
+	}{}
+	_, res, err := request(func() (interface{}, *jira.Response, error) {
+		res, err := client.Do(req, &result)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error retrieving transitions of JIRA issue %s: %v", issue.Key, err)
+		return nil, getErrorBody(config, res)
+	}
+
+	return result.Transitions, nil
+}
+
+// versionPayload is the body of a request to create or update a version.
+// Unlike jira.Version, it only includes the fields which are set.
+type versionPayload struct {
+	Name        string `json:"name,omitempty"`
+	Project     string `json:"project,omitempty"`
+	ReleaseDate string `json:"releaseDate,omitempty"`
+	Released    bool   `json:"released"`
+}
+
+// RemoteLink is a link from a JIRA issue to an object outside of JIRA, such
+// as a GitHub issue or pull request. The go-jira library we use doesn't
+// support remote links.
+type RemoteLink struct {
+	ID int `json:"id,omitempty"`
+	// GlobalID identifies the linked object; setting a remote link with the
+	// same GlobalID as an existing one updates it.
+	GlobalID     string           `json:"globalId"`
+	Relationship string           `json:"relationship,omitempty"`
+	Object       RemoteLinkObject `json:"object"`
+}
+
+// RemoteLinkObject is the object a remote link points to.
+type RemoteLinkObject struct {
+	URL     string            `json:"url"`
+	Title   string            `json:"title"`
+	Summary string            `json:"summary,omitempty"`
+	Icon    *RemoteLinkIcon   `json:"icon,omitempty"`
+	Status  *RemoteLinkStatus `json:"status,omitempty"`
+}
+
+// RemoteLinkIcon is an icon displayed next to a remote link.
+type RemoteLinkIcon struct {
+	URL16x16 string `json:"url16x16,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+// RemoteLinkStatus is the status of the object a remote link points to.
+// Resolved links are struck through.
+type RemoteLinkStatus struct {
+	Resolved bool            `json:"resolved"`
+	Icon     *RemoteLinkIcon `json:"icon,omitempty"`
+}
+
+// getVersions retrieves every version of a JIRA project. It is shared by
+// realJIRAClient and dryrunJIRAClient.
+func getVersions(config cfg.Config, client jira.Client, project string, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error)) ([]jira.Version, error) {
+	log := config.GetLogger()
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/project/%s/versions", project), nil)
+	if err != nil {
+		log.Errorf("Error creating versions request: %v", err)
+		return nil, err
+	}
+
+	var versions []jira.Version
+	_, res, err := request(func() (interface{}, *jira.Response, error) {
+		versions = nil
+		res, err := client.Do(req, &versions)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error retrieving versions of JIRA project %s: %v", project, err)
+		return nil, getErrorBody(config, res)
+	}
+
+	return versions, nil
+}
+
+// maxSearchResults is the number of JIRA issues requested per page of search results.
+const maxSearchResults = 100
+
+// searchResult is the body of a response to a JIRA search.
+type searchResult struct {
+	Issues []jira.Issue `json:"issues"`
+	Total  int          `json:"total"`
+}
+
+// searchIssues retrieves every JIRA issue matching the JQL query, page by
+// page, with only the given fields. Values of the query which don't exist,
+// such as the keys of deleted issues, are ignored rather than failing the
+// search. It is shared by realJIRAClient and dryrunJIRAClient.
+func searchIssues(config cfg.Config, client jira.Client, jql string, fields []string, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error)) ([]jira.Issue, error) {
+	log := config.GetLogger()
+
+	var issues []jira.Issue
+	for {
+		params := url.Values{}
+		params.Set("jql", jql)
+		params.Set("startAt", fmt.Sprint(len(issues)))
+		params.Set("maxResults", fmt.Sprint(maxSearchResults))
+		params.Set("fields", strings.Join(fields, ","))
+		params.Set("validateQuery", "warn")
+
+		req, err := client.NewRequest("GET", "rest/api/2/search?"+params.Encode(), nil)
+		if err != nil {
+			log.Errorf("Error creating search request: %v", err)
+			return nil, err
+		}
+
+		var result searchResult
+		_, res, err := request(func() (interface{}, *jira.Response, error) {
+			result = searchResult{}
+			res, err := client.Do(req, &result)
+			return nil, res, err
+		})
+		if err != nil {
+			log.Errorf("Error retrieving JIRA issues: %v", err)
+			return nil, getErrorBody(config, res)
+		}
+
+		issues = append(issues, result.Issues...)
+		if len(result.Issues) == 0 || len(issues) >= result.Total {
+			break
+		}
+	}
+
+	return issues, nil
+}
+
+// listIssues retrieves the JIRA issues of the project with the given GitHub
+// IDs, with the fields needed to synchronize them. It is shared by
+// realJIRAClient and dryrunJIRAClient.
+func listIssues(config cfg.Config, client jira.Client, project string, ids []int, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error)) ([]jira.Issue, error) {
+	idStrs := make([]string, len(ids))
+	for i, v := range ids {
+		idStrs[i] = fmt.Sprint(v)
+	}
+
+	return searchChunks(config, client, idStrs, func(chunk string) string {
+		return fmt.Sprintf("project='%s' AND cf[%s] in (%s)", project, config.GetFieldID(cfg.GitHubID), chunk)
+	}, request)
+}
+
+// listIssuesByKey retrieves the JIRA issues with the given keys, with the
+// fields needed to synchronize them. It is shared by realJIRAClient and
+// dryrunJIRAClient.
+func listIssuesByKey(config cfg.Config, client jira.Client, keys []string, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error)) ([]jira.Issue, error) {
+	return searchChunks(config, client, keys, func(chunk string) string {
+		return fmt.Sprintf("key in (%s)", chunk)
+	}, request)
+}
+
+// searchChunks retrieves the JIRA issues matching the JQL query returned by
+// jql for each chunk of at most maxJQLIssueLength of the given values, joined
+// into a list, with the fields needed to synchronize them.
+func searchChunks(config cfg.Config, client jira.Client, values []string, jql func(string) string, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error)) ([]jira.Issue, error) {
+	var issues []jira.Issue
+	for start := 0; start < len(values); start += maxJQLIssueLength {
+		end := start + maxJQLIssueLength
+		if end > len(values) {
+			end = len(values)
+		}
+		chunk, err := searchIssues(config, client, jql(strings.Join(values[start:end], ",")), syncedIssueFields(config), request)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, chunk...)
+	}
+	return issues, nil
+}
+
+// syncedIssueFields returns the fields of JIRA issues retrieved by ListIssues
+// and ListIssuesByKey: those compared with, or copied from, the GitHub issue.
+func syncedIssueFields(config cfg.Config) []string {
+	return []string{
+		"summary", "description", "status", "labels", "issuetype", "priority",
+		"components", "fixVersions", "assignee", "reporter", "attachment",
+		"comment", "issuelinks", "subtasks",
+		config.GetFieldKey(cfg.GitHubID),
+		config.GetFieldKey(cfg.GitHubNumber),
+		config.GetFieldKey(cfg.GitHubLabels),
+		config.GetFieldKey(cfg.GitHubStatus),
+		config.GetFieldKey(cfg.GitHubReporter),
+		config.GetFieldKey(cfg.LastISUpdate),
+	}
+}
+
+// mirroredIssueFields returns the fields of JIRA issues retrieved by
+// ListMirroredIssues.
+func mirroredIssueFields(config cfg.Config) []string {
+	return []string{
+		"summary", "status", "labels", "issuetype",
+		config.GetFieldKey(cfg.GitHubID),
+		config.GetFieldKey(cfg.GitHubNumber),
+		config.GetFieldKey(cfg.GitHubStatus),
+	}
+}
+
+// mirroredIssuesJQL returns the JQL query matching every JIRA issue of the
+// project which mirrors a GitHub issue.
+func mirroredIssuesJQL(config cfg.Config, project string) string {
+	return fmt.Sprintf("project='%s' AND cf[%s] is not EMPTY", project, config.GetFieldID(cfg.GitHubID))
+}
+
+// getRemoteLinks retrieves every remote link of a JIRA issue. It is shared by
+// realJIRAClient and dryrunJIRAClient.
+func getRemoteLinks(config cfg.Config, client jira.Client, issue jira.Issue, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error)) ([]RemoteLink, error) {
+	log := config.GetLogger()
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/remotelink", issue.Key), nil)
+	if err != nil {
+		log.Errorf("Error creating remote links request: %v", err)
+		return nil, err
+	}
+
+	var links []RemoteLink
+	_, res, err := request(func() (interface{}, *jira.Response, error) {
+		links = nil
+		res, err := client.Do(req, &links)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error retrieving remote links of JIRA issue %s: %v", issue.Key, err)
+		return nil, getErrorBody(config, res)
+	}
+
+	return links, nil
+}
+
+// searchUsers retrieves the JIRA users whose username, name or email address
+// match the query. It is shared by realJIRAClient and dryrunJIRAClient.
+func searchUsers(config cfg.Config, client jira.Client, query string, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error)) ([]jira.User, error) {
+	log := config.GetLogger()
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/user/search?username=%s", url.QueryEscape(query)), nil)
+	if err != nil {
+		log.Errorf("Error creating user search request: %v", err)
+		return nil, err
+	}
+
+	var users []jira.User
+	_, res, err := request(func() (interface{}, *jira.Response, error) {
+		users = nil
+		res, err := client.Do(req, &users)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error searching JIRA users: %v", err)
+		return nil, getErrorBody(config, res)
+	}
+
+	return users, nil
 }
 
 // NewJIRAClient creates a new JIRAClient and configures it with
@@ -84,17 +388,23 @@ func NewJIRAClient(config *cfg.Config) (JIRAClient, error) {
 
 	log.Debug("JIRA clients initialized")
 
-	config.LoadJIRAConfig(*client)
+	if err := config.LoadJIRAConfig(*client); err != nil {
+		return dryrunJIRAClient{}, err
+	}
+
+	limiter := newLimiter(config.GetJIRALimits())
 
 	if config.IsDryRun() {
 		j = dryrunJIRAClient{
-			config: *config,
-			client: *client,
+			config:  *config,
+			client:  *client,
+			limiter: limiter,
 		}
 	} else {
 		j = realJIRAClient{
-			config: *config,
-			client: *client,
+			config:  *config,
+			client:  *client,
+			limiter: limiter,
 		}
 	}
 
@@ -105,63 +415,27 @@ func NewJIRAClient(config *cfg.Config) (JIRAClient, error) {
 // of the requests against the JIRA REST API. It is the canonical
 // implementation of JIRAClient.
 type realJIRAClient struct {
-	config cfg.Config
-	client jira.Client
+	config  cfg.Config
+	client  jira.Client
+	limiter *limiter
 }
 
-// ListIssues returns a list of JIRA issues on the configured project which
-// have GitHub IDs in the provided list. `ids` should be a comma-separated
-// list of GitHub IDs.
-func (j realJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
-	log := j.config.GetLogger()
-
-	idStrs := make([]string, len(ids))
-	for i, v := range ids {
-		idStrs[i] = fmt.Sprint(v)
-	}
-
-	var jql string
-	// If the list of IDs is too long, we get a 414 Request-URI Too Large, so in that case,
-	// we'll need to do the filtering ourselves.
-	if len(ids) < maxJQLIssueLength {
-		jql = fmt.Sprintf("project='%s' AND cf[%s] in (%s)",
-			j.config.GetProjectKey(), j.config.GetFieldID(cfg.GitHubID), strings.Join(idStrs, ","))
-	} else {
-		jql = fmt.Sprintf("project='%s'", j.config.GetProjectKey())
-	}
-
-	ji, res, err := j.request(func() (interface{}, *jira.Response, error) {
-		return j.client.Issue.Search(jql, nil)
-	})
-	if err != nil {
-		log.Errorf("Error retrieving JIRA issues: %v", err)
-		return nil, getErrorBody(j.config, res)
-	}
-	jiraIssues, ok := ji.([]jira.Issue)
-	if !ok {
-		log.Errorf("Get JIRA issues did not return issues! Got: %v", ji)
-		return nil, fmt.Errorf("get JIRA issues failed: expected []jira.Issue; got %T", ji)
-	}
+// ListIssues returns a list of JIRA issues on the given project which
+// have GitHub IDs in the provided list.
+func (j realJIRAClient) ListIssues(project string, ids []int) ([]jira.Issue, error) {
+	return listIssues(j.config, j.client, project, ids, j.request)
+}
 
-	var issues []jira.Issue
-	if len(ids) < maxJQLIssueLength {
-		// The issues were already filtered by our JQL, so use as is
-		issues = jiraIssues
-	} else {
-		// Filter only issues which have a defined GitHub ID in the list of IDs
-		for _, v := range jiraIssues {
-			if id, err := v.Fields.Unknowns.Int(j.config.GetFieldKey(cfg.GitHubID)); err == nil {
-				for _, idOpt := range ids {
-					if id == int64(idOpt) {
-						issues = append(issues, v)
-						break
-					}
-				}
-			}
-		}
-	}
+// ListMirroredIssues returns every JIRA issue of the given project which mirrors
+// a GitHub issue, with only the fields needed to find orphaned ones.
+func (j realJIRAClient) ListMirroredIssues(project string) ([]jira.Issue, error) {
+	return searchIssues(j.config, j.client, mirroredIssuesJQL(j.config, project), mirroredIssueFields(j.config), j.request)
+}
 
-	return issues, nil
+// ListIssuesByKey returns the JIRA issues with the given keys. Keys of issues
+// which no longer exist are ignored.
+func (j realJIRAClient) ListIssuesByKey(keys []string) ([]jira.Issue, error) {
+	return listIssuesByKey(j.config, j.client, keys, j.request)
 }
 
 // GetIssue returns a single JIRA issue within the configured project
@@ -229,9 +503,9 @@ func (j realJIRAClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
 	return *is, nil
 }
 
-// maxBodyLength is the maximum length of a JIRA comment body, which is currently
-// 2^15-1.
-const maxBodyLength = 1 << 15
+// MaxBodyLength is the maximum length of a JIRA comment body, which is currently
+// 2^15-1. Longer bodies are cut short.
+const MaxBodyLength = 1 << 15
 
 // CreateComment adds a comment to the provided JIRA issue using the fields from
 // the provided GitHub comment. It then returns the created comment.
@@ -255,8 +529,8 @@ func (j realJIRAClient) CreateComment(issue jira.Issue, comment github.IssueComm
 		comment.GetBody(),
 	)
 
-	if len(body) > maxBodyLength {
-		body = body[:maxBodyLength]
+	if len(body) > MaxBodyLength {
+		body = body[:MaxBodyLength]
 	}
 
 	jComment := jira.Comment{
@@ -301,8 +575,8 @@ func (j realJIRAClient) UpdateComment(issue jira.Issue, id string, comment githu
 		comment.GetBody(),
 	)
 
-	if len(body) > maxBodyLength {
-		body = body[:maxBodyLength]
+	if len(body) > MaxBodyLength {
+		body = body[:MaxBodyLength]
 	}
 
 	// As it is, the JIRA API we're using doesn't have any way to update comments natively.
@@ -313,33 +587,285 @@ func (j realJIRAClient) UpdateComment(issue jira.Issue, id string, comment githu
 		Body: body,
 	}
 
-	req, err := j.client.NewRequest("PUT", fmt.Sprintf("rest/api/2/issue/%s/comment/%s", issue.Key, id), request)
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		req, err := j.client.NewRequest("PUT", fmt.Sprintf("rest/api/2/issue/%s/comment/%s", issue.Key, id), request)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := j.client.Do(req, nil)
+		return nil, res, err
+	})
 	if err != nil {
-		log.Errorf("Error creating comment update request: %s", err)
-		return jira.Comment{}, err
+		log.Errorf("Error updating comment: %v", err)
+		return jira.Comment{}, getErrorBody(j.config, res)
+	}
+
+	// The response isn't decoded; the comment is what was sent.
+	return jira.Comment{ID: id, Body: body}, nil
+}
+
+// AddComment adds a comment with the given body, as is, to the provided JIRA
+// issue. It returns the created comment.
+func (j realJIRAClient) AddComment(issue jira.Issue, body string) (jira.Comment, error) {
+	log := j.config.GetLogger()
+
+	if len(body) > MaxBodyLength {
+		body = body[:MaxBodyLength]
+	}
+
+	jComment := jira.Comment{
+		Body: body,
 	}
 
 	com, res, err := j.request(func() (interface{}, *jira.Response, error) {
-		res, err := j.client.Do(req, nil)
-		return nil, res, err
+		return j.client.Issue.AddComment(issue.ID, &jComment)
 	})
 	if err != nil {
-		log.Errorf("Error updating comment: %v", err)
+		log.Errorf("Error creating JIRA comment on issue %s. Error: %v", issue.Key, err)
 		return jira.Comment{}, getErrorBody(j.config, res)
 	}
 	co, ok := com.(*jira.Comment)
 	if !ok {
-		log.Errorf("Update JIRA comment did not return comment! Got: %v", com)
-		return jira.Comment{}, fmt.Errorf("Update JIRA comment failed: expected *jira.Comment; got %T", com)
+		log.Errorf("Create JIRA comment did not return comment! Got: %v", com)
+		return jira.Comment{}, fmt.Errorf("Create JIRA comment failed: expected *jira.Comment; got %T", com)
 	}
 	return *co, nil
 }
 
+// SetCommentBody replaces the whole body of a comment (identified by the `id`
+// parameter) on a given JIRA issue, header included.
+func (j realJIRAClient) SetCommentBody(issue jira.Issue, id string, body string) error {
+	log := j.config.GetLogger()
+
+	if len(body) > MaxBodyLength {
+		body = body[:MaxBodyLength]
+	}
+
+	request := struct {
+		Body string `json:"body"`
+	}{
+		Body: body,
+	}
+
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		req, err := j.client.NewRequest("PUT", fmt.Sprintf("rest/api/2/issue/%s/comment/%s", issue.Key, id), request)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := j.client.Do(req, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error updating JIRA comment %s on issue %s. Error: %v", id, issue.Key, err)
+		return getErrorBody(j.config, res)
+	}
+
+	return nil
+}
+
+// DeleteComment deletes a comment (identified by the `id` parameter) from a
+// given JIRA issue.
+func (j realJIRAClient) DeleteComment(issue jira.Issue, id string) error {
+	log := j.config.GetLogger()
+
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		req, err := j.client.NewRequest("DELETE", fmt.Sprintf("rest/api/2/issue/%s/comment/%s", issue.Key, id), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := j.client.Do(req, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error deleting JIRA comment %s on issue %s. Error: %v", id, issue.Key, err)
+		return getErrorBody(j.config, res)
+	}
+
+	return nil
+}
+
+// GetTransitions returns the workflow transitions which can currently be
+// performed on the given JIRA issue.
+func (j realJIRAClient) GetTransitions(issue jira.Issue) ([]Transition, error) {
+	return getTransitions(j.config, j.client, issue, j.request)
+}
+
+// DoTransition performs a workflow transition on the given JIRA issue. If
+// resolution is not empty, the resolution of the issue is set to it as part
+// of the transition.
+func (j realJIRAClient) DoTransition(issue jira.Issue, transition Transition, resolution string) error {
+	log := j.config.GetLogger()
+
+	payload := transitionPayload{
+		Transition: jira.TransitionPayload{ID: transition.ID},
+	}
+	if resolution != "" {
+		payload.Fields = map[string]interface{}{
+			"resolution": map[string]string{"name": resolution},
+		}
+	}
+
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		req, err := j.client.NewRequest("POST", fmt.Sprintf("rest/api/2/issue/%s/transitions", issue.Key), payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := j.client.Do(req, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error performing transition %q on JIRA issue %s: %v", transition.Name, issue.Key, err)
+		return getErrorBody(j.config, res)
+	}
+
+	return nil
+}
+
+// ListVersions returns every version of the given JIRA project.
+func (j realJIRAClient) ListVersions(project string) ([]jira.Version, error) {
+	return getVersions(j.config, j.client, project, j.request)
+}
+
+// CreateVersion creates a version in the given JIRA project, with the name,
+// release date and released state of the version provided, and returns the
+// version which was created.
+func (j realJIRAClient) CreateVersion(project string, version jira.Version) (jira.Version, error) {
+	log := j.config.GetLogger()
+
+	payload := versionPayload{
+		Name:        version.Name,
+		Project:     project,
+		ReleaseDate: version.ReleaseDate,
+		Released:    version.Released,
+	}
+
+	var created jira.Version
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		req, err := j.client.NewRequest("POST", "rest/api/2/version", payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := j.client.Do(req, &created)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error creating version %s in JIRA project %s: %v", version.Name, project, err)
+		return jira.Version{}, getErrorBody(j.config, res)
+	}
+
+	return created, nil
+}
+
+// UpdateVersion sets the release date and released state of a JIRA version
+// (identified by version.ID) to those of the version provided.
+func (j realJIRAClient) UpdateVersion(version jira.Version) (jira.Version, error) {
+	log := j.config.GetLogger()
+
+	payload := versionPayload{
+		ReleaseDate: version.ReleaseDate,
+		Released:    version.Released,
+	}
+
+	var updated jira.Version
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		req, err := j.client.NewRequest("PUT", fmt.Sprintf("rest/api/2/version/%s", version.ID), payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := j.client.Do(req, &updated)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error updating JIRA version %s: %v", version.Name, err)
+		return jira.Version{}, getErrorBody(j.config, res)
+	}
+
+	return updated, nil
+}
+
+// SearchUsers returns the JIRA users whose username, name or email address
+// match the query.
+func (j realJIRAClient) SearchUsers(query string) ([]jira.User, error) {
+	return searchUsers(j.config, j.client, query, j.request)
+}
+
+// AddAttachment uploads a file as an attachment of the provided JIRA issue, with
+// the given name. It returns the created attachment.
+func (j realJIRAClient) AddAttachment(issue jira.Issue, name string, data []byte) (jira.Attachment, error) {
+	log := j.config.GetLogger()
+
+	a, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		return j.client.Issue.PostAttachment(issue.ID, bytes.NewReader(data), name)
+	})
+	if err != nil {
+		log.Errorf("Error adding attachment %s to JIRA issue %s. Error: %v", name, issue.Key, err)
+		return jira.Attachment{}, getErrorBody(j.config, res)
+	}
+	attachments, ok := a.(*[]jira.Attachment)
+	if !ok || len(*attachments) == 0 {
+		log.Errorf("Add JIRA attachment did not return attachment! Got: %v", a)
+		return jira.Attachment{}, fmt.Errorf("Add JIRA attachment failed: expected *[]jira.Attachment; got %T", a)
+	}
+
+	return (*attachments)[0], nil
+}
+
+// ListRemoteLinks returns every remote link of the provided JIRA issue.
+func (j realJIRAClient) ListRemoteLinks(issue jira.Issue) ([]RemoteLink, error) {
+	return getRemoteLinks(j.config, j.client, issue, j.request)
+}
+
+// SetRemoteLink adds a remote link to the provided JIRA issue, or updates the
+// remote link with the same global ID if there is one.
+func (j realJIRAClient) SetRemoteLink(issue jira.Issue, link RemoteLink) error {
+	log := j.config.GetLogger()
+
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		req, err := j.client.NewRequest("POST", fmt.Sprintf("rest/api/2/issue/%s/remotelink", issue.Key), link)
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := j.client.Do(req, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error setting remote link %s on JIRA issue %s. Error: %v", link.Object.URL, issue.Key, err)
+		return getErrorBody(j.config, res)
+	}
+
+	return nil
+}
+
+// LinkIssues links two JIRA issues with an issue link of the given type, so
+// that from is related to to by the outward description of the type (e.g.
+// "from blocks to").
+func (j realJIRAClient) LinkIssues(linkType string, from, to jira.Issue) error {
+	log := j.config.GetLogger()
+
+	// JIRA applies the outward description to the inward issue of a new link.
+	link := jira.IssueLink{
+		Type:         jira.IssueLinkType{Name: linkType},
+		InwardIssue:  &jira.Issue{Key: from.Key},
+		OutwardIssue: &jira.Issue{Key: to.Key},
+	}
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		res, err := j.client.Issue.AddLink(&link)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error linking JIRA issue %s to %s. Error: %v", from.Key, to.Key, err)
+		return getErrorBody(j.config, res)
+	}
+
+	return nil
+}
+
 // request takes an API function from the JIRA library
 // and calls it with exponential backoff. If the function succeeds, it
 // returns the expected value and the JIRA API response, as well as a nil
 // error. If it continues to fail until a maximum time is reached, it returns
 // a nil result as well as the returned HTTP response and a timeout error.
+// Each attempt waits for the configured JIRA limits.
 func (j realJIRAClient) request(f func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error) {
 	log := j.config.GetLogger()
 
@@ -347,6 +873,9 @@ func (j realJIRAClient) request(f func() (interface{}, *jira.Response, error)) (
 	var res *jira.Response
 
 	op := func() error {
+		release := j.limiter.acquire()
+		defer release()
+
 		var err error
 		ret, res, err = f()
 		return err
@@ -371,8 +900,9 @@ func (j realJIRAClient) request(f func() (interface{}, *jira.Response, error)) (
 // unsafe requests which may modify server data, instead printing out the
 // actions it is asked to perform without making the request.
 type dryrunJIRAClient struct {
-	config cfg.Config
-	client jira.Client
+	config  cfg.Config
+	client  jira.Client
+	limiter *limiter
 }
 
 // newlineReplaceRegex is a regex to match both "\r\n" and just "\n" newline styles,
@@ -394,61 +924,28 @@ func truncate(s string, length int) string {
 	return fmt.Sprintf("%s...", s[0:length])
 }
 
-// ListIssues returns a list of JIRA issues on the configured project which
-// have GitHub IDs in the provided list. `ids` should be a comma-separated
-// list of GitHub IDs.
+// ListIssues returns a list of JIRA issues on the given project which
+// have GitHub IDs in the provided list.
 //
 // This function is identical to that in realJIRAClient.
-func (j dryrunJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
-	log := j.config.GetLogger()
-
-	idStrs := make([]string, len(ids))
-	for i, v := range ids {
-		idStrs[i] = fmt.Sprint(v)
-	}
-
-	var jql string
-	// If the list of IDs is too long, we get a 414 Request-URI Too Large, so in that case,
-	// we'll need to do the filtering ourselves.
-	if len(ids) < maxJQLIssueLength {
-		jql = fmt.Sprintf("project='%s' AND cf[%s] in (%s)",
-			j.config.GetProjectKey(), j.config.GetFieldID(cfg.GitHubID), strings.Join(idStrs, ","))
-	} else {
-		jql = fmt.Sprintf("project='%s'", j.config.GetProjectKey())
-	}
-
-	ji, res, err := j.request(func() (interface{}, *jira.Response, error) {
-		return j.client.Issue.Search(jql, nil)
-	})
-	if err != nil {
-		log.Errorf("Error retrieving JIRA issues: %v", err)
-		return nil, getErrorBody(j.config, res)
-	}
-	jiraIssues, ok := ji.([]jira.Issue)
-	if !ok {
-		log.Errorf("Get JIRA issues did not return issues! Got: %v", ji)
-		return nil, fmt.Errorf("get JIRA issues failed: expected []jira.Issue; got %T", ji)
-	}
+func (j dryrunJIRAClient) ListIssues(project string, ids []int) ([]jira.Issue, error) {
+	return listIssues(j.config, j.client, project, ids, j.request)
+}
 
-	var issues []jira.Issue
-	if len(ids) < maxJQLIssueLength {
-		// The issues were already filtered by our JQL, so use as is
-		issues = jiraIssues
-	} else {
-		// Filter only issues which have a defined GitHub ID in the list of IDs
-		for _, v := range jiraIssues {
-			if id, err := v.Fields.Unknowns.Int(j.config.GetFieldKey(cfg.GitHubID)); err == nil {
-				for _, idOpt := range ids {
-					if id == int64(idOpt) {
-						issues = append(issues, v)
-						break
-					}
-				}
-			}
-		}
-	}
+// ListMirroredIssues returns every JIRA issue of the given project which mirrors
+// a GitHub issue, with only the fields needed to find orphaned ones.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) ListMirroredIssues(project string) ([]jira.Issue, error) {
+	return searchIssues(j.config, j.client, mirroredIssuesJQL(j.config, project), mirroredIssueFields(j.config), j.request)
+}
 
-	return issues, nil
+// ListIssuesByKey returns the JIRA issues with the given keys. Keys of issues
+// which no longer exist are ignored.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) ListIssuesByKey(keys []string) ([]jira.Issue, error) {
+	return listIssuesByKey(j.config, j.client, keys, j.request)
 }
 
 // GetIssue returns a single JIRA issue within the configured project
@@ -484,6 +981,7 @@ func (j dryrunJIRAClient) CreateIssue(issue jira.Issue) (jira.Issue, error) {
 
 	log.Info("")
 	log.Info("Create new JIRA issue:")
+	log.Infof("  Type: %s", fields.Type.Name)
 	log.Infof("  Summary: %s", fields.Summary)
 	log.Infof("  Description: %s", truncate(fields.Description, 50))
 	log.Infof("  GitHub ID: %d", fields.Unknowns[j.config.GetFieldKey(cfg.GitHubID)])
@@ -491,6 +989,28 @@ func (j dryrunJIRAClient) CreateIssue(issue jira.Issue) (jira.Issue, error) {
 	log.Infof("  Labels: %s", fields.Unknowns[j.config.GetFieldKey(cfg.GitHubLabels)])
 	log.Infof("  State: %s", fields.Unknowns[j.config.GetFieldKey(cfg.GitHubStatus)])
 	log.Infof("  Reporter: %s", fields.Unknowns[j.config.GetFieldKey(cfg.GitHubReporter)])
+	if labels, ok := fields.Unknowns["labels"].([]string); ok {
+		log.Infof("  JIRA Labels: %s", strings.Join(labels, ", "))
+	}
+	if components, ok := fields.Unknowns["components"].([]jira.Component); ok {
+		log.Infof("  Components: %s", componentNames(components))
+	}
+	if fields.Priority != nil {
+		log.Infof("  Priority: %s", fields.Priority.Name)
+	}
+	if fields.Reporter != nil {
+		log.Infof("  JIRA Reporter: %s", fields.Reporter.Name)
+	}
+	if fields.Assignee != nil {
+		log.Infof("  Assignee: %s", fields.Assignee.Name)
+	}
+	if versions, ok := fields.Unknowns["fixVersions"].([]jira.FixVersion); ok {
+		names := make([]string, len(versions))
+		for i, v := range versions {
+			names[i] = v.Name
+		}
+		log.Infof("  Fix versions: %s", strings.Join(names, ", "))
+	}
 	log.Info("")
 
 	return issue, nil
@@ -506,6 +1026,7 @@ func (j dryrunJIRAClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
 
 	log.Info("")
 	log.Infof("Update JIRA issue %s:", issue.Key)
+	log.Infof("  Type: %s", fields.Type.Name)
 	log.Infof("  Summary: %s", fields.Summary)
 	log.Infof("  Description: %s", truncate(fields.Description, 50))
 	key := j.config.GetFieldKey(cfg.GitHubLabels)
@@ -516,11 +1037,42 @@ func (j dryrunJIRAClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
 	if state, err := fields.Unknowns.String(key); err == nil {
 		log.Infof("  State: %s", state)
 	}
+	if labels, ok := fields.Unknowns["labels"].([]string); ok {
+		log.Infof("  JIRA Labels: %s", strings.Join(labels, ", "))
+	}
+	if components, ok := fields.Unknowns["components"].([]jira.Component); ok {
+		log.Infof("  Components: %s", componentNames(components))
+	}
+	if fields.Priority != nil {
+		log.Infof("  Priority: %s", fields.Priority.Name)
+	}
+	if fields.Reporter != nil {
+		log.Infof("  JIRA Reporter: %s", fields.Reporter.Name)
+	}
+	if fields.Assignee != nil {
+		log.Infof("  Assignee: %s", fields.Assignee.Name)
+	}
+	if versions, ok := fields.Unknowns["fixVersions"].([]jira.FixVersion); ok {
+		names := make([]string, len(versions))
+		for i, v := range versions {
+			names[i] = v.Name
+		}
+		log.Infof("  Fix versions: %s", strings.Join(names, ", "))
+	}
 	log.Info("")
 
 	return issue, nil
 }
 
+// componentNames returns the comma-separated names of a list of JIRA components.
+func componentNames(components []jira.Component) string {
+	names := make([]string, len(components))
+	for i, component := range components {
+		names[i] = component.Name
+	}
+	return strings.Join(names, ", ")
+}
+
 // CreateComment prints the body that would be set on a new comment if it were
 // to be created according to the fields of the provided GitHub comment. It then
 // returns a comment object containing the body that would be used.
@@ -600,11 +1152,180 @@ func (j dryrunJIRAClient) UpdateComment(issue jira.Issue, id string, comment git
 	}, nil
 }
 
+// AddComment prints the comment that would be added to the given JIRA issue.
+// It returns a comment with the given body.
+func (j dryrunJIRAClient) AddComment(issue jira.Issue, body string) (jira.Comment, error) {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Create comment on JIRA issue %s:", issue.Key)
+	log.Infof("  Body: %s", truncate(body, 100))
+	log.Info("")
+
+	return jira.Comment{
+		Body: body,
+	}, nil
+}
+
+// SetCommentBody prints the body a comment on the given JIRA issue would be
+// replaced with.
+func (j dryrunJIRAClient) SetCommentBody(issue jira.Issue, id string, body string) error {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Update JIRA comment %s on issue %s:", id, issue.Key)
+	log.Infof("  Body: %s", truncate(body, 100))
+	log.Info("")
+
+	return nil
+}
+
+// DeleteComment prints the comment that would be deleted from the given JIRA issue.
+func (j dryrunJIRAClient) DeleteComment(issue jira.Issue, id string) error {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Delete JIRA comment %s on issue %s", id, issue.Key)
+	log.Info("")
+
+	return nil
+}
+
+// GetTransitions returns the workflow transitions which can currently be
+// performed on the given JIRA issue.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) GetTransitions(issue jira.Issue) ([]Transition, error) {
+	return getTransitions(j.config, j.client, issue, j.request)
+}
+
+// DoTransition prints the workflow transition that would be performed on
+// the given JIRA issue, and the resolution that would be set.
+func (j dryrunJIRAClient) DoTransition(issue jira.Issue, transition Transition, resolution string) error {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Transition JIRA issue %s:", issue.Key)
+	log.Infof("  Transition: %s", transition.Name)
+	log.Infof("  New status: %s", transition.To.Name)
+	if resolution != "" {
+		log.Infof("  Resolution: %s", resolution)
+	}
+	log.Info("")
+
+	return nil
+}
+
+// ListVersions returns every version of the given JIRA project.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) ListVersions(project string) ([]jira.Version, error) {
+	return getVersions(j.config, j.client, project, j.request)
+}
+
+// CreateVersion prints the version that would be created in the given JIRA
+// project. It returns the provided version as-is.
+func (j dryrunJIRAClient) CreateVersion(project string, version jira.Version) (jira.Version, error) {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Create version in JIRA project %s:", project)
+	log.Infof("  Name: %s", version.Name)
+	if version.ReleaseDate != "" {
+		log.Infof("  Release date: %s", version.ReleaseDate)
+	}
+	log.Infof("  Released: %t", version.Released)
+	log.Info("")
+
+	return version, nil
+}
+
+// UpdateVersion prints the release date and released state that would be set
+// on a JIRA version. It returns the provided version as-is.
+func (j dryrunJIRAClient) UpdateVersion(version jira.Version) (jira.Version, error) {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Update JIRA version %s:", version.Name)
+	if version.ReleaseDate != "" {
+		log.Infof("  Release date: %s", version.ReleaseDate)
+	}
+	log.Infof("  Released: %t", version.Released)
+	log.Info("")
+
+	return version, nil
+}
+
+// SearchUsers returns the JIRA users whose username, name or email address
+// match the query.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) SearchUsers(query string) ([]jira.User, error) {
+	return searchUsers(j.config, j.client, query, j.request)
+}
+
+// AddAttachment prints the file that would be attached to the provided JIRA
+// issue. It returns an attachment with the name and size of the file.
+func (j dryrunJIRAClient) AddAttachment(issue jira.Issue, name string, data []byte) (jira.Attachment, error) {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Add attachment to JIRA issue %s:", issue.Key)
+	log.Infof("  Name: %s", name)
+	log.Infof("  Size: %d bytes", len(data))
+	log.Info("")
+
+	return jira.Attachment{
+		Filename: name,
+		Size:     len(data),
+	}, nil
+}
+
+// ListRemoteLinks returns every remote link of the provided JIRA issue.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) ListRemoteLinks(issue jira.Issue) ([]RemoteLink, error) {
+	return getRemoteLinks(j.config, j.client, issue, j.request)
+}
+
+// SetRemoteLink prints the remote link that would be added to or updated on
+// the provided JIRA issue.
+func (j dryrunJIRAClient) SetRemoteLink(issue jira.Issue, link RemoteLink) error {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Set remote link on JIRA issue %s:", issue.Key)
+	log.Infof("  URL: %s", link.Object.URL)
+	log.Infof("  Title: %s", link.Object.Title)
+	if link.Object.Summary != "" {
+		log.Infof("  Summary: %s", link.Object.Summary)
+	}
+	if link.Object.Status != nil {
+		log.Infof("  Resolved: %t", link.Object.Status.Resolved)
+	}
+	log.Info("")
+
+	return nil
+}
+
+// LinkIssues prints the issue link that would be added between two JIRA issues.
+func (j dryrunJIRAClient) LinkIssues(linkType string, from, to jira.Issue) error {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Link JIRA issue %s to %s:", from.Key, to.Key)
+	log.Infof("  Type: %s", linkType)
+	log.Info("")
+
+	return nil
+}
+
 // request takes an API function from the JIRA library
 // and calls it with exponential backoff. If the function succeeds, it
 // returns the expected value and the JIRA API response, as well as a nil
 // error. If it continues to fail until a maximum time is reached, it returns
 // a nil result as well as the returned HTTP response and a timeout error.
+// Each attempt waits for the configured JIRA limits.
 //
 // This function is identical to that in realJIRAClient.
 func (j dryrunJIRAClient) request(f func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error) {
@@ -614,6 +1335,9 @@ func (j dryrunJIRAClient) request(f func() (interface{}, *jira.Response, error))
 	var res *jira.Response
 
 	op := func() error {
+		release := j.limiter.acquire()
+		defer release()
+
 		var err error
 		ret, res, err = f()
 		return err
@@ -0,0 +1,166 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// testPageSize is the number of issues the fake JIRA server of TestSearchChunks
+// returns per page, less than maxSearchResults, as JIRA may.
+const testPageSize = 30
+
+func TestSearchChunks(t *testing.T) {
+	keyRegex := regexp.MustCompile(`^key in \((.*)\)$`)
+
+	tests := []struct {
+		keys         int
+		wantRequests int
+	}{
+		{0, 0},
+		{1, 1},
+		{testPageSize, 1},
+		{testPageSize + 1, 2},
+		{maxJQLIssueLength, 4},
+		{maxJQLIssueLength + 1, 5},
+		{2*maxJQLIssueLength + 50, 10},
+	}
+
+	for _, test := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			m := keyRegex.FindStringSubmatch(r.URL.Query().Get("jql"))
+			if m == nil {
+				http.Error(w, "unexpected query", http.StatusBadRequest)
+				return
+			}
+			keys := strings.Split(m[1], ",")
+			if len(keys) > maxJQLIssueLength {
+				http.Error(w, "query too long", http.StatusBadRequest)
+				return
+			}
+			start, _ := strconv.Atoi(r.URL.Query().Get("startAt"))
+			end := start + testPageSize
+			if end > len(keys) {
+				end = len(keys)
+			}
+
+			result := searchResult{Total: len(keys)}
+			for _, key := range keys[start:end] {
+				result.Issues = append(result.Issues, jira.Issue{Key: key})
+			}
+			json.NewEncoder(w).Encode(result)
+		}))
+
+		client, err := jira.NewClient(nil, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var keys []string
+		for i := 1; i <= test.keys; i++ {
+			keys = append(keys, fmt.Sprintf("SYNC-%d", i))
+		}
+		request := func(f func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error) {
+			return f()
+		}
+
+		issues, err := searchChunks(cfg.Config{}, *client, keys, func(chunk string) string {
+			return fmt.Sprintf("key in (%s)", chunk)
+		}, request)
+		server.Close()
+		if err != nil {
+			t.Fatalf("%d keys: %v", test.keys, err)
+		}
+
+		var got []string
+		for _, issue := range issues {
+			got = append(got, issue.Key)
+		}
+		if !reflect.DeepEqual(got, keys) {
+			t.Errorf("%d keys: got %d issues %v", test.keys, len(got), got)
+		}
+		if requests != test.wantRequests {
+			t.Errorf("%d keys: made %d requests; want %d", test.keys, requests, test.wantRequests)
+		}
+	}
+}
+
+func TestUpdateComment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := newTestConfig(t, dir, nil)
+
+	ghClient := &countingGHClient{users: map[string]github.User{
+		"alice": {Login: github.String("alice"), HTMLURL: github.String("https://github.com/alice")},
+	}}
+	created := time.Date(2019, time.April, 17, 16, 27, 0, 0, time.UTC)
+	header := "Comment [(ID 11)|https://github.com/coreos/issue-sync/issues/1#issuecomment-11] from GitHub user [alice|https://github.com/alice] at 16:27 PM, April 17 2019:\n\n"
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"short", "Edited", header + "Edited"},
+		{"long", strings.Repeat("a", MaxBodyLength), (header + strings.Repeat("a", MaxBodyLength))[:MaxBodyLength]},
+	}
+
+	for _, test := range tests {
+		var sent string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "PUT" || r.URL.Path != "/rest/api/2/issue/SYNC-1/comment/10" {
+				http.NotFound(w, r)
+				return
+			}
+			var request struct {
+				Body string `json:"body"`
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+			sent = request.Body
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		client, err := jira.NewClient(nil, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		j := realJIRAClient{config: config, client: *client, limiter: newLimiter(cfg.ServiceLimits{})}
+
+		comment, err := j.UpdateComment(jira.Issue{Key: "SYNC-1"}, "10", github.IssueComment{
+			ID:        github.Int(11),
+			HTMLURL:   github.String("https://github.com/coreos/issue-sync/issues/1#issuecomment-11"),
+			User:      &github.User{Login: github.String("alice")},
+			Body:      github.String(test.body),
+			CreatedAt: &created,
+		}, ghClient)
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if sent != test.want {
+			t.Errorf("%s: sent body of %d bytes; want %d", test.name, len(sent), len(test.want))
+		}
+		if comment.ID != "10" || comment.Body != test.want {
+			t.Errorf("%s: got comment %s with a body of %d bytes; want 10 with %d", test.name, comment.ID, len(comment.Body), len(test.want))
+		}
+	}
+}
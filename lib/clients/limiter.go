@@ -0,0 +1,78 @@
+package clients
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// limiter limits the requests made to a service, both in how many are in
+// flight at once and, with a token bucket, how fast they are made. It is
+// shared by every copy of a client, and safe for concurrent use.
+type limiter struct {
+	// slots holds a value for each request in flight; it is nil if their
+	// number isn't limited.
+	slots chan struct{}
+
+	lock sync.Mutex
+	// rate is the number of tokens added to the bucket per second, or 0 if
+	// the rate isn't limited, and burst the size of the bucket.
+	rate  float64
+	burst float64
+	// tokens is the number of tokens in the bucket at last; it is negative
+	// when requests are waiting for tokens.
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter returns a limiter enforcing the given limits.
+func newLimiter(limits cfg.ServiceLimits) *limiter {
+	l := &limiter{
+		rate:  limits.Rate,
+		burst: float64(limits.Burst),
+	}
+	if limits.MaxRequests > 0 {
+		l.slots = make(chan struct{}, limits.MaxRequests)
+	}
+	if l.burst == 0 {
+		l.burst = math.Max(1, math.Ceil(l.rate))
+	}
+	l.tokens = l.burst
+	l.last = time.Now()
+	return l
+}
+
+// acquire waits until a request can be made, and returns the function to call
+// once it is done.
+func (l *limiter) acquire() func() {
+	l.wait()
+
+	if l.slots == nil {
+		return func() {}
+	}
+	l.slots <- struct{}{}
+	return func() { <-l.slots }
+}
+
+// wait takes a token from the bucket, waiting for one to be added if it is
+// empty. Tokens are reserved in the order requests arrive.
+func (l *limiter) wait() {
+	if l.rate == 0 {
+		return
+	}
+
+	l.lock.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.lock.Unlock()
+
+	time.Sleep(delay)
+}
@@ -0,0 +1,103 @@
+package clients
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+func TestLimiterWait(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   cfg.ServiceLimits
+		requests int
+		min, max time.Duration
+	}{
+		{"no limit", cfg.ServiceLimits{}, 100, 0, 50 * time.Millisecond},
+		{"within the burst", cfg.ServiceLimits{Rate: 10, Burst: 5}, 5, 0, 50 * time.Millisecond},
+		{"above the burst", cfg.ServiceLimits{Rate: 10, Burst: 5}, 7, 150 * time.Millisecond, 400 * time.Millisecond},
+		{"default burst", cfg.ServiceLimits{Rate: 20}, 22, 75 * time.Millisecond, 300 * time.Millisecond},
+		{"rate", cfg.ServiceLimits{Rate: 50, Burst: 1}, 11, 180 * time.Millisecond, 500 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		l := newLimiter(test.limits)
+		start := time.Now()
+		for i := 0; i < test.requests; i++ {
+			l.wait()
+		}
+		if elapsed := time.Since(start); elapsed < test.min || elapsed > test.max {
+			t.Errorf("%s: %d requests took %v; want between %v and %v", test.name, test.requests, elapsed, test.min, test.max)
+		}
+	}
+}
+
+func TestLimiterWaitConcurrent(t *testing.T) {
+	l := newLimiter(cfg.ServiceLimits{Rate: 100, Burst: 1})
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.wait()
+		}()
+	}
+	wg.Wait()
+
+	// The first request takes the token in the bucket, and the others wait
+	// for one token each, in turn.
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond || elapsed > 400*time.Millisecond {
+		t.Errorf("10 concurrent requests took %v; want about 90ms", elapsed)
+	}
+}
+
+func TestLimiterMaxRequests(t *testing.T) {
+	tests := []struct {
+		maxRequests int
+		requests    int
+		want        int
+	}{
+		{0, 5, 5},
+		{1, 5, 1},
+		{2, 5, 2},
+		{10, 5, 5},
+	}
+
+	for _, test := range tests {
+		l := newLimiter(cfg.ServiceLimits{MaxRequests: test.maxRequests})
+
+		var lock sync.Mutex
+		running, maxRunning := 0, 0
+		var wg sync.WaitGroup
+		for i := 0; i < test.requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				release := l.acquire()
+				defer release()
+
+				lock.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				lock.Unlock()
+
+				time.Sleep(20 * time.Millisecond)
+
+				lock.Lock()
+				running--
+				lock.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		if maxRunning != test.want {
+			t.Errorf("max-requests %d: %d requests in flight at once; want %d", test.maxRequests, maxRunning, test.want)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package lib
 
 import (
+	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/andygrunwald/go-jira"
 	"github.com/coreos/issue-sync/cfg"
@@ -13,27 +16,73 @@ import (
 // jCommentRegex matches a generated JIRA comment. It has matching groups to retrieve the
 // GitHub Comment ID (\1), the GitHub username (\2), the GitHub real name (\3, if it exists),
 // the time the comment was posted (\3 or \4), and the body of the comment (\4 or \5).
-var jCommentRegex = regexp.MustCompile("^Comment \\[\\(ID (\\d+)\\)\\|.*?] from GitHub user \\[(.+)\\|.*?] \\((.+)\\) at (.+):\\n\\n(.+)$")
+var jCommentRegex = regexp.MustCompile("^Comment \\[\\(ID (\\d+)\\)\\|.*?] from GitHub user \\[(.+)\\|.*?] \\((.+)\\) at (.+):\\n\\n((?s).+)$")
 
 // jCommentIDRegex just matches the beginning of a generated JIRA comment. It's a smaller,
 // simpler, and more efficient regex, to quickly filter only generated comments and retrieve
 // just their GitHub ID for matching.
 var jCommentIDRegex = regexp.MustCompile("^Comment \\[\\(ID (\\d+)\\)\\|")
 
-// CompareComments takes a GitHub issue, and retrieves all of its comments. It then
-// matches each one to a comment in `existing`. If it finds a match, it calls
-// UpdateComment; if it doesn't, it calls CreateComment.
-func CompareComments(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+// deletedCommentMarker and hiddenCommentMarker mark the JIRA comments whose
+// GitHub comment was deleted or hidden (see ReconcileComments).
+const (
+	deletedCommentMarker = "_(This comment was deleted on GitHub.)_"
+	hiddenCommentMarker  = "_(This comment was hidden on GitHub.)_"
+)
+
+// CompareComments takes a GitHub issue, and retrieves its comments. It then
+// matches each one to a comment in `existing` (see findComment). If it finds a
+// match, it calls UpdateComment, unless the comment hasn't changed since it was
+// last mirrored; if it doesn't, it calls CreateComment.
+//
+// Once every comment has been mirrored, the latest update time of the comments
+// is recorded in the state, and only the comments updated since then, less the
+// `since` overlap, are retrieved on the next run. Every comment is retrieved
+// when deleted comments are reconciled, since they can only be found that way,
+// and when --compare-all is given. If some comments fail to be updated, the
+// others are still mirrored, and an error listing them is returned, so that
+// the issue is retried.
+//
+// It returns the comments retrieved which were updated since then, so that
+// they can be scanned for references without retrieving them again.
+func CompareComments(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) ([]*github.IssueComment, error) {
 	log := config.GetLogger()
 
-	if ghIssue.GetComments() == 0 {
+	// Without comments on GitHub, there is nothing to do unless the JIRA
+	// comments mirroring deleted ones must be reconciled.
+	if ghIssue.GetComments() == 0 && !config.IsDeletedCommentSyncEnabled() {
 		log.Debugf("Issue #%d has no comments, skipping.", *ghIssue.Number)
-		return nil
+		return nil, nil
 	}
 
-	ghComments, err := ghClient.ListComments(ghIssue)
-	if err != nil {
-		return err
+	var changedSince, since time.Time
+	updated := config.GetCommentsUpdated(ghIssue.GetID())
+	if !updated.IsZero() && !config.IsCompareForced() {
+		changedSince = updated.Add(-config.GetSinceOverlap())
+	}
+	if !config.IsDeletedCommentSyncEnabled() {
+		since = changedSince
+	}
+
+	owner, repo := config.GetRepo()
+	var ghComments []*github.IssueComment
+	if ghIssue.GetComments() > 0 {
+		var err error
+		ghComments, err = ghClient.ListComments(owner, repo, ghIssue, since)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hidden := map[int]bool{}
+	if config.IsHiddenCommentSyncEnabled() && len(ghComments) > 0 {
+		ids, err := ghClient.ListHiddenComments(owner, repo, ghIssue.GetNumber())
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			hidden[id] = true
+		}
 	}
 
 	var jComments []jira.Comment
@@ -48,41 +97,182 @@ func CompareComments(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue,
 		log.Debugf("JIRA issue %s has %d comments", jIssue.Key, len(jComments))
 	}
 
+	var failed []string
+	var changed []*github.IssueComment
 	for _, ghComment := range ghComments {
-		found := false
-		for _, jComment := range jComments {
-			if !jCommentIDRegex.MatchString(jComment.Body) {
+		if ghComment.GetUpdatedAt().After(updated) {
+			updated = ghComment.GetUpdatedAt()
+		}
+		if !ghComment.GetUpdatedAt().Before(changedSince) {
+			changed = append(changed, ghComment)
+		}
+
+		if isBackReferenceComment(config, *ghComment, jIssue.Key) || hidden[ghComment.GetID()] {
+			continue
+		}
+
+		hash := commentHash(config, *ghComment)
+		if jComment, ok := findComment(config, ghIssue, *ghComment, jComments); ok {
+			if _, last, _ := config.GetMirroredComment(ghIssue.GetID(), ghComment.GetID()); last == hash && !config.IsCompareForced() {
 				continue
 			}
-			// matches[0] is the whole string, matches[1] is the ID
+			if err := UpdateComment(config, *ghComment, jComment, jIssue, ghClient, jClient); err != nil {
+				log.Errorf("Error updating JIRA comment %s of issue %s. Error: %v", jComment.ID, jIssue.Key, err)
+				failed = append(failed, jComment.ID)
+				continue
+			}
+			config.SetMirroredComment(ghIssue.GetID(), ghComment.GetID(), jComment.ID, hash)
+			continue
+		}
+
+		converted, err := convertComment(config, *ghComment, jIssue, ghClient, jClient)
+		if err != nil {
+			return nil, err
+		}
+
+		comment, err := jClient.CreateComment(jIssue, converted, ghClient)
+		if err != nil {
+			return nil, err
+		}
+
+		config.SetMirroredComment(ghIssue.GetID(), ghComment.GetID(), comment.ID, hash)
+
+		log.Debugf("Created JIRA comment %s.", comment.ID)
+	}
+
+	if len(failed) == 0 {
+		config.SetCommentsUpdated(ghIssue.GetID(), updated)
+	}
+
+	// Only reconcile if every GitHub comment was retrieved, so that none is
+	// mistaken for a deleted one.
+	if config.IsDeletedCommentSyncEnabled() && len(ghComments) < ghIssue.GetComments() {
+		log.Warnf("Retrieved %d of the %d comments of GitHub issue #%d; not reconciling deleted comments", len(ghComments), ghIssue.GetComments(), ghIssue.GetNumber())
+	} else if config.IsDeletedCommentSyncEnabled() {
+		if err := ReconcileComments(config, ghComments, hidden, jIssue, jComments, jClient); err != nil {
+			return nil, err
+		}
+	}
+
+	// The other comments are mirrored regardless, but the issue must fail
+	// so that it is retried on the next run.
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("failed to update JIRA comments of issue %s: %s", jIssue.Key, strings.Join(failed, ", "))
+	}
+
+	log.Debugf("Copied comments from GH issue #%d to JIRA issue %s.", *ghIssue.Number, jIssue.Key)
+	return changed, nil
+}
+
+// findComment returns the JIRA comment mirroring a GitHub comment: the one
+// recorded in the state, or else the one whose header has the ID of the
+// GitHub comment. It returns false if there is none.
+func findComment(config cfg.Config, ghIssue github.Issue, ghComment github.IssueComment, jComments []jira.Comment) (jira.Comment, bool) {
+	if id, _, ok := config.GetMirroredComment(ghIssue.GetID(), ghComment.GetID()); ok {
+		for _, jComment := range jComments {
+			if jComment.ID == id {
+				return jComment, true
+			}
+		}
+		// The JIRA comment was deleted.
+		config.ForgetMirroredComment(ghIssue.GetID(), ghComment.GetID())
+	}
+
+	for _, jComment := range jComments {
+		// matches[0] is the whole string, matches[1] is the ID
+		matches := jCommentIDRegex.FindStringSubmatch(jComment.Body)
+		if matches == nil {
+			continue
+		}
+		if id, _ := strconv.Atoi(matches[1]); id == ghComment.GetID() {
+			return jComment, true
+		}
+	}
+
+	return jira.Comment{}, false
+}
+
+// ReconcileComments finds the generated JIRA comments whose GitHub comment is
+// no longer in ghComments, because it was deleted, or is in hidden, and deletes,
+// redacts or marks them, as configured. Comments which were already redacted
+// or marked are left alone.
+func ReconcileComments(config cfg.Config, ghComments []*github.IssueComment, hidden map[int]bool, jIssue jira.Issue, jComments []jira.Comment, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	exists := map[int]bool{}
+	for _, c := range ghComments {
+		exists[c.GetID()] = true
+	}
+
+	issueID, _ := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID))
+	mirrored := map[string]int{}
+	for id, jID := range config.GetMirroredComments(int(issueID)) {
+		mirrored[jID] = id
+	}
+
+	for _, jComment := range jComments {
+		id, ok := mirrored[jComment.ID]
+		if !ok {
 			matches := jCommentIDRegex.FindStringSubmatch(jComment.Body)
-			id, _ := strconv.Atoi(matches[1])
-			if *ghComment.ID != id {
+			if matches == nil {
 				continue
 			}
-			found = true
+			id, _ = strconv.Atoi(matches[1])
+		}
 
-			UpdateComment(config, *ghComment, jComment, jIssue, ghClient, jClient)
-			break
+		var marker string
+		switch {
+		case !exists[id]:
+			marker = deletedCommentMarker
+		case hidden[id]:
+			marker = hiddenCommentMarker
+		default:
+			continue
 		}
-		if found {
+		if strings.HasSuffix(jComment.Body, marker) {
 			continue
 		}
 
-		comment, err := jClient.CreateComment(jIssue, *ghComment, ghClient)
+		var err error
+		switch config.GetDeletedCommentAction() {
+		case cfg.DeletedCommentDelete:
+			err = jClient.DeleteComment(jIssue, jComment.ID)
+			if err == nil {
+				config.ForgetMirroredComment(int(issueID), id)
+			}
+		case cfg.DeletedCommentRedact:
+			header := strings.SplitN(jComment.Body, ":\n\n", 2)[0]
+			err = jClient.SetCommentBody(jIssue, jComment.ID, fmt.Sprintf("%s:\n\n%s", header, marker))
+		case cfg.DeletedCommentMark:
+			// A comment which was hidden may have been deleted since, or
+			// the other way around; the marker replaces any previous one.
+			body := stripCommentMarker(jComment.Body)
+			err = jClient.SetCommentBody(jIssue, jComment.ID, fmt.Sprintf("%s\n\n%s", body, marker))
+		}
 		if err != nil {
 			return err
 		}
 
-		log.Debugf("Created JIRA comment %s.", comment.ID)
+		log.Debugf("Applied %s to JIRA comment %s of issue %s: %s", config.GetDeletedCommentAction(), jComment.ID, jIssue.Key, marker)
 	}
 
-	log.Debugf("Copied comments from GH issue #%d to JIRA issue %s.", *ghIssue.Number, jIssue.Key)
 	return nil
 }
 
-// UpdateComment compares the body of a GitHub comment with the body (minus header)
-// of the JIRA comment, and updates the JIRA comment if necessary.
+// stripCommentMarker returns the body of a JIRA comment without the marker
+// ReconcileComments added to it, if any.
+func stripCommentMarker(body string) string {
+	for _, marker := range []string{deletedCommentMarker, hiddenCommentMarker} {
+		if strings.HasSuffix(body, "\n\n"+marker) {
+			return strings.TrimSuffix(body, "\n\n"+marker)
+		}
+	}
+	return body
+}
+
+// UpdateComment compares the body of a GitHub comment, converted to JIRA wiki markup,
+// with the body (minus header) of the JIRA comment, and updates the JIRA comment if
+// necessary.
 func UpdateComment(config cfg.Config, ghComment github.IssueComment, jComment jira.Comment, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
 	log := config.GetLogger()
 
@@ -90,8 +280,20 @@ func UpdateComment(config cfg.Config, ghComment github.IssueComment, jComment ji
 	// 4 is the date, and 5 is the real body
 	fields := jCommentRegex.FindStringSubmatch(jComment.Body)
 
-	if fields[5] == ghComment.GetBody() {
-		return nil
+	ghComment, err := convertComment(config, ghComment, jIssue, ghClient, jClient)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 6 {
+		// The body is cut short along with the header, as the client does.
+		header := fields[0][:len(fields[0])-len(fields[5])]
+		body := header + ghComment.GetBody()
+		if len(body) > clients.MaxBodyLength {
+			body = body[:clients.MaxBodyLength]
+		}
+		if body == jComment.Body {
+			return nil
+		}
 	}
 
 	comment, err := jClient.UpdateComment(jIssue, jComment.ID, ghComment, ghClient)
@@ -99,7 +301,23 @@ func UpdateComment(config cfg.Config, ghComment github.IssueComment, jComment ji
 		return err
 	}
 
-	log.Debug("Updated JIRA comment %s.", comment.ID)
+	log.Debugf("Updated JIRA comment %s.", comment.ID)
 
 	return nil
 }
+
+// convertComment returns a copy of a GitHub comment whose body is converted
+// to JIRA wiki markup by jiraBody, ready to be mirrored to the JIRA issue. The
+// files referenced in the comment are mirrored to the issue first.
+func convertComment(config cfg.Config, comment github.IssueComment, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) (github.IssueComment, error) {
+	if err := MirrorAttachments(config, jIssue, comment.GetBody(), ghClient, jClient); err != nil {
+		return github.IssueComment{}, err
+	}
+
+	body, err := jiraBody(config, comment.GetBody(), jIssue, ghClient, jClient)
+	if err != nil {
+		return github.IssueComment{}, err
+	}
+	comment.Body = github.String(body)
+	return comment, nil
+}
@@ -1,6 +1,19 @@
 package lib
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+	"github.com/trivago/tgo/tcontainer"
+)
 
 func TestJiraCommentRegex(t *testing.T) {
 	var fields = jCommentRegex.FindStringSubmatch(`Comment [(ID 484163403)|https://github.com] from GitHub user [bilbo-baggins|https://github.com/bilbo-baggins] (Bilbo Baggins) at 16:27 PM, April 17 2019:
@@ -31,3 +44,201 @@ Bla blibidy bloo bla`)
 		t.Fatalf("Expected field[5] = Bla blibidy bloo bla; Got field[5] = %s", fields[5])
 	}
 }
+
+func TestJiraCommentRegexMultiline(t *testing.T) {
+	var fields = jCommentRegex.FindStringSubmatch(`Comment [(ID 484163403)|https://github.com] from GitHub user [bilbo-baggins|https://github.com/bilbo-baggins] (Bilbo Baggins) at 16:27 PM, April 17 2019:
+
+Bla blibidy bloo bla
+
+{code}
+bla
+{code}`)
+
+	if len(fields) != 6 {
+		t.Fatalf("Regex failed to parse fields %v", fields)
+	}
+
+	if fields[5] != "Bla blibidy bloo bla\n\n{code}\nbla\n{code}" {
+		t.Fatalf("Expected field[5] to be the whole body; Got field[5] = %q", fields[5])
+	}
+}
+
+func TestCompareCommentsSince(t *testing.T) {
+	first := time.Date(2019, time.April, 17, 16, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	overlap := second.Add(-5 * time.Minute).Format(time.RFC3339)
+	all := time.Time{}.Format(time.RFC3339)
+
+	tests := []struct {
+		name        string
+		settings    map[string]interface{}
+		wantSince   string
+		wantChanged int
+	}{
+		{"incremental", nil, overlap, 1},
+		{"deleted comments", map[string]interface{}{"deleted-comments": map[string]string{"action": "mark"}}, all, 1},
+		{"compare-all", map[string]interface{}{"compare-all": true}, all, 2},
+	}
+
+	for _, test := range tests {
+		settings := map[string]interface{}{"state": map[string]string{"file": testStateFile(t)}}
+		for k, v := range test.settings {
+			settings[k] = v
+		}
+		config := newTestConfig(t, settings, nil)
+		config.SetMirroredIssue(1, "SYNC-1", "")
+
+		ghIssue := github.Issue{ID: github.Int(1), Number: github.Int(1), Comments: github.Int(2)}
+		gh := &fakeGHClient{comments: map[int][]*github.IssueComment{1: {
+			{ID: github.Int(11), Body: github.String("First"), UpdatedAt: &first},
+			{ID: github.Int(12), Body: github.String("Second"), UpdatedAt: &second},
+		}}}
+		header := "Comment [(ID %d)|https://github.com] from GitHub user [alice|https://github.com/alice] (Alice) at 16:27 PM, April 17 2019:\n\n%s"
+		jIssue := jira.Issue{Key: "SYNC-1", Fields: &jira.IssueFields{
+			Unknowns: tcontainer.MarshalMap{config.GetFieldKey(cfg.GitHubID): float64(1)},
+			Comments: &jira.Comments{Comments: []*jira.Comment{
+				{ID: "1", Body: fmt.Sprintf(header, 11, "First")},
+				{ID: "2", Body: fmt.Sprintf(header, 12, "Second")},
+			}},
+		}}
+		j := &fakeJIRAClient{}
+
+		if _, err := CompareComments(config, ghIssue, jIssue, gh, j); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		changed, err := CompareComments(config, ghIssue, jIssue, gh, j)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if want := "ListComments coreos/issue-sync#1 since " + test.wantSince; gh.calls[len(gh.calls)-1] != want {
+			t.Errorf("%s: last call %q; want %q", test.name, gh.calls[len(gh.calls)-1], want)
+		}
+		if len(changed) != test.wantChanged {
+			t.Errorf("%s: %d comments changed; want %d", test.name, len(changed), test.wantChanged)
+		}
+		if len(j.calls) != 0 {
+			t.Errorf("%s: unexpected JIRA calls %q", test.name, j.calls)
+		}
+	}
+}
+
+func TestReconcileCommentsMarkers(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{
+		"deleted-comments": map[string]interface{}{"action": "mark", "hidden": true},
+	}, nil)
+
+	header := "Comment [(ID 11)|https://github.com] from GitHub user [alice|https://github.com/alice] (Alice) at 16:27 PM, April 17 2019:\n\nFirst"
+	deleted := header + "\n\n" + deletedCommentMarker
+	hidden := header + "\n\n" + hiddenCommentMarker
+
+	tests := []struct {
+		name     string
+		body     string
+		exists   bool
+		hidden   bool
+		wantBody string
+	}{
+		{"unchanged", header, true, false, ""},
+		{"deleted", header, false, false, deleted},
+		{"hidden", header, true, true, hidden},
+		{"already deleted", deleted, false, false, ""},
+		{"already hidden", hidden, true, true, ""},
+		{"hidden then deleted", hidden, false, false, deleted},
+		{"deleted then hidden", deleted, true, true, hidden},
+	}
+
+	for _, test := range tests {
+		var ghComments []*github.IssueComment
+		if test.exists {
+			ghComments = append(ghComments, &github.IssueComment{ID: github.Int(11)})
+		}
+		jIssue := testJIRAIssue(config, "SYNC-1", 1)
+		jComments := []jira.Comment{{ID: "1", Body: test.body}}
+		j := &fakeJIRAClient{}
+
+		if err := ReconcileComments(config, ghComments, map[int]bool{11: test.hidden}, jIssue, jComments, j); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		var want []string
+		if test.wantBody != "" {
+			want = append(want, fmt.Sprintf("SetCommentBody SYNC-1 1 %q", test.wantBody))
+		}
+		if !reflect.DeepEqual(j.calls, want) {
+			t.Errorf("%s: got JIRA calls %q; want %q", test.name, j.calls, want)
+		}
+	}
+}
+
+func TestUpdateCommentCutShort(t *testing.T) {
+	config := newTestConfig(t, nil, nil)
+
+	header := "Comment [(ID 11)|https://github.com] from GitHub user [alice|https://github.com/alice] (Alice) at 16:27 PM, April 17 2019:\n\n"
+	long := strings.Repeat("a", clients.MaxBodyLength)
+	mirrored := (header + long)[:clients.MaxBodyLength]
+
+	tests := []struct {
+		name  string
+		jBody string
+		body  string
+		want  int
+	}{
+		{"unchanged", header + "First", "First", 0},
+		{"edited", header + "First", "Edited", 1},
+		{"long unchanged", mirrored, long, 0},
+		{"long edited past the cut", mirrored, long + "b", 0},
+		{"long edited", mirrored, "b" + long, 1},
+	}
+
+	for _, test := range tests {
+		j := &fakeJIRAClient{}
+		ghComment := github.IssueComment{ID: github.Int(11), Body: github.String(test.body)}
+		jComment := jira.Comment{ID: "1", Body: test.jBody}
+
+		if err := UpdateComment(config, ghComment, jComment, testJIRAIssue(config, "SYNC-1", 1), &fakeGHClient{}, j); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := j.count("UpdateComment"); got != test.want {
+			t.Errorf("%s: updated %d times; want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCompareCommentsUpdateFails(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{"state": map[string]string{"file": testStateFile(t)}}, nil)
+	config.SetMirroredIssue(1, "SYNC-1", "")
+
+	updated := time.Date(2019, time.April, 17, 16, 0, 0, 0, time.UTC)
+	ghIssue := github.Issue{ID: github.Int(1), Number: github.Int(1), Comments: github.Int(2)}
+	gh := &fakeGHClient{comments: map[int][]*github.IssueComment{1: {
+		{ID: github.Int(11), Body: github.String("Edited"), UpdatedAt: &updated},
+		{ID: github.Int(12), Body: github.String("New"), UpdatedAt: &updated},
+	}}}
+	header := "Comment [(ID 11)|https://github.com] from GitHub user [alice|https://github.com/alice] (Alice) at 16:27 PM, April 17 2019:\n\n"
+	jIssue := testJIRAIssue(config, "SYNC-1", 1)
+	jIssue.Fields.Comments = &jira.Comments{Comments: []*jira.Comment{{ID: "1", Body: header + "First"}}}
+	j := &fakeJIRAClient{}
+
+	if _, err := CompareComments(config, ghIssue, jIssue, gh, failingUpdateJIRAClient{j}); err == nil {
+		t.Error("comparing the comments didn't fail")
+	}
+	if got := j.count("CreateComment"); got != 1 {
+		t.Errorf("created %d comments; want 1", got)
+	}
+	if _, _, ok := config.GetMirroredComment(1, 11); ok {
+		t.Error("the comment which failed to be updated was recorded")
+	}
+	if got := config.GetCommentsUpdated(1); !got.IsZero() {
+		t.Errorf("comments updated advanced to %v", got)
+	}
+}
+
+// failingUpdateJIRAClient is a fakeJIRAClient failing to update comments.
+type failingUpdateJIRAClient struct {
+	*fakeJIRAClient
+}
+
+func (j failingUpdateJIRAClient) UpdateComment(issue jira.Issue, id string, comment github.IssueComment, ghClient clients.GitHubClient) (jira.Comment, error) {
+	return jira.Comment{}, errors.New("update failed")
+}
@@ -0,0 +1,177 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// eventDateFormat is the format used in generated JIRA event comments.
+const eventDateFormat = "15:04 PM, January 2 2006"
+
+// jEventIDRegex matches the beginning of a JIRA comment generated from a GitHub
+// timeline event, and retrieves the ID of the event (see eventID), in the same
+// way jCommentIDRegex does for comments.
+var jEventIDRegex = regexp.MustCompile("^Event \\[\\(ID ([\\w-]+)\\)\\|")
+
+// CompareEvents retrieves the timeline of a GitHub issue, and adds a JIRA
+// comment for each event of a mirrored type which doesn't have one yet.
+func CompareEvents(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	owner, repo := config.GetRepo()
+	events, err := ghClient.ListTimeline(owner, repo, ghIssue.GetNumber())
+	if err != nil {
+		return err
+	}
+
+	mirrored := map[string]bool{}
+	if jIssue.Fields.Comments != nil {
+		for _, c := range jIssue.Fields.Comments.Comments {
+			if matches := jEventIDRegex.FindStringSubmatch(c.Body); matches != nil {
+				mirrored[matches[1]] = true
+			}
+		}
+	}
+
+	for _, event := range events {
+		if !config.IsTimelineEventMirrored(event.Event) {
+			continue
+		}
+		id := eventID(event)
+		if mirrored[id] {
+			continue
+		}
+		text := describeEvent(event, ghIssue)
+		if text == "" {
+			continue
+		}
+
+		body := fmt.Sprintf("Event [(ID %s)|%s] at %s: %s",
+			id, ghIssue.GetHTMLURL(), event.CreatedAt.Format(eventDateFormat), text)
+		if _, err := jClient.AddComment(jIssue, body); err != nil {
+			return err
+		}
+		mirrored[id] = true
+	}
+
+	log.Debugf("Copied timeline events from GH issue #%d to JIRA issue %s.", ghIssue.GetNumber(), jIssue.Key)
+	return nil
+}
+
+// eventID returns the string identifying a timeline event in its JIRA comment:
+// its ID, or for cross-references, which have none, the time and the issue
+// the reference comes from.
+func eventID(event clients.TimelineEvent) string {
+	if event.ID != 0 {
+		return fmt.Sprint(event.ID)
+	}
+	id := fmt.Sprintf("%s-%d", event.Event, event.CreatedAt.Unix())
+	if event.Source != nil && event.Source.Issue != nil {
+		id = fmt.Sprintf("%s-%d", id, event.Source.Issue.Number)
+	}
+	return id
+}
+
+// describeEvent returns a concise description of a timeline event in JIRA wiki
+// markup, or the empty string if it can't be described.
+func describeEvent(event clients.TimelineEvent, ghIssue github.Issue) string {
+	actor := "Someone"
+	if event.Actor != nil {
+		actor = githubUserLink(*event.Actor)
+	}
+
+	kind := "issue"
+	if isPullRequest(ghIssue) {
+		kind = "pull request"
+	}
+
+	switch event.Event {
+	case "closed":
+		text := fmt.Sprintf("%s closed this %s", actor, kind)
+		if event.StateReason == "not_planned" {
+			text += " as not planned"
+		}
+		if event.CommitID != "" {
+			text += fmt.Sprintf(" in commit {{%s}}", shortCommit(event.CommitID))
+		}
+		return text + "."
+	case "reopened":
+		return fmt.Sprintf("%s reopened this %s.", actor, kind)
+	case "labeled", "unlabeled":
+		if event.Label == nil {
+			return ""
+		}
+		verb := "added"
+		if event.Event == "unlabeled" {
+			verb = "removed"
+		}
+		return fmt.Sprintf("%s %s the label _%s_.", actor, verb, jiraEscaper.Replace(event.Label.Name))
+	case "assigned", "unassigned":
+		if event.Assignee == nil {
+			return ""
+		}
+		if event.Event == "assigned" {
+			return fmt.Sprintf("%s assigned %s.", actor, githubUserLink(*event.Assignee))
+		}
+		return fmt.Sprintf("%s unassigned %s.", actor, githubUserLink(*event.Assignee))
+	case "milestoned", "demilestoned":
+		if event.Milestone == nil {
+			return ""
+		}
+		if event.Event == "milestoned" {
+			return fmt.Sprintf("%s added this to the _%s_ milestone.", actor, jiraEscaper.Replace(event.Milestone.Title))
+		}
+		return fmt.Sprintf("%s removed this from the _%s_ milestone.", actor, jiraEscaper.Replace(event.Milestone.Title))
+	case "renamed":
+		if event.Rename == nil {
+			return ""
+		}
+		return fmt.Sprintf("%s changed the title from _%s_ to _%s_.", actor,
+			jiraEscaper.Replace(event.Rename.From), jiraEscaper.Replace(event.Rename.To))
+	case "referenced":
+		if event.CommitID == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s referenced this %s in commit {{%s}}.", actor, kind, shortCommit(event.CommitID))
+	case "cross-referenced":
+		if event.Source == nil || event.Source.Issue == nil {
+			return ""
+		}
+		source := event.Source.Issue
+		sourceKind := "issue"
+		if source.PullRequest != nil {
+			sourceKind = "pull request"
+		}
+		name := fmt.Sprintf("#%d", source.Number)
+		if source.Repository != nil {
+			name = fmt.Sprintf("%s#%d", source.Repository.FullName, source.Number)
+		}
+		return fmt.Sprintf("%s mentioned this %s in %s [%s|%s]: %s.", actor, kind, sourceKind, name, source.HTMLURL,
+			jiraEscaper.Replace(strings.TrimSpace(source.Title)))
+	case "marked_as_duplicate":
+		return fmt.Sprintf("%s marked this %s as a duplicate.", actor, kind)
+	case "unmarked_as_duplicate":
+		return fmt.Sprintf("%s unmarked this %s as a duplicate.", actor, kind)
+	}
+
+	return ""
+}
+
+// githubUserLink returns a link to the profile of a GitHub user in JIRA wiki markup.
+func githubUserLink(user github.User) string {
+	return fmt.Sprintf("[%s|https://github.com/%s]", user.GetLogin(), user.GetLogin())
+}
+
+// shortCommit returns the abbreviated form of a commit SHA.
+func shortCommit(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// testCrossReference returns a cross-reference timeline event, which has no
+// ID, from the issue with the given number.
+func testCrossReference(t *testing.T, created time.Time, number int) clients.TimelineEvent {
+	b := fmt.Sprintf(`{"event": "cross-referenced", "created_at": %q, "source": {"issue": {
+		"number": %d, "title": "Issue %d", "html_url": "https://github.com/coreos/issue-sync/issues/%d"}}}`,
+		created.Format(time.RFC3339), number, number, number)
+	var event clients.TimelineEvent
+	if err := json.Unmarshal([]byte(b), &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestEventID(t *testing.T) {
+	created := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	unsourced := testCrossReference(t, created, 0)
+	unsourced.Source = nil
+
+	tests := []struct {
+		name  string
+		event clients.TimelineEvent
+		want  string
+	}{
+		{"ID", clients.TimelineEvent{ID: 123, Event: "closed", CreatedAt: created}, "123"},
+		{"cross-reference", testCrossReference(t, created, 42), "cross-referenced-1583064000-42"},
+		{"other source", testCrossReference(t, created, 43), "cross-referenced-1583064000-43"},
+		{"later", testCrossReference(t, created.Add(time.Second), 42), "cross-referenced-1583064001-42"},
+		{"no source", unsourced, "cross-referenced-1583064000"},
+	}
+
+	for _, test := range tests {
+		id := eventID(test.event)
+		if id != test.want {
+			t.Errorf("%s: got ID %q; want %q", test.name, id, test.want)
+		}
+		if body := fmt.Sprintf("Event [(ID %s)|url] at now: text", id); jEventIDRegex.FindStringSubmatch(body)[1] != id {
+			t.Errorf("%s: ID %q is not matched in comments", test.name, id)
+		}
+	}
+}
+
+func TestCompareEvents(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{
+		"timeline": map[string]interface{}{"sync": true},
+	}, nil)
+
+	created := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	actor := &github.User{Login: github.String("alice")}
+	url := "https://github.com/coreos/issue-sync/issues/1"
+	ghIssue := github.Issue{ID: github.Int(1), Number: github.Int(1), HTMLURL: github.String(url)}
+	g := &fakeGHClient{timelines: map[int][]clients.TimelineEvent{1: {
+		{ID: 11, Event: "closed", Actor: actor, CreatedAt: created},
+		{ID: 12, Event: "labeled", Actor: actor, CreatedAt: created},
+		{ID: 13, Event: "reopened", Actor: actor, CreatedAt: created},
+		testCrossReference(t, created, 42),
+		testCrossReference(t, created, 43),
+		{ID: 14, Event: "assigned", Actor: actor, CreatedAt: created},
+	}}}
+	j := &fakeJIRAClient{}
+
+	jIssue := testJIRAIssue(config, "SYNC-1", 1)
+	jIssue.Fields.Comments = &jira.Comments{Comments: []*jira.Comment{
+		{ID: "1", Body: "Event [(ID 11)|" + url + "] at 12:00 PM, March 1 2020: alice closed this issue."},
+		{ID: "2", Body: "Event (ID 13) was mentioned in a comment."},
+	}}
+
+	at := created.Format(eventDateFormat)
+	want := []string{
+		fmt.Sprintf("AddComment SYNC-1 %q", "Event [(ID 13)|"+url+"] at "+at+": [alice|https://github.com/alice] reopened this issue."),
+		fmt.Sprintf("AddComment SYNC-1 %q", "Event [(ID cross-referenced-1583064000-42)|"+url+"] at "+at+
+			": Someone mentioned this issue in issue [#42|https://github.com/coreos/issue-sync/issues/42]: Issue 42."),
+		fmt.Sprintf("AddComment SYNC-1 %q", "Event [(ID cross-referenced-1583064000-43)|"+url+"] at "+at+
+			": Someone mentioned this issue in issue [#43|https://github.com/coreos/issue-sync/issues/43]: Issue 43."),
+	}
+
+	// The second run finds the comments added by the first.
+	for run := 1; run <= 2; run++ {
+		if err := CompareEvents(config, ghIssue, jIssue, g, j); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if !reflect.DeepEqual(j.calls, want) {
+			t.Errorf("run %d: got JIRA calls %q; want %q", run, j.calls, want)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+	"github.com/spf13/cobra"
+	"github.com/trivago/tgo/tcontainer"
+)
+
+// testFields are the custom fields served by the fake JIRA server of
+// newTestConfig, by name.
+var testFields = map[string]int{
+	"GitHub ID":              10001,
+	"GitHub Number":          10002,
+	"GitHub Labels":          10003,
+	"GitHub Status":          10004,
+	"GitHub Reporter":        10005,
+	"Last Issue-Sync Update": 10006,
+}
+
+// newTestConfig returns a configuration for the repository coreos/issue-sync
+// mirrored to the JIRA project SYNC, with the given configuration parameters
+// added. The JIRA configuration is loaded from a fake JIRA server, which
+// serves the given JSON responses, by path, besides the project and fields.
+// Nothing is logged.
+func newTestConfig(t *testing.T, settings map[string]interface{}, responses map[string]interface{}) cfg.Config {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	all := map[string]interface{}{
+		"github-token": "token",
+		"jira-user":    "user",
+		"jira-pass":    "pass",
+		"jira-uri":     "https://jira.example.com",
+		"repo-name":    "coreos/issue-sync",
+		"jira-project": "SYNC",
+		"log-level":    "panic",
+	}
+	for k, v := range settings {
+		all[k] = v
+	}
+	b, err := json.Marshal(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(file, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("config", file, "")
+	cmd.Flags().Bool("dry-run", false, "")
+	cmd.Flags().Bool("rebuild-state", false, "")
+	cmd.Flags().Bool("compare-all", false, "")
+
+	config, err := cfg.NewConfig(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields []map[string]interface{}
+	for name, id := range testFields {
+		fields = append(fields, map[string]interface{}{
+			"id":     fmt.Sprintf("customfield_%d", id),
+			"name":   name,
+			"custom": true,
+			"schema": map[string]interface{}{"customId": id},
+		})
+	}
+	served := map[string]interface{}{
+		"/rest/api/2/project/SYNC": map[string]string{"id": "1", "key": "SYNC"},
+		"/rest/api/2/field":        fields,
+	}
+	for path, response := range responses {
+		served[path] = response
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := served[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadJIRAConfig(*client); err != nil {
+		t.Fatal(err)
+	}
+
+	return config.WithLogOutput(ioutil.Discard)
+}
+
+// testJIRAIssue returns a JIRA issue with the given key, mirroring the GitHub
+// issue with the given ID.
+func testJIRAIssue(config cfg.Config, key string, id int) jira.Issue {
+	return jira.Issue{Key: key, Fields: &jira.IssueFields{
+		Unknowns: tcontainer.MarshalMap{config.GetFieldKey(cfg.GitHubID): float64(id)},
+	}}
+}
+
+// fakeGHClient is a GitHubClient serving issues, comments and users from
+// memory. The methods which aren't implemented panic.
+type fakeGHClient struct {
+	clients.GitHubClient
+
+	lock     sync.Mutex
+	issues   []github.Issue
+	comments map[int][]*github.IssueComment
+	users    map[string]github.User
+	pulls    map[int]github.PullRequest
+	reasons  map[int]string
+	reviews  map[int][]github.PullRequestReview
+	// milestones are the milestones of the repository.
+	milestones []github.Milestone
+	// timelines maps the number of each issue to its timeline.
+	timelines map[int][]clients.TimelineEvent
+	// calls records the calls made, e.g. "GetUser alice".
+	calls []string
+}
+
+func (g *fakeGHClient) record(format string, args ...interface{}) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.calls = append(g.calls, fmt.Sprintf(format, args...))
+}
+
+// count returns the number of calls recorded starting with prefix.
+func (g *fakeGHClient) count(prefix string) int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	n := 0
+	for _, call := range g.calls {
+		if strings.HasPrefix(call, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func (g *fakeGHClient) ListIssues(owner, repo string, since time.Time) ([]github.Issue, error) {
+	g.record("ListIssues %s/%s", owner, repo)
+	var issues []github.Issue
+	for _, issue := range g.issues {
+		if !issue.GetUpdatedAt().Before(since) {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+func (g *fakeGHClient) GetIssue(owner, repo string, number int) (github.Issue, error) {
+	g.record("GetIssue %s/%s#%d", owner, repo, number)
+	for _, issue := range g.issues {
+		if issue.GetNumber() == number {
+			return issue, nil
+		}
+	}
+	return github.Issue{}, clients.ErrIssueNotFound
+}
+
+func (g *fakeGHClient) ListComments(owner, repo string, issue github.Issue, since time.Time) ([]*github.IssueComment, error) {
+	g.record("ListComments %s/%s#%d since %s", owner, repo, issue.GetNumber(), since.Format(time.RFC3339))
+	var comments []*github.IssueComment
+	for _, comment := range g.comments[issue.GetNumber()] {
+		if !comment.GetUpdatedAt().Before(since) {
+			comments = append(comments, comment)
+		}
+	}
+	return comments, nil
+}
+
+func (g *fakeGHClient) GetUser(login string) (github.User, error) {
+	g.record("GetUser %s", login)
+	user, ok := g.users[strings.ToLower(login)]
+	if !ok {
+		return github.User{}, clients.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (g *fakeGHClient) AddLabel(owner, repo string, number int, label string) error {
+	g.record("AddLabel %s/%s#%d %s", owner, repo, number, label)
+	return nil
+}
+
+func (g *fakeGHClient) GetStateReason(owner, repo string, number int) (string, error) {
+	g.record("GetStateReason %s/%s#%d", owner, repo, number)
+	return g.reasons[number], nil
+}
+
+func (g *fakeGHClient) GetPullRequest(owner, repo string, number int) (github.PullRequest, error) {
+	g.record("GetPullRequest %s/%s#%d", owner, repo, number)
+	return g.pulls[number], nil
+}
+
+func (g *fakeGHClient) ListReviews(owner, repo string, number int) ([]github.PullRequestReview, error) {
+	g.record("ListReviews %s/%s#%d", owner, repo, number)
+	return g.reviews[number], nil
+}
+
+func (g *fakeGHClient) ListMilestones(owner, repo string) ([]github.Milestone, error) {
+	g.record("ListMilestones %s/%s", owner, repo)
+	return g.milestones, nil
+}
+
+func (g *fakeGHClient) ListTimeline(owner, repo string, number int) ([]clients.TimelineEvent, error) {
+	g.record("ListTimeline %s/%s#%d", owner, repo, number)
+	return g.timelines[number], nil
+}
+
+// fakeJIRAClient is a JIRAClient keeping issues in memory. The methods which
+// aren't implemented panic.
+type fakeJIRAClient struct {
+	clients.JIRAClient
+
+	lock   sync.Mutex
+	issues []jira.Issue
+	users  []jira.User
+	// links maps the key of each issue to its remote links.
+	links map[string][]clients.RemoteLink
+	// workflow maps each status to the statuses it has transitions to, and
+	// statuses the key of each issue transitioned to its new status.
+	workflow map[string][]string
+	statuses map[string]string
+	// created is the number of issues created.
+	created int
+	// versions are the versions of the project.
+	versions []jira.Version
+	// calls records the calls which change something, e.g.
+	// "LinkIssues Relates SYNC-1 SYNC-2".
+	calls []string
+}
+
+func (j *fakeJIRAClient) record(format string, args ...interface{}) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.calls = append(j.calls, fmt.Sprintf(format, args...))
+}
+
+func (j *fakeJIRAClient) SearchUsers(query string) ([]jira.User, error) {
+	var users []jira.User
+	for _, u := range j.users {
+		if strings.EqualFold(u.EmailAddress, query) {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (j *fakeJIRAClient) ListMirroredIssues(project string) ([]jira.Issue, error) {
+	return j.issues, nil
+}
+
+func (j *fakeJIRAClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
+	j.record("UpdateIssue %s", issue.Key)
+	return issue, nil
+}
+
+// count returns the number of calls recorded starting with prefix.
+func (j *fakeJIRAClient) count(prefix string) int {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	n := 0
+	for _, call := range j.calls {
+		if strings.HasPrefix(call, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func (j *fakeJIRAClient) ListRemoteLinks(issue jira.Issue) ([]clients.RemoteLink, error) {
+	return j.links[issue.Key], nil
+}
+
+func (j *fakeJIRAClient) ListIssues(project string, ids []int) ([]jira.Issue, error) {
+	wanted := map[int64]bool{}
+	for _, id := range ids {
+		wanted[int64(id)] = true
+	}
+	var issues []jira.Issue
+	for _, issue := range j.issues {
+		if id, err := issue.Fields.Unknowns.Int(fmt.Sprintf("customfield_%d", testFields["GitHub ID"])); err == nil && wanted[id] {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+func (j *fakeJIRAClient) CreateComment(issue jira.Issue, comment github.IssueComment, ghClient clients.GitHubClient) (jira.Comment, error) {
+	j.record("CreateComment %s %d", issue.Key, comment.GetID())
+	return jira.Comment{ID: fmt.Sprintf("%d", 100+comment.GetID()), Body: comment.GetBody()}, nil
+}
+
+func (j *fakeJIRAClient) UpdateComment(issue jira.Issue, id string, comment github.IssueComment, ghClient clients.GitHubClient) (jira.Comment, error) {
+	j.record("UpdateComment %s %s", issue.Key, id)
+	return jira.Comment{ID: id, Body: comment.GetBody()}, nil
+}
+
+// AddComment adds the comment to the issue passed, which the caller sees on
+// its next call.
+func (j *fakeJIRAClient) AddComment(issue jira.Issue, body string) (jira.Comment, error) {
+	j.record("AddComment %s %q", issue.Key, body)
+	if issue.Fields.Comments == nil {
+		issue.Fields.Comments = &jira.Comments{}
+	}
+	comment := &jira.Comment{ID: fmt.Sprint(len(issue.Fields.Comments.Comments) + 1), Body: body}
+	issue.Fields.Comments.Comments = append(issue.Fields.Comments.Comments, comment)
+	return *comment, nil
+}
+
+func (j *fakeJIRAClient) SetCommentBody(issue jira.Issue, id string, body string) error {
+	j.record("SetCommentBody %s %s %q", issue.Key, id, body)
+	return nil
+}
+
+// CreateIssue keeps the issue, with the next key from SYNC-101 and the status
+// To Do.
+func (j *fakeJIRAClient) CreateIssue(issue jira.Issue) (jira.Issue, error) {
+	j.record("CreateIssue %s", issue.Fields.Summary)
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.created++
+	issue.Key = fmt.Sprintf("SYNC-%d", 100+j.created)
+	fields := *issue.Fields
+	fields.Status = &jira.Status{Name: "To Do"}
+	issue.Fields = &fields
+	j.issues = append(j.issues, issue)
+	return issue, nil
+}
+
+func (j *fakeJIRAClient) ListVersions(project string) ([]jira.Version, error) {
+	return j.versions, nil
+}
+
+func (j *fakeJIRAClient) CreateVersion(project string, version jira.Version) (jira.Version, error) {
+	j.record("CreateVersion %s %s %q released=%t", project, version.Name, version.ReleaseDate, version.Released)
+	return version, nil
+}
+
+func (j *fakeJIRAClient) UpdateVersion(version jira.Version) (jira.Version, error) {
+	j.record("UpdateVersion %s %q released=%t", version.Name, version.ReleaseDate, version.Released)
+	return version, nil
+}
+
+func (j *fakeJIRAClient) GetIssue(key string) (jira.Issue, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	for _, issue := range j.issues {
+		if issue.Key == key {
+			return issue, nil
+		}
+	}
+	return jira.Issue{}, fmt.Errorf("no JIRA issue %s", key)
+}
+
+// GetTransitions returns a transition to each status the workflow leads to
+// from the current status of the issue; those to Done set the resolution.
+func (j *fakeJIRAClient) GetTransitions(issue jira.Issue) ([]clients.Transition, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	current, ok := j.statuses[issue.Key]
+	if !ok {
+		current = issue.Fields.Status.Name
+	}
+	var transitions []clients.Transition
+	for _, status := range j.workflow[current] {
+		t := clients.Transition{ID: status, Name: status, To: jira.Status{Name: status}}
+		if status == "Done" {
+			t.Fields = map[string]jira.TransitionField{"resolution": {}}
+		}
+		transitions = append(transitions, t)
+	}
+	return transitions, nil
+}
+
+func (j *fakeJIRAClient) DoTransition(issue jira.Issue, transition clients.Transition, resolution string) error {
+	if resolution != "" {
+		j.record("DoTransition %s %s (%s)", issue.Key, transition.To.Name, resolution)
+	} else {
+		j.record("DoTransition %s %s", issue.Key, transition.To.Name)
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.statuses == nil {
+		j.statuses = map[string]string{}
+	}
+	j.statuses[issue.Key] = transition.To.Name
+	return nil
+}
+
+func (j *fakeJIRAClient) SetRemoteLink(issue jira.Issue, link clients.RemoteLink) error {
+	j.record("SetRemoteLink %s %s %s resolved=%t", issue.Key, link.Object.Title,
+		link.Object.Status.Icon.Title, link.Object.Status.Resolved)
+	return nil
+}
+
+func (j *fakeJIRAClient) LinkIssues(linkType string, from, to jira.Issue) error {
+	j.record("LinkIssues %s %s %s", linkType, from.Key, to.Key)
+	return nil
+}
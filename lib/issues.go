@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
@@ -13,62 +14,264 @@ import (
 // dateFormat is the format used for the Last IS Update field
 const dateFormat = "2006-01-02T15:04:05.0-0700"
 
-// CompareIssues gets the list of GitHub issues updated since the `since` date,
-// gets the list of JIRA issues which have GitHub ID custom fields in that list,
-// then matches each one. If a JIRA issue already exists for a given GitHub issue,
-// it calls UpdateIssue; if no JIRA issue already exists, it calls CreateIssue.
+// CompareIssues synchronizes each of the configured GitHub repositories in
+// turn (see CompareRepoIssues). An error synchronizing one repository is
+// logged and does not stop the others, and leaves the `since` parameter of
+// that repository unchanged. The state is rebuilt
+// from JIRA first if needed (see RebuildState); then, orphaned JIRA issues are
+// reconciled if configured to (see ReconcileOrphans).
 func CompareIssues(config cfg.Config, ghClient clients.GitHubClient, jiraClient clients.JIRAClient) error {
 	log := config.GetLogger()
 
+	if config.IsStateRebuildDue() {
+		if err := RebuildState(config, jiraClient); err != nil {
+			log.Errorf("Error rebuilding the state. Error: %v", err)
+		} else {
+			config.SetStateRebuilt()
+		}
+	}
+
+	config.ResetIssueLinks()
+
+	var failed []string
+	for _, repoConfig := range config.Repos() {
+		owner, repo := repoConfig.GetRepo()
+
+		if err := CompareRepoIssues(repoConfig, ghClient, jiraClient); err != nil {
+			log.Errorf("Error synchronizing repository %s/%s. Error: %v", owner, repo, err)
+			failed = append(failed, fmt.Sprintf("%s/%s", owner, repo))
+		}
+	}
+
+	if config.IsOrphanSyncEnabled() {
+		if err := ReconcileOrphans(config, ghClient, jiraClient); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to synchronize repositories: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// CompareRepoIssues gets the list of GitHub issues updated since the `since` date
+// on the repository the configuration is scoped to, along with the issues which
+// failed to be synchronized on the previous run, gets the list of JIRA issues
+// which have GitHub ID custom fields in that list, then matches each one. If a JIRA
+// issue already exists for a given GitHub issue, it calls UpdateIssue; if no JIRA
+// issue already exists, it calls CreateIssue. The JIRA issues recorded in the
+// state are retrieved by key, and the others by GitHub ID.
+//
+// The issues are synchronized by the configured number of workers in parallel
+// (see forEachIssue). Afterwards, `since` is advanced past the issues which
+// were synchronized successfully, and the issues which failed are recorded to
+// be retried on the next run (see recordProgress).
+func CompareRepoIssues(config cfg.Config, ghClient clients.GitHubClient, jiraClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
 	log.Debug("Collecting issues")
 
-	ghIssues, err := ghClient.ListIssues()
+	owner, repo := config.GetRepo()
+	ghIssues, err := ghClient.ListIssues(owner, repo, config.GetSinceParam())
+	if err != nil {
+		return err
+	}
+
+	ghIssues, err = addRetryIssues(config, ghIssues, ghClient)
 	if err != nil {
 		return err
 	}
 
+	if config.IsMilestoneSyncEnabled() {
+		if err := SyncMilestones(&config, ghIssues, ghClient, jiraClient); err != nil {
+			return err
+		}
+	}
+
 	if len(ghIssues) == 0 {
 		log.Info("There are no GitHub issues; exiting")
+		config.SetRetryIssues(nil)
 		return nil
 	}
 
-	ids := make([]int, len(ghIssues))
-	for i, v := range ghIssues {
-		ids[i] = v.GetID()
-	}
-
-	jiraIssues, err := jiraClient.ListIssues(ids)
+	jiraIssues, err := listMirroringIssues(config, ghIssues, jiraClient)
 	if err != nil {
 		return err
 	}
 
 	log.Debug("Collected all JIRA issues")
 
-	for _, ghIssue := range ghIssues {
-		found := false
-		for _, jIssue := range jiraIssues {
-			id, _ := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID))
-			if int64(*ghIssue.ID) == id {
-				found = true
-				if err := UpdateIssue(config, ghIssue, jIssue, ghClient, jiraClient); err != nil {
-					log.Errorf("Error updating issue %s. Error: %v", jIssue.Key, err)
-				}
-				break
+	errs := forEachIssue(config, ghIssues, func(issueConfig cfg.Config, ghIssue github.Issue) error {
+		return compareIssue(issueConfig, ghIssue, jiraIssues, ghClient, jiraClient)
+	})
+
+	recordProgress(config, ghIssues, errs)
+
+	return nil
+}
+
+// recordProgress advances `since` to the latest update of the GitHub issues
+// which were synchronized successfully, less the configured overlap, and
+// records the issues which failed, given the error synchronizing each issue,
+// to be retried on the next run.
+func recordProgress(config cfg.Config, ghIssues []github.Issue, errs []error) {
+	log := config.GetLogger()
+
+	since := config.GetSinceParam()
+	var failed []int
+	for i, ghIssue := range ghIssues {
+		if errs[i] != nil {
+			failed = append(failed, ghIssue.GetNumber())
+			continue
+		}
+
+		if updated := ghIssue.GetUpdatedAt().Add(-config.GetSinceOverlap()); updated.After(since) {
+			since = updated
+		}
+	}
+
+	if len(failed) > 0 {
+		log.Warnf("%d GitHub issues failed to synchronize and will be retried on the next run", len(failed))
+	}
+	config.SetSinceParam(since)
+	config.SetRetryIssues(failed)
+}
+
+// compareIssue synchronizes a single GitHub issue with the JIRA issue
+// mirroring it among jiraIssues, if any (see CompareRepoIssues). Errors are
+// logged before being returned.
+func compareIssue(config cfg.Config, ghIssue github.Issue, jiraIssues []jira.Issue, ghClient clients.GitHubClient, jiraClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	owner, repo := config.GetRepo()
+	config.SetIssueID(owner+"/"+repo, ghIssue.GetNumber(), ghIssue.GetID())
+
+	if isPullRequest(ghIssue) && config.GetPullRequestSync() == cfg.PullRequestSyncLinks {
+		err := LinkPullRequest(config, ghIssue, ghClient, jiraClient)
+		if err != nil {
+			log.Errorf("Error linking pull request #%d. Error: %v", ghIssue.GetNumber(), err)
+		}
+		return err
+	}
+
+	for _, jIssue := range jiraIssues {
+		id, _ := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID))
+		if int64(*ghIssue.ID) == id {
+			err := UpdateIssue(config, ghIssue, jIssue, ghClient, jiraClient)
+			if err != nil {
+				log.Errorf("Error updating issue %s. Error: %v", jIssue.Key, err)
 			}
+			return err
 		}
-		if !found {
-			if err := CreateIssue(config, ghIssue, ghClient, jiraClient); err != nil {
-				log.Errorf("Error creating issue for #%d. Error: %v", *ghIssue.Number, err)
+	}
+
+	err := CreateIssue(config, ghIssue, ghClient, jiraClient)
+	if err != nil {
+		log.Errorf("Error creating issue for #%d. Error: %v", *ghIssue.Number, err)
+	}
+	return err
+}
+
+// addRetryIssues returns ghIssues with the GitHub issues which failed to be
+// synchronized on the previous run appended, unless they are already listed.
+// Issues which can no longer be found, or were transferred to another
+// repository, are dropped; they are reconciled as orphans if configured to.
+func addRetryIssues(config cfg.Config, ghIssues []github.Issue, ghClient clients.GitHubClient) ([]github.Issue, error) {
+	log := config.GetLogger()
+
+	owner, repo := config.GetRepo()
+
+	listed := map[int]bool{}
+	for _, ghIssue := range ghIssues {
+		listed[ghIssue.GetNumber()] = true
+	}
+
+	for _, number := range config.GetRetryIssues() {
+		if listed[number] {
+			continue
+		}
+		listed[number] = true
+
+		ghIssue, err := ghClient.GetIssue(owner, repo, number)
+		if err == clients.ErrIssueDeleted || err == clients.ErrIssueNotFound {
+			log.Debugf("GitHub issue #%d to retry can't be found", number)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if newOwner, newRepo := issueRepo(ghIssue); !strings.EqualFold(newOwner, owner) || !strings.EqualFold(newRepo, repo) {
+			log.Debugf("GitHub issue #%d to retry was transferred", number)
+			continue
+		}
+		if isPullRequest(ghIssue) && !config.IsPullRequestSyncEnabled() {
+			continue
+		}
+
+		log.Debugf("Retrying GitHub issue #%d", number)
+		ghIssues = append(ghIssues, ghIssue)
+	}
+
+	return ghIssues, nil
+}
+
+// listMirroringIssues returns the JIRA issues mirroring the given GitHub issues.
+// Those recorded in the state are retrieved by key; the others, and those whose
+// JIRA issue no longer mirrors them, are looked up by GitHub ID.
+func listMirroringIssues(config cfg.Config, ghIssues []github.Issue, jiraClient clients.JIRAClient) ([]jira.Issue, error) {
+	var keys []string
+	for _, ghIssue := range ghIssues {
+		if key, _, ok := config.GetMirroredIssue(ghIssue.GetID()); ok {
+			keys = append(keys, key)
+		}
+	}
+
+	var jiraIssues []jira.Issue
+	found := map[int]bool{}
+	if len(keys) > 0 {
+		known, err := jiraClient.ListIssuesByKey(keys)
+		if err != nil {
+			return nil, err
+		}
+		for _, jIssue := range known {
+			id, err := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID))
+			if err != nil {
+				continue
+			}
+			if key, _, _ := config.GetMirroredIssue(int(id)); key == jIssue.Key {
+				found[int(id)] = true
+				jiraIssues = append(jiraIssues, jIssue)
 			}
 		}
 	}
 
-	return nil
+	var ids []int
+	for _, ghIssue := range ghIssues {
+		if found[ghIssue.GetID()] {
+			continue
+		}
+		config.ForgetMirroredIssue(ghIssue.GetID())
+		ids = append(ids, ghIssue.GetID())
+	}
+	if len(ids) == 0 {
+		return jiraIssues, nil
+	}
+
+	unknown, err := jiraClient.ListIssues(config.GetProjectKey(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(jiraIssues, unknown...), nil
 }
 
 // DidIssueChange tests each of the relevant fields on the provided JIRA and GitHub issue
-// and returns whether or not they differ.
-func DidIssueChange(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue) bool {
+// and returns whether or not they differ. The description of the JIRA issue is compared
+// with the body of the GitHub issue converted to JIRA wiki markup, given as description,
+// and its GitHub Status field with the status of the GitHub issue (see githubStatus).
+func DidIssueChange(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, description, status string) bool {
 	log := config.GetLogger()
 
 	log.Debugf("Comparing GitHub issue #%d and JIRA issue %s", ghIssue.GetNumber(), jIssue.Key)
@@ -76,11 +279,15 @@ func DidIssueChange(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue)
 	anyDifferent := false
 
 	anyDifferent = anyDifferent || (ghIssue.GetTitle() != jIssue.Fields.Summary)
-	anyDifferent = anyDifferent || (ghIssue.GetBody() != jIssue.Fields.Description)
+	anyDifferent = anyDifferent || (description != jIssue.Fields.Description)
+
+	if config.IsIssueTypeUpdated() && !strings.EqualFold(issueType(config, ghIssue), jIssue.Fields.Type.Name) {
+		anyDifferent = true
+	}
 
 	key := config.GetFieldKey(cfg.GitHubStatus)
 	field, err := jIssue.Fields.Unknowns.String(key)
-	if err != nil || *ghIssue.State != field {
+	if err != nil || status != field {
 		anyDifferent = true
 	}
 
@@ -90,25 +297,34 @@ func DidIssueChange(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue)
 		anyDifferent = true
 	}
 
-	labels := make([]string, len(ghIssue.Labels))
-	for i, l := range ghIssue.Labels {
-		labels[i] = *l.Name
-	}
-
 	key = config.GetFieldKey(cfg.GitHubLabels)
 	field, err = jIssue.Fields.Unknowns.String(key)
-	if err != nil && strings.Join(labels, ",") != field {
+	if err != nil || strings.Join(githubLabels(ghIssue), ",") != field {
 		anyDifferent = true
 	}
 
-	log.Debugf("Issues have any differences: %b", anyDifferent)
+	if config.IsLabelSyncEnabled() && didLabelsChange(config, ghIssue, jIssue) {
+		anyDifferent = true
+	}
+
+	if config.IsMilestoneSyncEnabled() && didFixVersionsChange(config, ghIssue, jIssue) {
+		anyDifferent = true
+	}
+
+	if didPriorityChange(config, ghIssue, jIssue) {
+		anyDifferent = true
+	}
+
+	log.Debugf("Issues have any differences: %t", anyDifferent)
 
 	return anyDifferent
 }
 
 // UpdateIssue compares each field of a GitHub issue to a JIRA issue; if any of them
 // differ, the differing fields of the JIRA issue are updated to match the GitHub
-// issue.
+// issue. The fields aren't compared if the content of the GitHub issue, and the
+// configuration it is mirrored with, haven't changed since it was last mirrored,
+// as recorded in the state, unless --compare-all is given.
 func UpdateIssue(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
 	log := config.GetLogger()
 
@@ -116,35 +332,157 @@ func UpdateIssue(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghC
 
 	var issue jira.Issue
 
-	if DidIssueChange(config, ghIssue, jIssue) {
+	status, err := githubStatus(config, ghIssue, ghClient)
+	if err != nil {
+		return err
+	}
+
+	// Transition before the GitHub Status field is updated, so that a failed
+	// transition is retried on the next run. The JIRA issue is also moved if
+	// its status disagrees with the state of the GitHub issue, e.g. because a
+	// run failed after creating it, or the GitHub issue was closed before
+	// transitions were configured.
+	previousState, _ := jIssue.Fields.Unknowns.String(config.GetFieldKey(cfg.GitHubStatus))
+	if previousState != status || !statusAgrees(ghIssue, jIssue) {
+		if err := TransitionIssue(config, ghIssue, jIssue, ghClient, jClient); err != nil {
+			return err
+		}
+	}
+
+	changed, err := updateIssueFields(config, ghIssue, jIssue, status, ghClient, jClient)
+	if err != nil {
+		return err
+	}
+
+	issue, err = jClient.GetIssue(jIssue.Key)
+	if err != nil {
+		log.Debugf("Failed to retrieve JIRA issue %s!", jIssue.Key)
+		return err
+	}
+
+	if err := SyncRemoteLink(config, ghIssue, issue, status, jClient); err != nil {
+		return err
+	}
+
+	if config.IsBackReferenceEnabled() {
+		if err := writePendingBackReference(config, ghIssue, issue, ghClient); err != nil {
+			return err
+		}
+	}
+
+	comments, err := CompareComments(config, ghIssue, issue, ghClient, jClient)
+	if err != nil {
+		return err
+	}
+
+	if config.IsTimelineSyncEnabled() {
+		if err := CompareEvents(config, ghIssue, issue, ghClient, jClient); err != nil {
+			return err
+		}
+	}
+
+	if config.IsTaskListSyncEnabled() {
+		if err := SyncTaskList(config, ghIssue, issue, ghClient, jClient); err != nil {
+			return err
+		}
+	}
+
+	// References are only looked for again in what changed: links are only
+	// ever added.
+	if config.IsReferenceSyncEnabled() && (changed || len(comments) > 0) {
+		if err := LinkReferences(config, ghIssue, issue, changed, comments, ghClient, jClient); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateIssueFields compares each field of a GitHub issue to a JIRA issue; if any
+// of them differ, the differing fields of the JIRA issue are updated to match the
+// GitHub issue. Nothing is compared if the hash of the content of the GitHub
+// issue matches the one recorded when it was last mirrored; it returns whether
+// the fields were compared.
+func updateIssueFields(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, status string, ghClient clients.GitHubClient, jClient clients.JIRAClient) (bool, error) {
+	log := config.GetLogger()
+
+	var err error
+	var reporter, assignee string
+	usersChanged := false
+	if config.IsUserMappingEnabled() {
+		if reporter, err = issueReporter(config, ghIssue, ghClient, jClient); err != nil {
+			return false, err
+		}
+		if assignee, err = issueAssignee(config, ghIssue, ghClient, jClient); err != nil {
+			return false, err
+		}
+		usersChanged = (reporter != "" && !isJIRAUser(jIssue.Fields.Reporter, reporter)) ||
+			(assignee != "" && !isJIRAUser(jIssue.Fields.Assignee, assignee)) ||
+			(len(ghIssue.Assignees) == 0 && jIssue.Fields.Assignee != nil)
+	}
+
+	if err := MirrorAttachments(config, jIssue, ghIssue.GetBody(), ghClient, jClient); err != nil {
+		return false, err
+	}
+
+	body := stripBackReference(config, ghIssue.GetBody(), jIssue.Key)
+	description, err := jiraBody(config, body, jIssue, ghClient, jClient)
+	if err != nil {
+		return false, err
+	}
+
+	hash := issueHash(config, ghIssue, status, description, reporter, assignee)
+	if _, last, _ := config.GetMirroredIssue(ghIssue.GetID()); last == hash && !config.IsCompareForced() {
+		log.Debugf("GitHub issue #%d hasn't changed since it was last mirrored", ghIssue.GetNumber())
+		return false, nil
+	}
+
+	if DidIssueChange(config, ghIssue, jIssue, description, status) || usersChanged {
 		fields := jira.IssueFields{}
 		fields.Unknowns = map[string]interface{}{}
 
 		fields.Summary = ghIssue.GetTitle()
-		fields.Description = ghIssue.GetBody()
-		fields.Unknowns[config.GetFieldKey(cfg.GitHubStatus)] = ghIssue.GetState()
+		fields.Description = description
+		fields.Unknowns[config.GetFieldKey(cfg.GitHubStatus)] = status
 		fields.Unknowns[config.GetFieldKey(cfg.GitHubReporter)] = ghIssue.User.GetLogin()
 
-		labels := make([]string, len(ghIssue.Labels))
-		for i, l := range ghIssue.Labels {
-			labels[i] = l.GetName()
+		fields.Unknowns[config.GetFieldKey(cfg.GitHubLabels)] = strings.Join(githubLabels(ghIssue), ",")
+		if config.IsLabelSyncEnabled() {
+			labels, components := syncLabels(config, ghIssue, jIssue)
+			setLabelFields(&fields, labels, components)
+		}
+		if config.IsMilestoneSyncEnabled() {
+			setFixVersionFields(&fields, syncFixVersions(config, ghIssue, jIssue))
 		}
-		fields.Unknowns[config.GetFieldKey(cfg.GitHubLabels)] = strings.Join(labels, ",")
 
 		fields.Unknowns[config.GetFieldKey(cfg.LastISUpdate)] = time.Now().Format(dateFormat)
 
+		if reporter != "" {
+			fields.Reporter = &jira.User{Name: reporter}
+		}
+		if config.IsUserMappingEnabled() {
+			setAssignee(&fields, ghIssue, assignee)
+		}
+		if priority := issuePriority(config, ghIssue); priority != "" {
+			fields.Priority = &jira.Priority{Name: priority}
+		}
+
 		fields.Type = jIssue.Fields.Type
+		if config.IsIssueTypeUpdated() {
+			if name := issueType(config, ghIssue); !strings.EqualFold(name, jIssue.Fields.Type.Name) {
+				log.Debugf("Changing type of JIRA issue %s from %s to %s", jIssue.Key, jIssue.Fields.Type.Name, name)
+				fields.Type = jira.IssueType{Name: name}
+			}
+		}
 
-		issue = jira.Issue{
+		issue := jira.Issue{
 			Fields: &fields,
 			Key:    jIssue.Key,
 			ID:     jIssue.ID,
 		}
 
-		var err error
-		issue, err = jClient.UpdateIssue(issue)
-		if err != nil {
-			return err
+		if _, err := jClient.UpdateIssue(issue); err != nil {
+			return false, err
 		}
 
 		log.Debugf("Successfully updated JIRA issue %s!", jIssue.Key)
@@ -152,17 +490,9 @@ func UpdateIssue(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghC
 		log.Debugf("JIRA issue %s is already up to date!", jIssue.Key)
 	}
 
-	issue, err := jClient.GetIssue(jIssue.Key)
-	if err != nil {
-		log.Debugf("Failed to retrieve JIRA issue %s!", jIssue.Key)
-		return err
-	}
+	config.SetMirroredIssue(ghIssue.GetID(), jIssue.Key, hash)
 
-	if err := CompareComments(config, ghIssue, issue, ghClient, jClient); err != nil {
-		return err
-	}
-
-	return nil
+	return true, nil
 }
 
 // CreateIssue generates a JIRA issue from the various fields on the given GitHub issue, then
@@ -172,38 +502,75 @@ func CreateIssue(config cfg.Config, issue github.Issue, ghClient clients.GitHubC
 
 	log.Debugf("Creating JIRA issue based on GitHub issue #%d", *issue.Number)
 
+	status, err := githubStatus(config, issue, ghClient)
+	if err != nil {
+		return err
+	}
+
+	description, err := jiraBody(config, issue.GetBody(), jira.Issue{}, ghClient, jClient)
+	if err != nil {
+		return err
+	}
+
 	fields := jira.IssueFields{
 		Type: jira.IssueType{
-			Name: "Task", // TODO: Determine issue type
+			Name: issueType(config, issue),
 		},
 		Project:     config.GetProject(),
 		Summary:     issue.GetTitle(),
-		Description: issue.GetBody(),
+		Description: description,
 		Unknowns:    map[string]interface{}{},
 	}
 
 	fields.Unknowns[config.GetFieldKey(cfg.GitHubID)] = issue.GetID()
 	fields.Unknowns[config.GetFieldKey(cfg.GitHubNumber)] = issue.GetNumber()
-	fields.Unknowns[config.GetFieldKey(cfg.GitHubStatus)] = issue.GetState()
+	fields.Unknowns[config.GetFieldKey(cfg.GitHubStatus)] = status
 	fields.Unknowns[config.GetFieldKey(cfg.GitHubReporter)] = issue.User.GetLogin()
 
-	strs := make([]string, len(issue.Labels))
-	for i, v := range issue.Labels {
-		strs[i] = *v.Name
+	fields.Unknowns[config.GetFieldKey(cfg.GitHubLabels)] = strings.Join(githubLabels(issue), ",")
+	if config.IsLabelSyncEnabled() {
+		labels, components := mapLabels(config, githubLabels(issue))
+		setLabelFields(&fields, labels, components)
+	}
+	if config.IsMilestoneSyncEnabled() && issue.Milestone != nil {
+		setFixVersionFields(&fields, []string{issue.Milestone.GetTitle()})
 	}
-	fields.Unknowns[config.GetFieldKey(cfg.GitHubLabels)] = strings.Join(strs, ",")
 
 	fields.Unknowns[config.GetFieldKey(cfg.LastISUpdate)] = time.Now().Format(dateFormat)
 
+	if priority := issuePriority(config, issue); priority != "" {
+		fields.Priority = &jira.Priority{Name: priority}
+	}
+
+	var reporter, assignee string
+	if config.IsUserMappingEnabled() {
+		if reporter, err = issueReporter(config, issue, ghClient, jClient); err != nil {
+			return err
+		}
+		if reporter != "" {
+			fields.Reporter = &jira.User{Name: reporter}
+		}
+
+		if assignee, err = issueAssignee(config, issue, ghClient, jClient); err != nil {
+			return err
+		}
+		setAssignee(&fields, issue, assignee)
+	}
+
 	jIssue := jira.Issue{
 		Fields: &fields,
 	}
 
-	jIssue, err := jClient.CreateIssue(jIssue)
+	jIssue, err = jClient.CreateIssue(jIssue)
 	if err != nil {
 		return err
 	}
 
+	config.SetMirroredIssue(issue.GetID(), jIssue.Key, issueHash(config, issue, status, description, reporter, assignee))
+	if config.IsBackReferenceEnabled() {
+		config.SetBackReferencePending(issue.GetID(), true)
+	}
+
 	jIssue, err = jClient.GetIssue(jIssue.Key)
 	if err != nil {
 		return err
@@ -211,9 +578,73 @@ func CreateIssue(config cfg.Config, issue github.Issue, ghClient clients.GitHubC
 
 	log.Debugf("Created JIRA issue %s!", jIssue.Key)
 
-	if err := CompareComments(config, issue, jIssue, ghClient, jClient); err != nil {
+	// Files can only be attached once the issue exists, so the description
+	// is updated to link to them afterwards.
+	if config.IsAttachmentSyncEnabled() {
+		if err := MirrorAttachments(config, jIssue, issue.GetBody(), ghClient, jClient); err != nil {
+			return err
+		}
+		updated, err := jiraBody(config, issue.GetBody(), jIssue, ghClient, jClient)
+		if err != nil {
+			return err
+		}
+		if updated != description {
+			jIssue, err = jClient.UpdateIssue(jira.Issue{
+				Key: jIssue.Key,
+				ID:  jIssue.ID,
+				Fields: &jira.IssueFields{
+					Type:        jIssue.Fields.Type,
+					Description: updated,
+					Unknowns:    map[string]interface{}{},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if jIssue, err = jClient.GetIssue(jIssue.Key); err != nil {
+				return err
+			}
+			config.SetMirroredIssue(issue.GetID(), jIssue.Key, issueHash(config, issue, status, updated, reporter, assignee))
+		}
+	}
+
+	if err := jClient.SetRemoteLink(jIssue, githubLink(issue, status, "mirrors")); err != nil {
 		return err
 	}
 
+	if config.IsBackReferenceEnabled() {
+		if err := WriteBackReference(config, issue, jIssue, ghClient); err != nil {
+			return err
+		}
+		config.SetBackReferencePending(issue.GetID(), false)
+	}
+
+	if err := TransitionIssue(config, issue, jIssue, ghClient, jClient); err != nil {
+		return err
+	}
+
+	comments, err := CompareComments(config, issue, jIssue, ghClient, jClient)
+	if err != nil {
+		return err
+	}
+
+	if config.IsTimelineSyncEnabled() {
+		if err := CompareEvents(config, issue, jIssue, ghClient, jClient); err != nil {
+			return err
+		}
+	}
+
+	if config.IsTaskListSyncEnabled() {
+		if err := SyncTaskList(config, issue, jIssue, ghClient, jClient); err != nil {
+			return err
+		}
+	}
+
+	if config.IsReferenceSyncEnabled() {
+		if err := LinkReferences(config, issue, jIssue, true, comments, ghClient, jClient); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
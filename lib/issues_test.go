@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+func TestRecordProgress(t *testing.T) {
+	start := time.Date(2019, time.April, 17, 0, 0, 0, 0, time.UTC)
+	issue := func(number int, updated time.Duration) github.Issue {
+		at := start.Add(updated)
+		return github.Issue{Number: github.Int(number), UpdatedAt: &at}
+	}
+	failure := errors.New("failed")
+
+	tests := []struct {
+		name      string
+		ghIssues  []github.Issue
+		errs      []error
+		wantSince time.Time
+		wantRetry []int
+	}{
+		{
+			"all synchronized",
+			[]github.Issue{issue(1, time.Hour), issue(2, 3*time.Hour), issue(3, 2*time.Hour)},
+			[]error{nil, nil, nil},
+			start.Add(3*time.Hour - 5*time.Minute),
+			nil,
+		},
+		{
+			"latest failed",
+			[]github.Issue{issue(1, time.Hour), issue(2, 3*time.Hour), issue(3, 2*time.Hour)},
+			[]error{nil, failure, nil},
+			start.Add(2*time.Hour - 5*time.Minute),
+			[]int{2},
+		},
+		{
+			"all failed",
+			[]github.Issue{issue(1, time.Hour), issue(2, 3*time.Hour)},
+			[]error{failure, failure},
+			start,
+			[]int{1, 2},
+		},
+		{
+			"within the overlap",
+			[]github.Issue{issue(1, time.Minute)},
+			[]error{nil},
+			start,
+			nil,
+		},
+	}
+
+	config := newTestConfig(t, nil, nil)
+	for _, test := range tests {
+		config.SetSinceParam(start)
+		config.SetRetryIssues([]int{9})
+
+		recordProgress(config, test.ghIssues, test.errs)
+
+		if got := config.GetSinceParam(); !got.Equal(test.wantSince) {
+			t.Errorf("%s: since %v; want %v", test.name, got, test.wantSince)
+		}
+		if got := config.GetRetryIssues(); !reflect.DeepEqual(got, test.wantRetry) {
+			t.Errorf("%s: retry %v; want %v", test.name, got, test.wantRetry)
+		}
+	}
+}
+
+func TestAddRetryIssues(t *testing.T) {
+	issue := func(number int, url string) github.Issue {
+		return github.Issue{Number: github.Int(number), HTMLURL: github.String(url)}
+	}
+	gh := &fakeGHClient{issues: []github.Issue{
+		issue(1, "https://github.com/coreos/issue-sync/issues/1"),
+		issue(2, "https://github.com/coreos/issue-sync/issues/2"),
+		issue(4, "https://github.com/coreos/etcd/issues/10"),
+		{
+			Number:           github.Int(5),
+			HTMLURL:          github.String("https://github.com/coreos/issue-sync/pull/5"),
+			PullRequestLinks: &github.PullRequestLinks{},
+		},
+	}}
+
+	tests := []struct {
+		name  string
+		retry []int
+		want  []int
+	}{
+		{"none", nil, []int{1}},
+		{"already listed", []int{1}, []int{1}},
+		{"found", []int{2}, []int{1, 2}},
+		{"missing", []int{3}, []int{1}},
+		{"transferred", []int{4}, []int{1}},
+		{"pull request", []int{5}, []int{1}},
+		{"duplicates", []int{2, 3, 2}, []int{1, 2}},
+	}
+
+	config := newTestConfig(t, nil, nil)
+	for _, test := range tests {
+		config.SetRetryIssues(test.retry)
+
+		ghIssues, err := addRetryIssues(config, []github.Issue{gh.issues[0]}, gh)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var got []int
+		for _, ghIssue := range ghIssues {
+			got = append(got, ghIssue.GetNumber())
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got issues %v; want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// issueType returns the name of the JIRA issue type a GitHub issue should be
+// mirrored as: that of the first configured rule which matches the issue,
+// or the default issue type (of pull requests, for pull requests) if none do.
+func issueType(config cfg.Config, ghIssue github.Issue) string {
+	for _, rule := range config.GetIssueTypeRules() {
+		if issueTypeRuleMatches(rule, ghIssue) {
+			return rule.Type
+		}
+	}
+	if isPullRequest(ghIssue) {
+		return config.GetPullRequestIssueType()
+	}
+	return config.GetDefaultIssueType()
+}
+
+// issueTypeRuleMatches returns whether every condition set on an issue type
+// rule matches the GitHub issue. Labels are compared case-insensitively, as
+// GitHub does.
+func issueTypeRuleMatches(rule cfg.IssueTypeRule, ghIssue github.Issue) bool {
+	if rule.Label != "" {
+		found := false
+		for _, l := range ghIssue.Labels {
+			if strings.EqualFold(l.GetName(), rule.Label) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if rule.TitlePrefix != "" && !strings.HasPrefix(ghIssue.GetTitle(), rule.TitlePrefix) {
+		return false
+	}
+
+	if rule.Template != "" && !strings.Contains(ghIssue.GetBody(), rule.Template) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+func TestIssueType(t *testing.T) {
+	rules := []map[string]string{
+		{"label": "security", "title-prefix": "CVE-", "type": "Vulnerability"},
+		{"label": "kind/bug", "type": "Bug"},
+		{"title-prefix": "[Epic]", "type": "Epic"},
+		{"template": "### Feature request", "type": "New Feature"},
+	}
+	issueTypes := []*jira.MetaIssueType{{Name: "Task"}, {Name: "Story"}, {Name: "Change"}}
+	for _, rule := range rules {
+		issueTypes = append(issueTypes, &jira.MetaIssueType{Name: rule["type"]})
+	}
+	responses := map[string]interface{}{
+		"/rest/api/2/issue/createmeta": jira.CreateMetaInfo{Projects: []*jira.MetaProject{{Key: "SYNC", IssueTypes: issueTypes}}},
+	}
+
+	configs := map[string]cfg.Config{
+		"plain": newTestConfig(t, map[string]interface{}{
+			"issue-types":   map[string]interface{}{"rules": rules},
+			"pull-requests": map[string]interface{}{"sync": cfg.PullRequestSyncIssues},
+		}, responses),
+		"custom": newTestConfig(t, map[string]interface{}{
+			"issue-types":   map[string]interface{}{"default": "Story", "rules": rules},
+			"pull-requests": map[string]interface{}{"sync": cfg.PullRequestSyncIssues, "issue-type": "Change"},
+		}, responses),
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		pull   bool
+		labels []string
+		title  string
+		body   string
+		want   string
+	}{
+		{"default", "plain", false, nil, "Crash", "", "Task"},
+		{"configured default", "custom", false, nil, "Crash", "", "Story"},
+		{"label", "plain", false, []string{"Kind/Bug"}, "Crash", "", "Bug"},
+		{"title prefix", "plain", false, nil, "[Epic] Rewrite", "", "Epic"},
+		{"title prefix not at start", "plain", false, nil, "Rewrite [Epic]", "", "Task"},
+		{"template", "plain", false, nil, "Dark mode", "### Feature request\n\nPlease.", "New Feature"},
+		{"all conditions", "plain", false, []string{"security"}, "CVE-2020-1234", "", "Vulnerability"},
+		{"label only", "plain", false, []string{"security"}, "Leak", "", "Task"},
+		{"title prefix only", "plain", false, nil, "CVE-2020-1234", "", "Task"},
+		{"first rule", "plain", false, []string{"kind/bug"}, "[Epic] Crash", "", "Bug"},
+		{"pull request default", "plain", true, nil, "Fix crash", "", "Task"},
+		{"pull request type", "custom", true, nil, "Fix crash", "", "Change"},
+		{"pull request rule", "custom", true, []string{"kind/bug"}, "Fix crash", "", "Bug"},
+	}
+
+	for _, test := range tests {
+		labels := make([]github.Label, len(test.labels))
+		for i, name := range test.labels {
+			labels[i] = github.Label{Name: github.String(name)}
+		}
+		ghIssue := github.Issue{
+			Number: github.Int(1),
+			Title:  github.String(test.title),
+			Body:   github.String(test.body),
+			Labels: labels,
+		}
+		if test.pull {
+			ghIssue.PullRequestLinks = &github.PullRequestLinks{}
+		}
+
+		if got := issueType(configs[test.config], ghIssue); got != test.want {
+			t.Errorf("%s: got issue type %q; want %q", test.name, got, test.want)
+		}
+	}
+}
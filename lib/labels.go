@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// githubLabels returns the names of the labels of a GitHub issue.
+func githubLabels(issue github.Issue) []string {
+	labels := make([]string, len(issue.Labels))
+	for i, l := range issue.Labels {
+		labels[i] = l.GetName()
+	}
+	return labels
+}
+
+// previousLabels returns the names of the GitHub labels recorded in the
+// GitHub Labels field of a JIRA issue on the last synchronization.
+func previousLabels(config cfg.Config, jIssue jira.Issue) []string {
+	field, err := jIssue.Fields.Unknowns.String(config.GetFieldKey(cfg.GitHubLabels))
+	if err != nil || field == "" {
+		return nil
+	}
+	return strings.Split(field, ",")
+}
+
+// sanitizeLabel turns a GitHub label name into a valid JIRA label, which
+// cannot contain spaces.
+func sanitizeLabel(name string) string {
+	return strings.Join(strings.Fields(name), "-")
+}
+
+// mapLabels returns the JIRA labels and components the given GitHub labels
+// are mapped to by the label configuration.
+func mapLabels(config cfg.Config, labels []string) ([]string, []string) {
+	var jLabels, components []string
+	for _, label := range labels {
+		rule, ok := config.GetLabelRule(label)
+		if !ok {
+			if config.IsLabelPassThrough() {
+				jLabels = appendUnique(jLabels, sanitizeLabel(label))
+			}
+			continue
+		}
+		if rule.JIRALabel != "" {
+			jLabels = appendUnique(jLabels, rule.JIRALabel)
+		}
+		if rule.Component != "" {
+			components = appendUnique(components, rule.Component)
+		}
+	}
+	return jLabels, components
+}
+
+// syncLabels returns the labels and components the JIRA issue should have for
+// the labels of the GitHub issue. Labels and components which were mapped from
+// a label the GitHub issue no longer has are removed; those which were added
+// in JIRA are kept.
+func syncLabels(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue) ([]string, []string) {
+	oldLabels, oldComponents := mapLabels(config, previousLabels(config, jIssue))
+	newLabels, newComponents := mapLabels(config, githubLabels(ghIssue))
+
+	var labels []string
+	for _, label := range jIssue.Fields.Labels {
+		if !containsFold(oldLabels, label) || containsFold(newLabels, label) {
+			labels = appendUnique(labels, label)
+		}
+	}
+	for _, label := range newLabels {
+		labels = appendUnique(labels, label)
+	}
+
+	var components []string
+	for _, component := range jIssue.Fields.Components {
+		if !containsFold(oldComponents, component.Name) || containsFold(newComponents, component.Name) {
+			components = appendUnique(components, component.Name)
+		}
+	}
+	for _, component := range newComponents {
+		components = appendUnique(components, component)
+	}
+
+	return labels, components
+}
+
+// didLabelsChange returns whether the labels or components of the JIRA issue
+// differ from those it should have for the labels of the GitHub issue.
+func didLabelsChange(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue) bool {
+	labels, components := syncLabels(config, ghIssue, jIssue)
+
+	current := make([]string, len(jIssue.Fields.Components))
+	for i, component := range jIssue.Fields.Components {
+		current[i] = component.Name
+	}
+
+	return !sameElements(labels, jIssue.Fields.Labels) || !sameElements(components, current)
+}
+
+// setLabelFields sets the JIRA labels and components on the fields of an issue
+// being created or updated. They are set through Unknowns so that an empty list
+// is still sent, removing every label or component.
+func setLabelFields(fields *jira.IssueFields, labels, components []string) {
+	if labels == nil {
+		labels = []string{}
+	}
+	comps := make([]jira.Component, len(components))
+	for i, name := range components {
+		comps[i] = jira.Component{Name: name}
+	}
+	fields.Unknowns["labels"] = labels
+	fields.Unknowns["components"] = comps
+}
+
+// appendUnique appends s to list unless list already contains it, ignoring case.
+func appendUnique(list []string, s string) []string {
+	if containsFold(list, s) {
+		return list
+	}
+	return append(list, s)
+}
+
+// containsFold returns whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameElements returns whether a and b contain the same strings, ignoring
+// case and order.
+func sameElements(a, b []string) bool {
+	for _, v := range a {
+		if !containsFold(b, v) {
+			return false
+		}
+	}
+	for _, v := range b {
+		if !containsFold(a, v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+func TestSanitizeLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"bug", "bug"},
+		{"good first issue", "good-first-issue"},
+		{"  needs \t review\n", "needs-review"},
+		{"kind/bug", "kind/bug"},
+	}
+
+	for _, test := range tests {
+		if got := sanitizeLabel(test.name); got != test.want {
+			t.Errorf("sanitizeLabel(%q) = %q; want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSyncLabels(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{
+		"labels": map[string]interface{}{
+			"pass-through": true,
+			"rules": []map[string]string{
+				{"label": "kind/bug", "jira-label": "bug"},
+				{"label": "area/api", "component": "API"},
+				{"label": "area/ui", "jira-label": "frontend", "component": "UI"},
+			},
+		},
+	}, map[string]interface{}{
+		"/rest/api/2/project/SYNC": map[string]interface{}{"id": "1", "key": "SYNC", "components": []jira.ProjectComponent{
+			{Name: "API"},
+			{Name: "UI"},
+		}},
+	})
+
+	tests := []struct {
+		name           string
+		previous       []string
+		ghLabels       []string
+		jLabels        []string
+		jComponents    []string
+		wantLabels     []string
+		wantComponents []string
+	}{
+		{"no labels", nil, nil, nil, nil, nil, nil},
+		{"renamed", nil, []string{"kind/bug"}, nil, nil, []string{"bug"}, nil},
+		{"routed", nil, []string{"area/api"}, nil, nil, nil, []string{"API"}},
+		{"renamed and routed", nil, []string{"Area/UI"}, nil, nil, []string{"frontend"}, []string{"UI"}},
+		{"pass-through", nil, []string{"good first issue"}, nil, nil, []string{"good-first-issue"}, nil},
+		{"unchanged", []string{"kind/bug", "area/api"}, []string{"kind/bug", "area/api"}, []string{"bug"}, []string{"API"},
+			[]string{"bug"}, []string{"API"}},
+		{"removed", []string{"kind/bug", "area/api"}, nil, []string{"bug"}, []string{"API"}, nil, nil},
+		{"label renamed on GitHub", []string{"kind/bug"}, []string{"kind/defect"}, []string{"bug"}, nil,
+			[]string{"kind/defect"}, nil},
+		{"added in JIRA", []string{"kind/bug"}, nil, []string{"bug", "triaged"}, []string{"Backend"},
+			[]string{"triaged"}, []string{"Backend"}},
+		{"added in JIRA and GitHub", nil, []string{"kind/bug"}, []string{"bug"}, nil, []string{"bug"}, nil},
+	}
+
+	for _, test := range tests {
+		labels := make([]github.Label, len(test.ghLabels))
+		for i, name := range test.ghLabels {
+			labels[i] = github.Label{Name: github.String(name)}
+		}
+		ghIssue := github.Issue{Labels: labels}
+
+		jIssue := testJIRAIssue(config, "SYNC-1", 1)
+		jIssue.Fields.Unknowns[config.GetFieldKey(cfg.GitHubLabels)] = strings.Join(test.previous, ",")
+		jIssue.Fields.Labels = test.jLabels
+		for _, name := range test.jComponents {
+			jIssue.Fields.Components = append(jIssue.Fields.Components, &jira.Component{Name: name})
+		}
+
+		gotLabels, gotComponents := syncLabels(config, ghIssue, jIssue)
+		if !reflect.DeepEqual(gotLabels, test.wantLabels) {
+			t.Errorf("%s: got labels %q; want %q", test.name, gotLabels, test.wantLabels)
+		}
+		if !reflect.DeepEqual(gotComponents, test.wantComponents) {
+			t.Errorf("%s: got components %q; want %q", test.name, gotComponents, test.wantComponents)
+		}
+
+		wantChanged := !sameElements(test.wantLabels, test.jLabels) || !sameElements(test.wantComponents, test.jComponents)
+		if got := didLabelsChange(config, ghIssue, jIssue); got != wantChanged {
+			t.Errorf("%s: labels changed %t; want %t", test.name, got, wantChanged)
+		}
+	}
+}
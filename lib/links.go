@@ -0,0 +1,109 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// githubIconURL is the URL of the icon displayed next to remote links to GitHub.
+const githubIconURL = "https://github.com/favicon.ico"
+
+// statusIconURLs are the URLs of the icons displayed next to the status of
+// remote links to GitHub, by state: the emoji closest to the colors GitHub
+// uses for them. Closed issues, like merged pull requests, are purple.
+var statusIconURLs = map[string]string{
+	pullOpen:             "https://github.githubassets.com/images/icons/emoji/unicode/1f7e2.png",
+	pullApproved:         "https://github.githubassets.com/images/icons/emoji/unicode/2705.png",
+	pullChangesRequested: "https://github.githubassets.com/images/icons/emoji/unicode/1f7e0.png",
+	pullMerged:           "https://github.githubassets.com/images/icons/emoji/unicode/1f7e3.png",
+	pullClosed:           "https://github.githubassets.com/images/icons/emoji/unicode/1f534.png",
+}
+
+// issueRepo returns the owner and name of the repository of a GitHub issue,
+// from its URL.
+func issueRepo(ghIssue github.Issue) (string, string) {
+	parts := strings.Split(strings.TrimPrefix(ghIssue.GetHTMLURL(), "https://"), "/")
+	if len(parts) < 3 {
+		return "", ""
+	}
+	return parts[1], parts[2]
+}
+
+// githubLink returns the remote link from a JIRA issue to a GitHub issue or
+// pull request in the given state (see githubStatus).
+func githubLink(ghIssue github.Issue, state, relationship string) clients.RemoteLink {
+	owner, repo := issueRepo(ghIssue)
+
+	icon := statusIconURLs[state]
+	if !isPullRequest(ghIssue) && state == "closed" {
+		icon = statusIconURLs[pullMerged]
+	}
+
+	return clients.RemoteLink{
+		GlobalID:     ghIssue.GetHTMLURL(),
+		Relationship: relationship,
+		Object: clients.RemoteLinkObject{
+			URL:     ghIssue.GetHTMLURL(),
+			Title:   fmt.Sprintf("%s/%s#%d", owner, repo, ghIssue.GetNumber()),
+			Summary: ghIssue.GetTitle(),
+			Icon: &clients.RemoteLinkIcon{
+				URL16x16: githubIconURL,
+				Title:    "GitHub",
+			},
+			Status: &clients.RemoteLinkStatus{
+				Resolved: state == pullMerged || state == pullClosed,
+				Icon: &clients.RemoteLinkIcon{
+					URL16x16: icon,
+					Title:    strings.Replace(state, "_", " ", -1),
+				},
+			},
+		},
+	}
+}
+
+// SyncRemoteLink adds a remote link from a JIRA issue to the GitHub issue it
+// mirrors if it doesn't have one yet, and otherwise updates the link if the
+// title or status of the GitHub issue changed. status is the status of the
+// GitHub issue (see githubStatus).
+func SyncRemoteLink(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, status string, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	link := githubLink(ghIssue, status, "mirrors")
+
+	links, err := jClient.ListRemoteLinks(jIssue)
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		if l.GlobalID == link.GlobalID && !didRemoteLinkChange(l, link) {
+			log.Debugf("Remote link from JIRA issue %s to GitHub is up to date", jIssue.Key)
+			return nil
+		}
+	}
+
+	if err := jClient.SetRemoteLink(jIssue, link); err != nil {
+		return err
+	}
+
+	log.Debugf("Set remote link from JIRA issue %s to %s", jIssue.Key, link.Object.URL)
+
+	return nil
+}
+
+// didRemoteLinkChange returns whether the existing remote link differs from
+// the one it should be updated to.
+func didRemoteLinkChange(existing, link clients.RemoteLink) bool {
+	if existing.Object.Title != link.Object.Title || existing.Object.Summary != link.Object.Summary {
+		return true
+	}
+	if existing.Object.Status == nil || existing.Object.Status.Icon == nil {
+		return true
+	}
+	return existing.Object.Status.Resolved != link.Object.Status.Resolved ||
+		existing.Object.Status.Icon.Title != link.Object.Status.Icon.Title
+}
@@ -0,0 +1,494 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// The regexes below match the GitHub Flavored Markdown block constructs
+// which have an equivalent in JIRA wiki markup.
+var (
+	// mdFenceRegex matches the opening line of a fenced code block, with the
+	// indentation (\1), the fence (\2) and the language (\3).
+	mdFenceRegex = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})\\s*([^`\\s]*)")
+	// mdHeadingRegex matches an ATX heading, with its level (\1) and text (\2).
+	mdHeadingRegex = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	// mdSetextRegex matches the underline of a setext heading; "=" is level 1
+	// and "-" is level 2.
+	mdSetextRegex = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	// mdRuleRegex matches a thematic break.
+	mdRuleRegex = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	// mdListRegex matches a list item, with its indentation (\1), its marker
+	// (\2) and its content (\3).
+	mdListRegex = regexp.MustCompile(`^(\s*)([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	// mdTaskRegex matches the checkbox at the start of a task list item, with
+	// its state (\1) and the rest of the item (\2).
+	mdTaskRegex = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	// mdQuoteRegex matches a line of a block quote, with the quoted text (\1).
+	mdQuoteRegex = regexp.MustCompile(`^ {0,3}>\s?(.*)$`)
+	// mdTableDelimiterRegex matches the delimiter row below the header of a table.
+	mdTableDelimiterRegex = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	// mdCommentRegex matches an HTML comment, such as those left in issue templates.
+	mdCommentRegex = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// The regexes below match the GitHub Flavored Markdown inline constructs
+// which have an equivalent in JIRA wiki markup.
+var (
+	mdImageRegex    = regexp.MustCompile(`!\[([^\]]*)\]\(\s*([^)\s]+)(?:\s+"[^"]*")?\s*\)`)
+	mdLinkRegex     = regexp.MustCompile(`\[([^\]]+)\]\(\s*([^)\s]+)(?:\s+"[^"]*")?\s*\)`)
+	mdImageTagRegex = regexp.MustCompile(`<img\s[^>]*?src="([^"]+)"[^>]*>`)
+	mdAutolinkRegex = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	mdURLRegex      = regexp.MustCompile(`https?://[^\s<>]*[^\s<>.,;:!?)'"]`)
+	mdBoldRegex     = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	mdBoldUnderRe   = regexp.MustCompile(`(^|[^\w])__(\S(?:.*?\S)?)__([^\w]|$)`)
+	mdItalicRegex   = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	mdStrikeRegex   = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	// mdMentionRegex matches an @mention of a GitHub user, with the
+	// character before it (\1), the login (\2) and, for a team, the
+	// name of the team (\3).
+	mdMentionRegex = regexp.MustCompile(`(^|[^\w@/.` + "`" + `])@([A-Za-z0-9](?:[A-Za-z0-9-]{0,38}))(/[\w-]+)?`)
+	// mdReferenceRegex matches a reference to a GitHub issue, with the
+	// character before it (\1), the repository (\2, if another repository
+	// is referenced) and the issue number (\3).
+	mdReferenceRegex = regexp.MustCompile(`(^|[^\w/#&.-])(?:([\w.-]+/[\w.-]+))?#(\d+)\b`)
+	// mdPlaceholderRegex matches the placeholders convertInline puts in place
+	// of the parts of a line which must not be converted any further.
+	mdPlaceholderRegex = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// jiraEscaper escapes the characters which have a meaning in JIRA wiki
+// markup, but not in Markdown.
+var jiraEscaper = strings.NewReplacer("{", "\\{", "}", "\\}", "[", "\\[", "]", "\\]")
+
+// mdConverter converts GitHub Flavored Markdown to JIRA wiki markup. Its
+// hooks may be nil.
+type mdConverter struct {
+	// mention returns the JIRA wiki markup an @mention of the GitHub user with
+	// the given login is converted to, or the empty string to leave it as it is.
+	mention func(login string) string
+	// attachment returns the name of the JIRA attachment the file at the given
+	// URL was mirrored as, or the empty string to keep linking to the URL.
+	attachment func(url string) string
+	// reference returns the key of the JIRA issue a reference to the GitHub
+	// issue with the given number, in the given repository ("owner/repo", or
+	// the empty string for the issue's own), is converted to, or the empty
+	// string to leave it as it is.
+	reference func(repo string, number int) string
+}
+
+// mdListLevel is a level of nesting of the list being converted.
+type mdListLevel struct {
+	indent int
+	marker string
+}
+
+// convertMarkdown converts GitHub Flavored Markdown, as used in the bodies
+// of GitHub issues and comments, to JIRA wiki markup.
+func convertMarkdown(md string) string {
+	return mdConverter{}.convert(md)
+}
+
+// convert converts GitHub Flavored Markdown to JIRA wiki markup. Constructs
+// which JIRA can't represent are left as they are.
+func (c mdConverter) convert(md string) string {
+	md = strings.Replace(md, "\r\n", "\n", -1)
+	md = mdCommentRegex.ReplaceAllString(md, "")
+
+	lines := strings.Split(md, "\n")
+
+	var out []string
+	var list []mdListLevel
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+
+		if strings.TrimSpace(line) == "" {
+			out = append(out, "")
+			continue
+		}
+
+		if m := mdFenceRegex.FindStringSubmatch(line); m != nil {
+			list = nil
+			if m[3] != "" {
+				out = append(out, fmt.Sprintf("{code:%s}", m[3]))
+			} else {
+				out = append(out, "{code}")
+			}
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), m[2]) {
+					break
+				}
+				out = append(out, strings.TrimPrefix(lines[i], m[1]))
+			}
+			out = append(out, "{code}")
+			continue
+		}
+
+		if m := mdListRegex.FindStringSubmatch(line); m != nil && !mdRuleRegex.MatchString(line) {
+			indent := len(strings.Replace(m[1], "\t", "    ", -1))
+			marker := "*"
+			if _, err := strconv.Atoi(strings.TrimRight(m[2], ".)")); err == nil {
+				marker = "#"
+			}
+
+			for len(list) > 0 && list[len(list)-1].indent > indent {
+				list = list[:len(list)-1]
+			}
+			if len(list) > 0 && list[len(list)-1].indent == indent {
+				list[len(list)-1].marker = marker
+			} else {
+				list = append(list, mdListLevel{indent: indent, marker: marker})
+			}
+
+			prefix := ""
+			for _, level := range list {
+				prefix += level.marker
+			}
+
+			content := m[3]
+			if t := mdTaskRegex.FindStringSubmatch(content); t != nil {
+				if t[1] == " " {
+					content = "(x) " + c.convertInline(t[2])
+				} else {
+					content = "(/) " + c.convertInline(t[2])
+				}
+			} else {
+				content = c.convertInline(content)
+			}
+
+			out = append(out, prefix+" "+content)
+			continue
+		}
+
+		// A line following a list item which isn't a block of its own is a
+		// continuation of that item, and an indented paragraph after it is
+		// still part of the list.
+		if len(list) > 0 && !mdQuoteRegex.MatchString(line) && !mdHeadingRegex.MatchString(line) && !mdRuleRegex.MatchString(line) {
+			if out[len(out)-1] != "" {
+				out[len(out)-1] += " " + c.convertInline(strings.TrimSpace(line))
+				continue
+			}
+			if line[0] == ' ' || line[0] == '\t' {
+				out = append(out, c.convertInline(strings.TrimSpace(line)))
+				continue
+			}
+		}
+		list = nil
+
+		if strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+			if len(out) == 0 || out[len(out)-1] == "" {
+				var code []string
+				for ; i < len(lines); i++ {
+					l := strings.TrimRight(lines[i], " \t")
+					if l != "" && !strings.HasPrefix(l, "    ") && !strings.HasPrefix(l, "\t") {
+						break
+					}
+					if strings.HasPrefix(l, "\t") {
+						l = l[1:]
+					} else if len(l) >= 4 {
+						l = l[4:]
+					}
+					code = append(code, l)
+				}
+				i--
+				// Trailing blank lines are not part of the block.
+				for len(code) > 0 && code[len(code)-1] == "" {
+					code = code[:len(code)-1]
+					i--
+				}
+				out = append(out, "{noformat}")
+				out = append(out, code...)
+				out = append(out, "{noformat}")
+				continue
+			}
+		}
+
+		if mdQuoteRegex.MatchString(line) {
+			var quote []string
+			for ; i < len(lines); i++ {
+				m := mdQuoteRegex.FindStringSubmatch(lines[i])
+				if m == nil {
+					break
+				}
+				quote = append(quote, m[1])
+			}
+			i--
+			out = append(out, "{quote}", c.convert(strings.Join(quote, "\n")), "{quote}")
+			continue
+		}
+
+		if m := mdHeadingRegex.FindStringSubmatch(line); m != nil {
+			out = append(out, fmt.Sprintf("h%d. %s", len(m[1]), c.convertInline(m[2])))
+			continue
+		}
+
+		if mdRuleRegex.MatchString(line) {
+			out = append(out, "----")
+			continue
+		}
+
+		if strings.Contains(line, "|") && i+1 < len(lines) && strings.Contains(lines[i+1], "|") && mdTableDelimiterRegex.MatchString(lines[i+1]) {
+			out = append(out, c.convertTableRow(line, "||"))
+			for i += 2; i < len(lines); i++ {
+				if strings.TrimSpace(lines[i]) == "" || !strings.Contains(lines[i], "|") {
+					break
+				}
+				out = append(out, c.convertTableRow(lines[i], "|"))
+			}
+			i--
+			continue
+		}
+
+		if i+1 < len(lines) {
+			if m := mdSetextRegex.FindStringSubmatch(lines[i+1]); m != nil {
+				level := 1
+				if m[1][0] == '-' {
+					level = 2
+				}
+				out = append(out, fmt.Sprintf("h%d. %s", level, c.convertInline(strings.TrimSpace(line))))
+				i++
+				continue
+			}
+		}
+
+		out = append(out, c.convertInline(line))
+	}
+
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// jiraBody converts the body of a GitHub issue or comment to the JIRA wiki
+// markup it is mirrored as, converting @mentions and references to mirrored
+// issues if configured to, and linking to the attachments of jIssue which
+// files referenced in the body were mirrored as (see MirrorAttachments).
+func jiraBody(config cfg.Config, body string, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) (string, error) {
+	var c mdConverter
+
+	if config.IsMentionSyncEnabled() {
+		var err error
+		c.mention, err = mentionFuncFor(config, body, ghClient, jClient)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if config.IsReferenceSyncEnabled() {
+		var err error
+		c.reference, err = referenceFuncFor(config, body, ghClient, jClient)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if config.IsAttachmentSyncEnabled() && jIssue.Key != "" {
+		c.attachment = func(url string) string {
+			name, _ := config.GetMirroredAttachment(jIssue.Key, url)
+			return name
+		}
+	}
+
+	return c.convert(body), nil
+}
+
+// convertTableRow converts a row of a Markdown table to a row of a JIRA table,
+// whose cells are separated by sep: "||" for the header, and "|" otherwise.
+func (c mdConverter) convertTableRow(row, sep string) string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if !strings.HasSuffix(row, "\\|") {
+		row = strings.TrimSuffix(row, "|")
+	}
+
+	// Split on pipes which aren't escaped.
+	var cells []string
+	start := 0
+	for i := 0; i < len(row); i++ {
+		if row[i] == '\\' {
+			i++
+			continue
+		}
+		if row[i] == '|' {
+			cells = append(cells, row[start:i])
+			start = i + 1
+		}
+	}
+	cells = append(cells, row[start:])
+
+	for i, cell := range cells {
+		cell = c.convertInline(strings.TrimSpace(strings.Replace(cell, "\\|", "|", -1)))
+		if cell == "" {
+			cell = " "
+		}
+		cells[i] = strings.Replace(cell, "|", "\\|", -1)
+	}
+
+	return sep + strings.Join(cells, sep) + sep
+}
+
+// convertInline converts the inline Markdown of a single line, such as
+// emphasis, code spans and links, to JIRA wiki markup.
+func (c mdConverter) convertInline(s string) string {
+	var protected []string
+	protect := func(s string) string {
+		protected = append(protected, s)
+		return fmt.Sprintf("\x00%d\x00", len(protected)-1)
+	}
+
+	s = replaceCodeSpans(s, func(code string) string {
+		return protect("{{" + code + "}}")
+	})
+	image := func(url string) string {
+		if name := c.attachmentName(url); name != "" {
+			return protect("!" + name + "!")
+		}
+		return protect("!" + url + "!")
+	}
+	s = mdImageRegex.ReplaceAllStringFunc(s, func(m string) string {
+		return image(mdImageRegex.FindStringSubmatch(m)[2])
+	})
+	s = mdImageTagRegex.ReplaceAllStringFunc(s, func(m string) string {
+		return image(mdImageTagRegex.FindStringSubmatch(m)[1])
+	})
+	s = mdLinkRegex.ReplaceAllStringFunc(s, func(m string) string {
+		sub := mdLinkRegex.FindStringSubmatch(m)
+		if name := c.attachmentName(sub[2]); name != "" {
+			return protect("[" + c.convertInline(sub[1]) + "|^" + name + "]")
+		}
+		return protect("[" + c.convertInline(sub[1]) + "|" + sub[2] + "]")
+	})
+	link := func(url string) string {
+		if name := c.attachmentName(url); name != "" {
+			return protect("[^" + name + "]")
+		}
+		return protect(url)
+	}
+	s = mdAutolinkRegex.ReplaceAllStringFunc(s, func(m string) string {
+		url := mdAutolinkRegex.FindStringSubmatch(m)[1]
+		if name := c.attachmentName(url); name != "" {
+			return protect("[^" + name + "]")
+		}
+		return protect("[" + url + "]")
+	})
+	s = mdURLRegex.ReplaceAllStringFunc(s, link)
+	if c.mention != nil {
+		s = mdMentionRegex.ReplaceAllStringFunc(s, func(m string) string {
+			sub := mdMentionRegex.FindStringSubmatch(m)
+			if sub[3] != "" {
+				return m
+			}
+			if markup := c.mention(sub[2]); markup != "" {
+				return sub[1] + protect(markup)
+			}
+			return m
+		})
+	}
+	if c.reference != nil {
+		s = mdReferenceRegex.ReplaceAllStringFunc(s, func(m string) string {
+			sub := mdReferenceRegex.FindStringSubmatch(m)
+			number, _ := strconv.Atoi(sub[3])
+			if key := c.reference(sub[2], number); key != "" {
+				return sub[1] + protect(key)
+			}
+			return m
+		})
+	}
+
+	s = jiraEscaper.Replace(s)
+
+	s = mdBoldRegex.ReplaceAllString(s, "\x01$1\x01")
+	s = mdBoldUnderRe.ReplaceAllString(s, "$1\x01$2\x01$3")
+	s = mdItalicRegex.ReplaceAllString(s, "_${1}_")
+	s = mdStrikeRegex.ReplaceAllString(s, "-$1-")
+	s = strings.Replace(s, "\x01", "*", -1)
+
+	return mdPlaceholderRegex.ReplaceAllStringFunc(s, func(m string) string {
+		i, _ := strconv.Atoi(mdPlaceholderRegex.FindStringSubmatch(m)[1])
+		return protected[i]
+	})
+}
+
+// attachmentName returns the name of the JIRA attachment the file at url was
+// mirrored as, or the empty string if it wasn't.
+func (c mdConverter) attachmentName(url string) string {
+	if c.attachment == nil {
+		return ""
+	}
+	return c.attachment(url)
+}
+
+// proseLines returns the lines of body outside fenced code blocks, with their
+// code spans blanked out, for finding the constructs which are converted.
+func proseLines(body string) []string {
+	var lines []string
+	fence := ""
+	for _, line := range strings.Split(strings.Replace(body, "\r\n", "\n", -1), "\n") {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := mdFenceRegex.FindStringSubmatch(line); m != nil {
+			fence = m[2]
+			continue
+		}
+		lines = append(lines, replaceCodeSpans(line, func(string) string { return "" }))
+	}
+	return lines
+}
+
+// replaceCodeSpans replaces each code span in s by the result of calling f
+// with its content. A code span starts with a run of backticks, and ends with
+// the next run of the same length.
+func replaceCodeSpans(s string, f func(string) string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "`")
+		if start < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		n := start
+		for n < len(s) && s[n] == '`' {
+			n++
+		}
+		fence := s[start:n]
+
+		end := -1
+		for i := n; i < len(s); {
+			j := strings.Index(s[i:], fence)
+			if j < 0 {
+				break
+			}
+			j += i
+			k := j + len(fence)
+			if k == len(s) || s[k] != '`' {
+				if j == 0 || s[j-1] != '`' {
+					end = j
+					break
+				}
+			}
+			for k < len(s) && s[k] == '`' {
+				k++
+			}
+			i = k
+		}
+
+		if end < 0 {
+			b.WriteString(s[:n])
+			s = s[n:]
+			continue
+		}
+
+		b.WriteString(s[:start])
+		b.WriteString(f(strings.TrimSpace(s[n:end])))
+		s = s[end+len(fence):]
+	}
+}
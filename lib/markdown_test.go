@@ -0,0 +1,134 @@
+package lib
+
+import "testing"
+
+func TestConvertMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		md   string
+		want string
+	}{
+		{
+			name: "headings",
+			md:   "# Title\n\n### Sub *title* ###\n\nSetext\n---",
+			want: "h1. Title\n\nh3. Sub _title_\n\nh2. Setext",
+		},
+		{
+			name: "emphasis",
+			md:   "Some **bold**, *italic*, _also italic_, __bold too__ and ~~struck~~ text.",
+			want: "Some *bold*, _italic_, _also italic_, *bold too* and -struck- text.",
+		},
+		{
+			name: "inline code",
+			md:   "Run `go test ./...` or ``a ` b``; `**not bold**`",
+			want: "Run {{go test ./...}} or {{a ` b}}; {{**not bold**}}",
+		},
+		{
+			name: "links",
+			md:   "See [the **docs**](https://example.com/a_b_c \"Docs\"), <https://example.com> and https://example.com/x_y_z.",
+			want: "See [the *docs*|https://example.com/a_b_c], [https://example.com] and https://example.com/x_y_z.",
+		},
+		{
+			name: "images",
+			md:   "![screenshot](https://example.com/a.png)",
+			want: "!https://example.com/a.png!",
+		},
+		{
+			name: "fenced code",
+			md:   "```go\nfunc main() {\n\t**x**\n}\n```\n\n~~~\nplain\n~~~",
+			want: "{code:go}\nfunc main() {\n\t**x**\n}\n{code}\n\n{code}\nplain\n{code}",
+		},
+		{
+			name: "indented code",
+			md:   "Text:\n\n    $ make\n    $ make test\n\nMore",
+			want: "Text:\n\n{noformat}\n$ make\n$ make test\n{noformat}\n\nMore",
+		},
+		{
+			name: "lists",
+			md:   "- one\n- two\n  * nested\n    1. deep\n- three\n  continued\n\n1. first\n2. second",
+			want: "* one\n* two\n** nested\n**# deep\n* three continued\n\n# first\n# second",
+		},
+		{
+			name: "task list",
+			md:   "- [ ] todo\n- [x] done",
+			want: "* (x) todo\n* (/) done",
+		},
+		{
+			name: "quote",
+			md:   "> quoted **text**\n> # heading\n\nafter",
+			want: "{quote}\nquoted *text*\nh1. heading\n{quote}\n\nafter",
+		},
+		{
+			name: "table",
+			md:   "| Name | Value |\n|------|:-----:|\n| `a\\|b` | **1** |\n| c | |",
+			want: "||Name||Value||\n|{{a\\|b}}|*1*|\n|c| |",
+		},
+		{
+			name: "rule and comments",
+			md:   "<!-- Please describe\nthe issue -->\nabove\n\n***\n\nbelow",
+			want: "above\n\n----\n\nbelow",
+		},
+		{
+			name: "escaping",
+			md:   "[WIP] use {braces} and 2 * 3 * 4\r\n",
+			want: "\\[WIP\\] use \\{braces\\} and 2 * 3 * 4",
+		},
+	}
+
+	for _, test := range tests {
+		if got := convertMarkdown(test.md); got != test.want {
+			t.Errorf("%s: convertMarkdown(%q) =\n%s\nwant:\n%s", test.name, test.md, got, test.want)
+		}
+	}
+}
+
+func TestConvertMarkdownMentions(t *testing.T) {
+	mention := func(login string) string {
+		if login == "alice" {
+			return "[~alice.smith]"
+		}
+		return "[@" + login + "|https://github.com/" + login + "]"
+	}
+
+	md := "@alice please look, cc @bob-1 and @coreos/team; not `@alice` or bob@example.com"
+	want := "[~alice.smith] please look, cc [@bob-1|https://github.com/bob-1] and @coreos/team; not {{@alice}} or bob@example.com"
+
+	if got := (mdConverter{mention: mention}).convert(md); got != want {
+		t.Errorf("convertMarkdown(%q) =\n%s\nwant:\n%s", md, got, want)
+	}
+}
+
+func TestConvertMarkdownAttachments(t *testing.T) {
+	attachment := func(url string) string {
+		if url == "https://user-images.githubusercontent.com/1/a.png" {
+			return "a.png"
+		}
+		return ""
+	}
+
+	md := "![shot](https://user-images.githubusercontent.com/1/a.png) <img src=\"https://user-images.githubusercontent.com/1/a.png\" width=\"50\"> ![other](https://example.com/b.png)"
+	want := "!a.png! !a.png! !https://example.com/b.png!"
+
+	if got := (mdConverter{attachment: attachment}).convert(md); got != want {
+		t.Errorf("convertMarkdown(%q) =\n%s\nwant:\n%s", md, got, want)
+	}
+}
+
+func TestConvertMarkdownReferences(t *testing.T) {
+	reference := func(repo string, number int) string {
+		switch {
+		case repo == "" && number == 12:
+			return "SYNC-3"
+		case repo == "coreos/etcd" && number == 5:
+			return "ETCD-8"
+		}
+		return ""
+	}
+
+	md := "Duplicate of #12, see coreos/etcd#5 and #7; not `#12`, https://example.com/#12 or a#12"
+	want := "Duplicate of SYNC-3, see ETCD-8 and #7; not {{#12}}, https://example.com/#12 or a#12"
+
+	if got := (mdConverter{reference: reference}).convert(md); got != want {
+		t.Errorf("convertMarkdown(%q) =\n%s\nwant:\n%s", md, got, want)
+	}
+}
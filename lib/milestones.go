@@ -0,0 +1,144 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// versionDateFormat is the format of the release date of a JIRA version.
+const versionDateFormat = "2006-01-02"
+
+// SyncMilestones mirrors the milestones of the GitHub repository the
+// configuration is scoped to as fix versions of its JIRA project. A version
+// is created for every open milestone, and for every closed milestone which
+// one of the given issues is in; the due date of the milestone is used as
+// the release date of its version. If configured, the versions of closed
+// milestones are marked released.
+//
+// The names of the milestones are recorded on the configuration, so that
+// UpdateIssue can replace the fix versions mirrored from milestones without
+// touching those set in JIRA.
+func SyncMilestones(config *cfg.Config, ghIssues []github.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	owner, repo := config.GetRepo()
+	milestones, err := ghClient.ListMilestones(owner, repo)
+	if err != nil {
+		return err
+	}
+
+	versions, err := jClient.ListVersions(config.GetProjectKey())
+	if err != nil {
+		return err
+	}
+
+	used := map[int]bool{}
+	for _, issue := range ghIssues {
+		if issue.Milestone != nil {
+			used[issue.Milestone.GetNumber()] = true
+		}
+	}
+
+	names := make([]string, len(milestones))
+	for i, milestone := range milestones {
+		names[i] = milestone.GetTitle()
+
+		want := milestoneVersion(*config, milestone)
+
+		var version *jira.Version
+		for j, v := range versions {
+			if strings.EqualFold(v.Name, want.Name) {
+				version = &versions[j]
+				break
+			}
+		}
+
+		if version == nil {
+			if milestone.GetState() != "open" && !used[milestone.GetNumber()] {
+				continue
+			}
+			log.Debugf("Creating JIRA version %s for milestone %s", want.Name, milestone.GetTitle())
+			if _, err := jClient.CreateVersion(config.GetProjectKey(), want); err != nil {
+				return err
+			}
+			continue
+		}
+
+		changed := false
+		if want.ReleaseDate != "" && want.ReleaseDate != version.ReleaseDate {
+			version.ReleaseDate = want.ReleaseDate
+			changed = true
+		}
+		if config.IsMilestoneReleaseEnabled() && want.Released != version.Released {
+			version.Released = want.Released
+			changed = true
+		}
+		if changed {
+			log.Debugf("Updating JIRA version %s for milestone %s", version.Name, milestone.GetTitle())
+			if _, err := jClient.UpdateVersion(*version); err != nil {
+				return err
+			}
+		}
+	}
+
+	config.SetMilestoneNames(names)
+
+	return nil
+}
+
+// milestoneVersion returns the JIRA version a GitHub milestone is mirrored as.
+func milestoneVersion(config cfg.Config, milestone github.Milestone) jira.Version {
+	version := jira.Version{
+		Name:     milestone.GetTitle(),
+		Released: config.IsMilestoneReleaseEnabled() && milestone.GetState() == "closed",
+	}
+	if milestone.DueOn != nil {
+		version.ReleaseDate = milestone.DueOn.UTC().Format(versionDateFormat)
+	}
+	return version
+}
+
+// syncFixVersions returns the names of the fix versions the JIRA issue should
+// have for the milestone of the GitHub issue: its current fix versions which
+// were not mirrored from a milestone, and the version of the milestone.
+func syncFixVersions(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue) []string {
+	milestones := config.GetMilestoneNames()
+
+	var versions []string
+	for _, v := range jIssue.Fields.FixVersions {
+		if !containsFold(milestones, v.Name) {
+			versions = appendUnique(versions, v.Name)
+		}
+	}
+	if ghIssue.Milestone != nil {
+		versions = appendUnique(versions, ghIssue.Milestone.GetTitle())
+	}
+
+	return versions
+}
+
+// didFixVersionsChange returns whether the fix versions of the JIRA issue
+// differ from those it should have for the milestone of the GitHub issue.
+func didFixVersionsChange(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue) bool {
+	current := make([]string, len(jIssue.Fields.FixVersions))
+	for i, v := range jIssue.Fields.FixVersions {
+		current[i] = v.Name
+	}
+
+	return !sameElements(syncFixVersions(config, ghIssue, jIssue), current)
+}
+
+// setFixVersionFields sets the fix versions on the fields of an issue being
+// created or updated. They are set through Unknowns so that an empty list is
+// still sent, removing every fix version.
+func setFixVersionFields(fields *jira.IssueFields, names []string) {
+	versions := make([]jira.FixVersion, len(names))
+	for i, name := range names {
+		versions[i] = jira.FixVersion{Name: name}
+	}
+	fields.Unknowns["fixVersions"] = versions
+}
@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+)
+
+func TestSyncMilestones(t *testing.T) {
+	due := func(date string) *time.Time {
+		d, err := time.Parse(versionDateFormat, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+	milestones := []github.Milestone{
+		{Number: github.Int(1), Title: github.String("v1.0"), State: github.String("open"), DueOn: due("2020-03-01")},
+		{Number: github.Int(2), Title: github.String("v0.9"), State: github.String("closed")},
+		{Number: github.Int(3), Title: github.String("v0.8"), State: github.String("closed")},
+		{Number: github.Int(4), Title: github.String("v0.7"), State: github.String("closed")},
+		{Number: github.Int(5), Title: github.String("v1.1"), State: github.String("open")},
+		{Number: github.Int(6), Title: github.String("v0.6"), State: github.String("closed"), DueOn: due("2019-06-01")},
+		{Number: github.Int(7), Title: github.String("v1.2"), State: github.String("open"), DueOn: due("2020-09-01")},
+	}
+	versions := []jira.Version{
+		{Name: "V0.7", ReleaseDate: "2019-01-01"},
+		{Name: "v1.1", Released: true},
+		{Name: "v0.6", ReleaseDate: "2019-06-01", Released: true},
+		{Name: "v1.2", ReleaseDate: "2020-06-01"},
+		{Name: "Backlog"},
+	}
+	ghIssues := []github.Issue{
+		{Number: github.Int(1), Milestone: &milestones[2]},
+		{Number: github.Int(2)},
+	}
+
+	tests := []struct {
+		name    string
+		release bool
+		want    []string
+	}{
+		{"sync", false, []string{
+			`CreateVersion SYNC v1.0 "2020-03-01" released=false`,
+			`CreateVersion SYNC v0.8 "" released=false`,
+			`UpdateVersion v1.2 "2020-09-01" released=false`,
+		}},
+		{"release", true, []string{
+			`CreateVersion SYNC v1.0 "2020-03-01" released=false`,
+			`CreateVersion SYNC v0.8 "" released=true`,
+			`UpdateVersion V0.7 "2019-01-01" released=true`,
+			`UpdateVersion v1.1 "" released=false`,
+			`UpdateVersion v1.2 "2020-09-01" released=false`,
+		}},
+	}
+
+	for _, test := range tests {
+		config := newTestConfig(t, map[string]interface{}{
+			"milestones": map[string]interface{}{"sync": true, "release": test.release},
+		}, nil)
+		g := &fakeGHClient{milestones: milestones}
+		j := &fakeJIRAClient{versions: append([]jira.Version(nil), versions...)}
+
+		if err := SyncMilestones(&config, ghIssues, g, j); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(j.calls, test.want) {
+			t.Errorf("%s: got JIRA calls %q; want %q", test.name, j.calls, test.want)
+		}
+
+		want := []string{"v1.0", "v0.9", "v0.8", "v0.7", "v1.1", "v0.6", "v1.2"}
+		if got := config.GetMilestoneNames(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got milestone names %q; want %q", test.name, got, want)
+		}
+	}
+}
+
+func TestSyncFixVersions(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{
+		"milestones": map[string]interface{}{"sync": true},
+	}, nil)
+	config.SetMilestoneNames([]string{"v1.0", "v1.1"})
+
+	tests := []struct {
+		name      string
+		milestone string
+		current   []string
+		want      []string
+	}{
+		{"none", "", nil, nil},
+		{"added", "v1.0", nil, []string{"v1.0"}},
+		{"unchanged", "v1.0", []string{"v1.0"}, []string{"v1.0"}},
+		{"changed", "v1.1", []string{"v1.0"}, []string{"v1.1"}},
+		{"removed", "", []string{"V1.0"}, nil},
+		{"set in JIRA", "v1.1", []string{"Backport", "v1.0"}, []string{"Backport", "v1.1"}},
+		{"set in JIRA only", "", []string{"Backport"}, []string{"Backport"}},
+	}
+
+	for _, test := range tests {
+		ghIssue := github.Issue{Number: github.Int(1)}
+		if test.milestone != "" {
+			ghIssue.Milestone = &github.Milestone{Title: github.String(test.milestone)}
+		}
+		jIssue := testJIRAIssue(config, "SYNC-1", 1)
+		for _, name := range test.current {
+			jIssue.Fields.FixVersions = append(jIssue.Fields.FixVersions, &jira.FixVersion{Name: name})
+		}
+
+		got := syncFixVersions(config, ghIssue, jIssue)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got fix versions %q; want %q", test.name, got, test.want)
+		}
+
+		wantChanged := !sameElements(test.want, test.current)
+		if changed := didFixVersionsChange(config, ghIssue, jIssue); changed != wantChanged {
+			t.Errorf("%s: fix versions changed %t; want %t", test.name, changed, wantChanged)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// The values of the GitHub Status field of orphaned JIRA issues, which aren't
+// reconciled again.
+const (
+	orphanDeleted = "deleted"
+	orphanMissing = "missing"
+)
+
+// ReconcileOrphans looks for the JIRA issues of each project of the configured
+// repositories whose GitHub issue is no longer in any of those repositories,
+// if it hasn't for the configured interval, and reconciles them (see
+// reconcileOrphan). An error reconciling one project is logged and does not
+// stop the others.
+func ReconcileOrphans(config cfg.Config, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	var projects []string
+	repos := map[string][]cfg.Config{}
+	for _, repoConfig := range config.Repos() {
+		project := repoConfig.GetProjectKey()
+		if _, ok := repos[project]; !ok {
+			projects = append(projects, project)
+		}
+		repos[project] = append(repos[project], repoConfig)
+	}
+
+	var failed []string
+	for _, project := range projects {
+		if !config.IsOrphanCheckDue(project) {
+			continue
+		}
+
+		start := time.Now()
+		if err := reconcileProjectOrphans(config, project, repos[project], ghClient, jClient); err != nil {
+			log.Errorf("Error reconciling orphaned issues of JIRA project %s. Error: %v", project, err)
+			failed = append(failed, project)
+			continue
+		}
+		config.SetOrphanChecked(project, start)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to reconcile orphaned issues: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// reconcileProjectOrphans reconciles the JIRA issues of a project whose GitHub
+// issue isn't in any of the repositories mirrored to it.
+func reconcileProjectOrphans(config cfg.Config, project string, repoConfigs []cfg.Config, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	log.Debugf("Looking for orphaned issues in JIRA project %s", project)
+
+	ids := map[int64]bool{}
+	for _, repoConfig := range repoConfigs {
+		owner, repo := repoConfig.GetRepo()
+		ghIssues, err := ghClient.ListIssues(owner, repo, time.Time{})
+		if err != nil {
+			return err
+		}
+		for _, ghIssue := range ghIssues {
+			ids[int64(ghIssue.GetID())] = true
+		}
+	}
+
+	jIssues, err := jClient.ListMirroredIssues(project)
+	if err != nil {
+		return err
+	}
+
+	for _, jIssue := range jIssues {
+		id, err := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID))
+		if err != nil || ids[id] {
+			continue
+		}
+		status, _ := jIssue.Fields.Unknowns.String(config.GetFieldKey(cfg.GitHubStatus))
+		if status == orphanDeleted || status == orphanMissing {
+			continue
+		}
+
+		if err := reconcileOrphan(config, jIssue, repoConfigs, ghClient, jClient); err != nil {
+			log.Errorf("Error reconciling orphaned JIRA issue %s. Error: %v", jIssue.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// reconcileOrphan finds out through the GitHub API what happened to the
+// GitHub issue a JIRA issue mirrors. If it was transferred to another
+// repository, the JIRA issue is relinked to it; if it was deleted, the JIRA
+// issue is closed, if configured to; otherwise, e.g. if it was converted to a
+// discussion, the JIRA issue is labeled as orphaned.
+func reconcileOrphan(config cfg.Config, jIssue jira.Issue, repoConfigs []cfg.Config, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	id, _ := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID))
+	number, err := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubNumber))
+	if err != nil {
+		return err
+	}
+
+	owner, repo, err := orphanRepo(config, jIssue, int(number), repoConfigs, jClient)
+	if err != nil {
+		return err
+	}
+	if owner == "" {
+		log.Warnf("Can't tell which repository the GitHub issue of JIRA issue %s was in; not reconciling it", jIssue.Key)
+		return nil
+	}
+
+	ghIssue, err := ghClient.GetIssue(owner, repo, int(number))
+	switch {
+	case err == clients.ErrIssueDeleted:
+		log.Infof("GitHub issue %s/%s#%d of JIRA issue %s was deleted", owner, repo, number, jIssue.Key)
+		return closeOrphan(config, jIssue, jClient)
+	case err == clients.ErrIssueNotFound:
+		log.Infof("GitHub issue %s/%s#%d of JIRA issue %s can't be found", owner, repo, number, jIssue.Key)
+		return labelOrphan(config, jIssue, orphanMissing, jClient)
+	case err != nil:
+		return err
+	}
+
+	newOwner, newRepo := issueRepo(ghIssue)
+	if strings.EqualFold(newOwner, owner) && strings.EqualFold(newRepo, repo) {
+		if int64(ghIssue.GetID()) != id {
+			log.Warnf("GitHub issue %s/%s#%d isn't the one JIRA issue %s mirrors; not reconciling it", owner, repo, number, jIssue.Key)
+		}
+		return nil
+	}
+
+	log.Infof("GitHub issue %s/%s#%d of JIRA issue %s was transferred to %s/%s#%d", owner, repo, number, jIssue.Key, newOwner, newRepo, ghIssue.GetNumber())
+	return relinkOrphan(config, jIssue, ghIssue, jClient)
+}
+
+// orphanRepo returns the repository the GitHub issue with the given number
+// mirrored by a JIRA issue was in: the one its remote link points to, or, if
+// it has no remote link to GitHub, the only repository mirrored to its
+// project. It returns empty strings if it can't tell, e.g. if the remote link
+// points to a repository which is no longer configured.
+func orphanRepo(config cfg.Config, jIssue jira.Issue, number int, repoConfigs []cfg.Config, jClient clients.JIRAClient) (string, string, error) {
+	links, err := jClient.ListRemoteLinks(jIssue)
+	if err != nil {
+		return "", "", err
+	}
+	linked := false
+	for _, link := range links {
+		if !isGitHubIssueURL(link.Object.URL) {
+			continue
+		}
+		linked = true
+
+		owner, repo := issueRepo(github.Issue{HTMLURL: github.String(link.Object.URL)})
+		for _, repoConfig := range repoConfigs {
+			o, r := repoConfig.GetRepo()
+			if strings.EqualFold(o, owner) && strings.EqualFold(r, repo) &&
+				strings.HasSuffix(link.Object.URL, fmt.Sprintf("/%d", number)) {
+				return o, r, nil
+			}
+		}
+	}
+
+	if !linked && len(repoConfigs) == 1 {
+		owner, repo := repoConfigs[0].GetRepo()
+		return owner, repo, nil
+	}
+
+	return "", "", nil
+}
+
+// isGitHubIssueURL returns whether a URL is the one of a GitHub issue or pull
+// request.
+func isGitHubIssueURL(url string) bool {
+	return strings.Contains(url, "/issues/") || strings.Contains(url, "/pull/")
+}
+
+// relinkOrphan points a JIRA issue to the GitHub issue its GitHub issue was
+// transferred to, so that it is mirrored from there if that repository is
+// configured.
+func relinkOrphan(config cfg.Config, jIssue jira.Issue, ghIssue github.Issue, jClient clients.JIRAClient) error {
+	fields := jira.IssueFields{
+		Type:     jIssue.Fields.Type,
+		Unknowns: map[string]interface{}{},
+	}
+	fields.Unknowns[config.GetFieldKey(cfg.GitHubID)] = ghIssue.GetID()
+	fields.Unknowns[config.GetFieldKey(cfg.GitHubNumber)] = ghIssue.GetNumber()
+	fields.Unknowns[config.GetFieldKey(cfg.GitHubStatus)] = ghIssue.GetState()
+	fields.Unknowns[config.GetFieldKey(cfg.LastISUpdate)] = time.Now().Format(dateFormat)
+
+	if _, err := jClient.UpdateIssue(jira.Issue{Key: jIssue.Key, ID: jIssue.ID, Fields: &fields}); err != nil {
+		return err
+	}
+
+	return jClient.SetRemoteLink(jIssue, githubLink(ghIssue, ghIssue.GetState(), "mirrors"))
+}
+
+// closeOrphan moves a JIRA issue whose GitHub issue was deleted to the
+// configured status, or labels it as orphaned if there is none.
+func closeOrphan(config cfg.Config, jIssue jira.Issue, jClient clients.JIRAClient) error {
+	rule, ok := config.GetOrphanTransitionRule()
+	if !ok {
+		return labelOrphan(config, jIssue, orphanDeleted, jClient)
+	}
+
+	if err := moveIssue(config, jIssue, rule, jClient); err != nil {
+		return err
+	}
+
+	return setOrphanFields(config, jIssue, orphanDeleted, nil, jClient)
+}
+
+// labelOrphan adds the configured label to an orphaned JIRA issue.
+func labelOrphan(config cfg.Config, jIssue jira.Issue, status string, jClient clients.JIRAClient) error {
+	labels := appendUnique(jIssue.Fields.Labels, config.GetOrphanLabel())
+	return setOrphanFields(config, jIssue, status, labels, jClient)
+}
+
+// setOrphanFields sets the GitHub Status field of an orphaned JIRA issue,
+// so that it isn't reconciled again, and its labels, if given.
+func setOrphanFields(config cfg.Config, jIssue jira.Issue, status string, labels []string, jClient clients.JIRAClient) error {
+	fields := jira.IssueFields{
+		Type:     jIssue.Fields.Type,
+		Labels:   labels,
+		Unknowns: map[string]interface{}{},
+	}
+	fields.Unknowns[config.GetFieldKey(cfg.GitHubStatus)] = status
+	fields.Unknowns[config.GetFieldKey(cfg.LastISUpdate)] = time.Now().Format(dateFormat)
+
+	_, err := jClient.UpdateIssue(jira.Issue{Key: jIssue.Key, ID: jIssue.ID, Fields: &fields})
+	return err
+}
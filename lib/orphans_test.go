@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+func TestOrphanRepo(t *testing.T) {
+	config := newTestConfig(t, nil, nil)
+	repoConfigs := []cfg.Config{config}
+
+	tests := []struct {
+		name  string
+		links []clients.RemoteLink
+		want  string
+	}{
+		{"no link", nil, "coreos/issue-sync"},
+		{"other link", []clients.RemoteLink{{Object: clients.RemoteLinkObject{URL: "https://wiki.example.com/page"}}}, "coreos/issue-sync"},
+		{"configured repo", []clients.RemoteLink{{Object: clients.RemoteLinkObject{URL: "https://github.com/coreos/issue-sync/issues/3"}}}, "coreos/issue-sync"},
+		{"other number", []clients.RemoteLink{{Object: clients.RemoteLinkObject{URL: "https://github.com/coreos/issue-sync/issues/4"}}}, "/"},
+		{"removed repo", []clients.RemoteLink{{Object: clients.RemoteLinkObject{URL: "https://github.com/coreos/archived/issues/3"}}}, "/"},
+	}
+
+	for _, test := range tests {
+		j := &fakeJIRAClient{links: map[string][]clients.RemoteLink{"SYNC-1": test.links}}
+		owner, repo, err := orphanRepo(config, jira.Issue{Key: "SYNC-1"}, 3, repoConfigs, j)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := owner + "/" + repo; got != test.want {
+			t.Errorf("%s: got %q; want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestOrphanCheckPersisted(t *testing.T) {
+	file := testStateFile(t)
+	settings := map[string]interface{}{
+		"state":   map[string]string{"file": file},
+		"orphans": map[string]interface{}{"sync": true, "interval": "1h"},
+	}
+
+	config := newTestConfig(t, settings, nil)
+	if !config.IsOrphanCheckDue("SYNC") {
+		t.Fatalf("first run: check not due")
+	}
+	config.SetOrphanChecked("SYNC", time.Now())
+	if err := config.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	if newTestConfig(t, settings, nil).IsOrphanCheckDue("SYNC") {
+		t.Errorf("next run: check due within the interval")
+	}
+}
@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// issuePriority returns the name of the JIRA priority the labels of a GitHub
+// issue map to. If no rule matches, or several rules match different
+// priorities and the configuration says to ignore conflicts, it returns the
+// empty string, and the priority should be left as it is.
+func issuePriority(config cfg.Config, ghIssue github.Issue) string {
+	log := config.GetLogger()
+
+	var matched []string
+	for _, rule := range config.GetPriorityRules() {
+		for _, label := range ghIssue.Labels {
+			if strings.EqualFold(rule.Label, label.GetName()) {
+				matched = appendUnique(matched, rule.Priority)
+				break
+			}
+		}
+	}
+
+	if len(matched) == 0 {
+		return ""
+	}
+	if len(matched) == 1 {
+		return matched[0]
+	}
+
+	switch config.GetPriorityConflict() {
+	case cfg.PriorityConflictIgnore:
+		log.Debugf("GitHub issue #%d has labels for conflicting priorities (%s); leaving priority unchanged",
+			ghIssue.GetNumber(), strings.Join(matched, ", "))
+		return ""
+	case cfg.PriorityConflictFirst:
+		return matched[0]
+	}
+
+	selected := matched[0]
+	for _, priority := range matched[1:] {
+		higher := config.GetPriorityRank(priority) < config.GetPriorityRank(selected)
+		if higher == (config.GetPriorityConflict() == cfg.PriorityConflictHighest) {
+			selected = priority
+		}
+	}
+
+	log.Debugf("GitHub issue #%d has labels for conflicting priorities (%s); using %s",
+		ghIssue.GetNumber(), strings.Join(matched, ", "), selected)
+
+	return selected
+}
+
+// didPriorityChange returns whether the priority of the JIRA issue differs
+// from the one the labels of the GitHub issue map to.
+func didPriorityChange(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue) bool {
+	priority := issuePriority(config, ghIssue)
+	if priority == "" {
+		return false
+	}
+	return jIssue.Fields.Priority == nil || !strings.EqualFold(jIssue.Fields.Priority.Name, priority)
+}
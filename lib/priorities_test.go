@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+func TestIssuePriority(t *testing.T) {
+	// The rules are listed lowest first, so that the first matching rule
+	// is not the highest one.
+	rules := []map[string]string{
+		{"label": "minor", "priority": "Low"},
+		{"label": "trivial", "priority": "low"},
+		{"label": "important", "priority": "High"},
+		{"label": "urgent", "priority": "Highest"},
+	}
+	responses := map[string]interface{}{
+		"/rest/api/2/priority": []jira.Priority{
+			{Name: "Highest"}, {Name: "High"}, {Name: "Medium"}, {Name: "Low"},
+		},
+	}
+
+	configs := map[string]cfg.Config{}
+	for _, conflict := range []string{
+		cfg.PriorityConflictHighest,
+		cfg.PriorityConflictLowest,
+		cfg.PriorityConflictFirst,
+		cfg.PriorityConflictIgnore,
+	} {
+		configs[conflict] = newTestConfig(t, map[string]interface{}{
+			"priorities": map[string]interface{}{"conflict": conflict, "rules": rules},
+		}, responses)
+	}
+
+	tests := []struct {
+		name     string
+		conflict string
+		labels   []string
+		want     string
+	}{
+		{"no labels", cfg.PriorityConflictHighest, nil, ""},
+		{"no rule", cfg.PriorityConflictHighest, []string{"bug"}, ""},
+		{"single rule", cfg.PriorityConflictHighest, []string{"Important"}, "High"},
+		{"single rule lowest", cfg.PriorityConflictLowest, []string{"important"}, "High"},
+		{"same priority", cfg.PriorityConflictIgnore, []string{"minor", "trivial"}, "Low"},
+		{"highest", cfg.PriorityConflictHighest, []string{"minor", "important", "urgent"}, "Highest"},
+		{"highest of two", cfg.PriorityConflictHighest, []string{"urgent", "important"}, "Highest"},
+		{"lowest", cfg.PriorityConflictLowest, []string{"important", "urgent", "minor"}, "Low"},
+		{"lowest of two", cfg.PriorityConflictLowest, []string{"urgent", "important"}, "High"},
+		{"first", cfg.PriorityConflictFirst, []string{"urgent", "minor"}, "Low"},
+		{"first of two", cfg.PriorityConflictFirst, []string{"urgent", "important"}, "High"},
+		{"ignore", cfg.PriorityConflictIgnore, []string{"urgent", "minor"}, ""},
+	}
+
+	for _, test := range tests {
+		labels := make([]github.Label, len(test.labels))
+		for i, name := range test.labels {
+			labels[i] = github.Label{Name: github.String(name)}
+		}
+		ghIssue := github.Issue{Number: github.Int(1), Labels: labels}
+
+		if got := issuePriority(configs[test.conflict], ghIssue); got != test.want {
+			t.Errorf("%s: got priority %q; want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGetPriorityRank(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{
+		"priorities": map[string]interface{}{
+			"rules": []map[string]string{{"label": "urgent", "priority": "Highest"}},
+		},
+	}, map[string]interface{}{
+		"/rest/api/2/priority": []jira.Priority{{Name: "Highest"}, {Name: "Medium"}, {Name: "Low"}},
+	})
+
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"Highest", 0},
+		{"medium", 1},
+		{"Low", 2},
+		{"Unknown", 3},
+	}
+
+	for _, test := range tests {
+		if got := config.GetPriorityRank(test.name); got != test.want {
+			t.Errorf("%s: got rank %d; want %d", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// The states of a pull request, as mirrored to JIRA. Open pull requests are
+// "approved" or "changes_requested" once they have been reviewed; closed pull
+// requests are "merged" if they were merged.
+const (
+	pullOpen             = "open"
+	pullApproved         = "approved"
+	pullChangesRequested = "changes_requested"
+	pullMerged           = "merged"
+	pullClosed           = "closed"
+)
+
+// closingKeywordRegex matches the references to the issues a pull request
+// closes, with the keywords GitHub recognizes. It has matching groups for the
+// repository (\1, if another repository is referenced) and the issue number (\2).
+var closingKeywordRegex = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+(?:([\w.-]+/[\w.-]+))?#(\d+)\b`)
+
+// isPullRequest returns whether a GitHub issue is actually a pull request.
+func isPullRequest(ghIssue github.Issue) bool {
+	return ghIssue.PullRequestLinks != nil
+}
+
+// pullRequestState returns the state of a pull request: whether it was merged
+// if it is closed, and the outcome of its reviews if it is open. The latest
+// review of each reviewer counts; a request for changes outweighs approvals.
+func pullRequestState(config cfg.Config, ghIssue github.Issue, ghClient clients.GitHubClient) (string, error) {
+	owner, repo := config.GetRepo()
+
+	if ghIssue.GetState() == "closed" {
+		pull, err := ghClient.GetPullRequest(owner, repo, ghIssue.GetNumber())
+		if err != nil {
+			return "", err
+		}
+		if pull.GetMerged() {
+			return pullMerged, nil
+		}
+		return pullClosed, nil
+	}
+
+	reviews, err := ghClient.ListReviews(owner, repo, ghIssue.GetNumber())
+	if err != nil {
+		return "", err
+	}
+
+	latest := map[string]string{}
+	for _, review := range reviews {
+		switch state := review.GetState(); state {
+		case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+			latest[review.User.GetLogin()] = state
+		}
+	}
+
+	state := pullOpen
+	for _, s := range latest {
+		if s == "CHANGES_REQUESTED" {
+			return pullChangesRequested, nil
+		}
+		if s == "APPROVED" {
+			state = pullApproved
+		}
+	}
+	return state, nil
+}
+
+// githubStatus returns the value of the GitHub Status field of the JIRA issue
+// mirroring a GitHub issue: the state of the issue or, for pull requests, the
+// state returned by pullRequestState.
+func githubStatus(config cfg.Config, ghIssue github.Issue, ghClient clients.GitHubClient) (string, error) {
+	if isPullRequest(ghIssue) {
+		return pullRequestState(config, ghIssue, ghClient)
+	}
+	return ghIssue.GetState(), nil
+}
+
+// closedIssues returns the numbers of the issues of the repository owner/repo
+// which a pull request of that repository closes when it is merged, as
+// referenced in its body.
+func closedIssues(ghIssue github.Issue, owner, repo string) []int {
+	var numbers []int
+	seen := map[int]bool{}
+	for _, m := range closingKeywordRegex.FindAllStringSubmatch(ghIssue.GetBody(), -1) {
+		if m[1] != "" && !strings.EqualFold(m[1], fmt.Sprintf("%s/%s", owner, repo)) {
+			continue
+		}
+		number, err := strconv.Atoi(m[2])
+		if err != nil || seen[number] {
+			continue
+		}
+		seen[number] = true
+		numbers = append(numbers, number)
+	}
+	return numbers
+}
+
+// LinkPullRequest adds a remote link to a pull request on the JIRA issues
+// mirroring the GitHub issues it closes, or updates it to reflect the state
+// of the pull request. Issues which haven't been mirrored are skipped.
+func LinkPullRequest(config cfg.Config, ghIssue github.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	owner, repo := config.GetRepo()
+
+	numbers := closedIssues(ghIssue, owner, repo)
+	if len(numbers) == 0 {
+		log.Debugf("Pull request #%d doesn't close any issue", ghIssue.GetNumber())
+		return nil
+	}
+
+	state, err := pullRequestState(config, ghIssue, ghClient)
+	if err != nil {
+		return err
+	}
+	link := githubLink(ghIssue, state, "fixed by")
+
+	for _, number := range numbers {
+		closed, err := ghClient.GetIssue(owner, repo, number)
+		if err == clients.ErrIssueDeleted || err == clients.ErrIssueNotFound {
+			log.Debugf("GitHub issue #%d closed by pull request #%d can't be found", number, ghIssue.GetNumber())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if isPullRequest(closed) {
+			continue
+		}
+
+		jIssues, err := jClient.ListIssues(config.GetProjectKey(), []int{closed.GetID()})
+		if err != nil {
+			return err
+		}
+		if len(jIssues) == 0 {
+			log.Debugf("GitHub issue #%d closed by pull request #%d hasn't been mirrored", number, ghIssue.GetNumber())
+			continue
+		}
+
+		for _, jIssue := range jIssues {
+			if err := jClient.SetRemoteLink(jIssue, link); err != nil {
+				return err
+			}
+			log.Debugf("Linked pull request #%d (%s) from JIRA issue %s", ghIssue.GetNumber(), state, jIssue.Key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+)
+
+func TestClosedIssues(t *testing.T) {
+	body := "Fixes #12, closes: #3 and resolved #12.\n\nRelated to #7; fixes other/repo#5, Fixes coreos/Issue-Sync#8; prefix#9"
+	issue := github.Issue{Body: github.String(body)}
+
+	want := []int{12, 3, 8}
+	if got := closedIssues(issue, "coreos", "issue-sync"); !reflect.DeepEqual(got, want) {
+		t.Errorf("closedIssues(%q) = %v; want %v", body, got, want)
+	}
+}
+
+func TestLinkPullRequest(t *testing.T) {
+	config := newTestConfig(t, nil, nil)
+
+	review := func(login, state string) github.PullRequestReview {
+		return github.PullRequestReview{User: &github.User{Login: github.String(login)}, State: github.String(state)}
+	}
+
+	tests := []struct {
+		name    string
+		state   string
+		merged  bool
+		reviews []github.PullRequestReview
+		want    string
+	}{
+		{"open", "open", false, nil, "open resolved=false"},
+		{"commented", "open", false, []github.PullRequestReview{review("alice", "COMMENTED")}, "open resolved=false"},
+		{"approved", "open", false, []github.PullRequestReview{review("alice", "APPROVED")}, "approved resolved=false"},
+		{"changes requested", "open", false, []github.PullRequestReview{
+			review("alice", "APPROVED"),
+			review("bob", "CHANGES_REQUESTED"),
+		}, "changes requested resolved=false"},
+		{"approved after changes", "open", false, []github.PullRequestReview{
+			review("alice", "CHANGES_REQUESTED"),
+			review("alice", "APPROVED"),
+		}, "approved resolved=false"},
+		{"dismissed", "open", false, []github.PullRequestReview{
+			review("alice", "APPROVED"),
+			review("alice", "DISMISSED"),
+		}, "open resolved=false"},
+		{"merged", "closed", true, nil, "merged resolved=true"},
+		{"closed", "closed", false, []github.PullRequestReview{review("alice", "APPROVED")}, "closed resolved=true"},
+	}
+
+	for _, test := range tests {
+		pull := github.Issue{
+			Number:           github.Int(20),
+			State:            github.String(test.state),
+			Body:             github.String("Fixes #12"),
+			HTMLURL:          github.String("https://github.com/coreos/issue-sync/pull/20"),
+			PullRequestLinks: &github.PullRequestLinks{},
+		}
+		gh := &fakeGHClient{
+			issues:  []github.Issue{{ID: github.Int(112), Number: github.Int(12)}},
+			pulls:   map[int]github.PullRequest{20: {Merged: github.Bool(test.merged)}},
+			reviews: map[int][]github.PullRequestReview{20: test.reviews},
+		}
+		j := &fakeJIRAClient{issues: []jira.Issue{testJIRAIssue(config, "SYNC-1", 112)}}
+
+		if err := LinkPullRequest(config, pull, gh, j); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		want := []string{"SetRemoteLink SYNC-1 coreos/issue-sync#20 " + test.want}
+		if !reflect.DeepEqual(j.calls, want) {
+			t.Errorf("%s: got JIRA calls %q; want %q", test.name, j.calls, want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package lib
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// issueRef is a reference to a GitHub issue. Owner and Repo are lowercase.
+type issueRef struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+// reference is a reference to a GitHub issue in the body of an issue or
+// comment, with the keyword preceding it, if any.
+type reference struct {
+	issueRef
+	Keyword string
+}
+
+// newIssueRef returns the reference to the issue with the given number in the
+// repository named by repo ("owner/repo"), or in owner/repo if it is empty.
+func newIssueRef(repo string, number int, owner, defaultRepo string) issueRef {
+	if parts := strings.SplitN(repo, "/", 2); len(parts) == 2 {
+		owner, defaultRepo = parts[0], parts[1]
+	}
+	return issueRef{
+		Owner:  strings.ToLower(owner),
+		Repo:   strings.ToLower(defaultRepo),
+		Number: number,
+	}
+}
+
+// referenceRegexFor returns the regex matching the references to GitHub
+// issues, optionally preceded by one of the configured keywords. It has
+// matching groups for the keyword (\2), the repository (\3, if another
+// repository is referenced) and the issue number (\4).
+func referenceRegexFor(config cfg.Config) *regexp.Regexp {
+	var keywords []string
+	for _, k := range config.GetReferenceKeywords() {
+		keywords = append(keywords, strings.Join(strings.Fields(regexp.QuoteMeta(k.Keyword)), `\s+`))
+	}
+	// Longer keywords first, so that e.g. "blocked by" isn't matched as "blocked".
+	sort.Slice(keywords, func(i, j int) bool {
+		return len(keywords[i]) > len(keywords[j])
+	})
+
+	return regexp.MustCompile(`(?i)(^|[^\w/#&.-])(?:(` + strings.Join(keywords, "|") + `)\s*:?\s+)?(?:([\w.-]+/[\w.-]+))?#(\d+)\b`)
+}
+
+// parseReferences returns the references to GitHub issues in body, a body of
+// an issue or comment of the repository owner/repo. References in code are
+// skipped.
+func parseReferences(config cfg.Config, body, owner, repo string) []reference {
+	regex := referenceRegexFor(config)
+
+	var refs []reference
+	for _, line := range proseLines(body) {
+		for _, m := range regex.FindAllStringSubmatch(line, -1) {
+			number, err := strconv.Atoi(m[4])
+			if err != nil {
+				continue
+			}
+			refs = append(refs, reference{
+				issueRef: newIssueRef(m[3], number, owner, repo),
+				Keyword:  m[2],
+			})
+		}
+	}
+
+	return refs
+}
+
+// resolveReferences returns the JIRA issues mirroring the referenced GitHub
+// issues. References to issues of repositories which aren't configured, and
+// to issues which haven't been mirrored, are left out. The IDs of the issues
+// are taken from the state, and only retrieved from GitHub if they aren't known.
+func resolveReferences(config cfg.Config, refs []issueRef, ghClient clients.GitHubClient, jClient clients.JIRAClient) (map[issueRef]jira.Issue, error) {
+	log := config.GetLogger()
+
+	var projects []string
+	ids := map[string][]int{}
+	byID := map[int]issueRef{}
+	for _, ref := range refs {
+		var repoConfig *cfg.Config
+		for _, c := range config.Repos() {
+			owner, repo := c.GetRepo()
+			if strings.EqualFold(owner, ref.Owner) && strings.EqualFold(repo, ref.Repo) {
+				repoConfig = &c
+				break
+			}
+		}
+		if repoConfig == nil {
+			continue
+		}
+
+		owner, repo := repoConfig.GetRepo()
+		id, ok := config.GetIssueID(owner+"/"+repo, ref.Number)
+		if !ok {
+			ghIssue, err := ghClient.GetIssue(owner, repo, ref.Number)
+			if err == clients.ErrIssueDeleted || err == clients.ErrIssueNotFound {
+				log.Debugf("Referenced GitHub issue %s/%s#%d can't be found", owner, repo, ref.Number)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			id = ghIssue.GetID()
+			config.SetIssueID(owner+"/"+repo, ref.Number, id)
+		}
+
+		project := repoConfig.GetProjectKey()
+		if _, ok := ids[project]; !ok {
+			projects = append(projects, project)
+		}
+		ids[project] = append(ids[project], id)
+		byID[id] = ref
+	}
+
+	resolved := map[issueRef]jira.Issue{}
+	for _, project := range projects {
+		jIssues, err := jClient.ListIssues(project, ids[project])
+		if err != nil {
+			return nil, err
+		}
+		for _, jIssue := range jIssues {
+			id, err := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID))
+			if err != nil {
+				continue
+			}
+			if ref, ok := byID[int(id)]; ok {
+				resolved[ref] = jIssue
+			}
+		}
+	}
+
+	return resolved, nil
+}
+
+// referenceFuncFor returns the function converting the references to GitHub
+// issues in body to the keys of the JIRA issues mirroring them, or the empty
+// string for issues which haven't been mirrored. The issues are looked up
+// beforehand, so that errors can be returned.
+func referenceFuncFor(config cfg.Config, body string, ghClient clients.GitHubClient, jClient clients.JIRAClient) (func(string, int) string, error) {
+	owner, repo := config.GetRepo()
+
+	var refs []issueRef
+	seen := map[issueRef]bool{}
+	for _, line := range proseLines(body) {
+		for _, m := range mdReferenceRegex.FindAllStringSubmatch(line, -1) {
+			number, err := strconv.Atoi(m[3])
+			if err != nil {
+				continue
+			}
+			ref := newIssueRef(m[2], number, owner, repo)
+			if !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	resolved, err := resolveReferences(config, refs, ghClient, jClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(r string, number int) string {
+		return resolved[newIssueRef(r, number, owner, repo)].Key
+	}, nil
+}
+
+// LinkReferences links the JIRA issue of a GitHub issue to the JIRA issues
+// mirroring the issues referenced in its body, if scanBody is true, and in the
+// given comments, with the issue link type of the keyword preceding each
+// reference, or the configured link type for plain references. Links are only
+// added: removing a reference doesn't remove its link, so only the body and
+// comments which changed need to be given.
+func LinkReferences(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, scanBody bool, comments []*github.IssueComment, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	owner, repo := config.GetRepo()
+
+	var texts []string
+	if scanBody {
+		texts = append(texts, ghIssue.GetBody())
+	}
+	for _, comment := range comments {
+		if !isBackReferenceComment(config, *comment, jIssue.Key) {
+			texts = append(texts, comment.GetBody())
+		}
+	}
+
+	self := newIssueRef("", ghIssue.GetNumber(), owner, repo)
+
+	var refs []reference
+	var issueRefs []issueRef
+	seen := map[issueRef]bool{}
+	for _, text := range texts {
+		for _, ref := range parseReferences(config, text, owner, repo) {
+			if ref.issueRef == self {
+				continue
+			}
+			refs = append(refs, ref)
+			if !seen[ref.issueRef] {
+				seen[ref.issueRef] = true
+				issueRefs = append(issueRefs, ref.issueRef)
+			}
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	resolved, err := resolveReferences(config, issueRefs, ghClient, jClient)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		target, ok := resolved[ref.issueRef]
+		if !ok || target.Key == jIssue.Key {
+			continue
+		}
+
+		linkType := config.GetReferenceLinkType()
+		from, to := jIssue, target
+		if keyword, ok := config.GetReferenceKeyword(ref.Keyword); ok {
+			linkType = keyword.LinkType
+			if keyword.Inward {
+				from, to = target, jIssue
+			}
+		}
+		if linkType == "" {
+			continue
+		}
+
+		created, err := createLink(config, jIssue, linkType, from, to, jClient)
+		if err != nil {
+			return err
+		}
+		if created {
+			log.Debugf("Linked JIRA issue %s to %s (%s)", from.Key, to.Key, linkType)
+		}
+	}
+
+	return nil
+}
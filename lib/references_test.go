@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+func TestParseReferences(t *testing.T) {
+	tests := []struct {
+		body string
+		want []reference
+	}{
+		{"See #7.", []reference{{issueRef: issueRef{"coreos", "issue-sync", 7}}}},
+		{"Duplicate of #12", []reference{{issueRef: issueRef{"coreos", "issue-sync", 12}, Keyword: "Duplicate of"}}},
+		{"blocked by: Coreos/Etcd#5", []reference{{issueRef: issueRef{"coreos", "etcd", 5}, Keyword: "blocked by"}}},
+		{"Blocks `#9` and #10", []reference{{issueRef: issueRef{"coreos", "issue-sync", 10}}}},
+		{"```\n#8\n```", nil},
+		{"not a#11 nor &#12;", nil},
+	}
+
+	for _, test := range tests {
+		if got := parseReferences(cfg.Config{}, test.body, "coreos", "issue-sync"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseReferences(%q) = %v; want %v", test.body, got, test.want)
+		}
+	}
+}
+
+// testLinkTypes is the response of the fake JIRA server listing the issue
+// link types used by the default reference keywords.
+var testLinkTypes = map[string]interface{}{
+	"/rest/api/2/issueLinkType": map[string]interface{}{
+		"issueLinkTypes": []jira.IssueLinkType{{Name: "Relates"}, {Name: "Duplicate"}, {Name: "Blocks"}},
+	},
+}
+
+func TestLinkReferences(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{"references": map[string]bool{"sync": true}}, testLinkTypes)
+
+	jiraIssue := func(key string, id int) jira.Issue {
+		return testJIRAIssue(config, key, id)
+	}
+	ghIssue := func(number int) github.Issue {
+		return github.Issue{ID: github.Int(number * 100), Number: github.Int(number)}
+	}
+	comment := func(body string) *github.IssueComment {
+		return &github.IssueComment{Body: github.String(body)}
+	}
+	linked := jiraIssue("SYNC-1", 100)
+	linked.Fields.IssueLinks = []*jira.IssueLink{{Type: jira.IssueLinkType{Name: "Relates"}, OutwardIssue: &jira.Issue{Key: "SYNC-2"}}}
+
+	tests := []struct {
+		name     string
+		body     string
+		scanBody bool
+		comments []*github.IssueComment
+		jIssue   jira.Issue
+		want     []string
+	}{
+		{"plain", "See #2", true, nil, jiraIssue("SYNC-1", 100), []string{"LinkIssues Relates SYNC-1 SYNC-2"}},
+		{"outward keyword", "Blocks #2", true, nil, jiraIssue("SYNC-1", 100), []string{"LinkIssues Blocks SYNC-1 SYNC-2"}},
+		{"inward keyword", "Blocked by #2", true, nil, jiraIssue("SYNC-1", 100), []string{"LinkIssues Blocks SYNC-2 SYNC-1"}},
+		{"duplicates", "#2 and #2", true, []*github.IssueComment{comment("see #2")}, jiraIssue("SYNC-1", 100), []string{"LinkIssues Relates SYNC-1 SYNC-2"}},
+		{"both types", "#2", true, []*github.IssueComment{comment("blocks #2")}, jiraIssue("SYNC-1", 100), []string{"LinkIssues Relates SYNC-1 SYNC-2", "LinkIssues Blocks SYNC-1 SYNC-2"}},
+		{"comments only", "#2", false, []*github.IssueComment{comment("blocks #2")}, jiraIssue("SYNC-1", 100), []string{"LinkIssues Blocks SYNC-1 SYNC-2"}},
+		{"self", "#1", true, nil, jiraIssue("SYNC-1", 100), nil},
+		{"not mirrored", "#3 and #4", true, nil, jiraIssue("SYNC-1", 100), nil},
+		{"other repository", "coreos/etcd#2", true, nil, jiraIssue("SYNC-1", 100), nil},
+		{"already linked", "See #2", true, nil, linked, nil},
+	}
+
+	for _, test := range tests {
+		config.ResetIssueLinks()
+		gh := &fakeGHClient{issues: []github.Issue{ghIssue(1), ghIssue(2), ghIssue(3)}}
+		j := &fakeJIRAClient{issues: []jira.Issue{jiraIssue("SYNC-1", 100), jiraIssue("SYNC-2", 200)}}
+
+		issue := ghIssue(1)
+		issue.Body = github.String(test.body)
+		if err := LinkReferences(config, issue, test.jIssue, test.scanBody, test.comments, gh, j); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(j.calls, test.want) {
+			t.Errorf("%s: got %q; want %q", test.name, j.calls, test.want)
+		}
+	}
+}
+
+func TestResolveReferencesUsesKnownIDs(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{"references": map[string]bool{"sync": true}}, testLinkTypes)
+	config.SetIssueID("coreos/issue-sync", 2, 200)
+
+	gh := &fakeGHClient{issues: []github.Issue{{ID: github.Int(300), Number: github.Int(3)}}}
+	j := &fakeJIRAClient{}
+	refs := []issueRef{{"coreos", "issue-sync", 2}, {"coreos", "issue-sync", 3}}
+
+	for run := 0; run < 2; run++ {
+		if _, err := resolveReferences(config, refs, gh, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		call string
+		want int
+	}{
+		{"GetIssue coreos/issue-sync#2", 0},
+		{"GetIssue coreos/issue-sync#3", 1},
+	}
+	for _, test := range tests {
+		if got := gh.count(test.call); got != test.want {
+			t.Errorf("%s called %d times; want %d", test.call, got, test.want)
+		}
+	}
+}
+
+func TestCreateLinkReleasesFailedLinks(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{"references": map[string]bool{"sync": true}}, testLinkTypes)
+	from, to := testJIRAIssue(config, "SYNC-1", 100), testJIRAIssue(config, "SYNC-2", 200)
+
+	if _, err := createLink(config, from, "Relates", from, to, failingLinkJIRAClient{&fakeJIRAClient{}}); err == nil {
+		t.Fatal("creating the link didn't fail")
+	}
+	if !config.ClaimIssueLink("Relates", "SYNC-2", "SYNC-1") {
+		t.Error("the link which failed is still claimed")
+	}
+}
+
+// failingLinkJIRAClient is a fakeJIRAClient failing to link issues.
+type failingLinkJIRAClient struct {
+	*fakeJIRAClient
+}
+
+func (j failingLinkJIRAClient) LinkIssues(linkType string, from, to jira.Issue) error {
+	return errors.New("link failed")
+}
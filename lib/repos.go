@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// DiscoverRepos lists the repositories of each configured GitHub organization
+// or user, and updates the configuration so that those which pass the org's
+// filters are synchronized by the next call to CompareIssues. If listing the
+// repositories of an org fails, the repositories discovered from it on a
+// previous run are kept, and the remaining orgs are still discovered.
+func DiscoverRepos(config *cfg.Config, ghClient clients.GitHubClient) error {
+	log := config.GetLogger()
+
+	var failed []string
+	for _, org := range config.GetOrgs() {
+		repos, err := ghClient.ListRepositories(org.Owner, org.User)
+		if err != nil {
+			log.Errorf("Error discovering repositories of %s. Error: %v", org.Owner, err)
+			failed = append(failed, org.Owner)
+			continue
+		}
+
+		var names []string
+		for _, repo := range repos {
+			if repoMatches(org, repo) {
+				names = append(names, repo.GetFullName())
+			}
+		}
+
+		log.Debugf("Discovered %d of %d repositories of %s", len(names), len(repos), org.Owner)
+
+		config.SetDiscoveredRepos(org, names)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to discover repositories of: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// repoMatches returns whether a GitHub repository passes all of the filters
+// configured on the org it was discovered from.
+func repoMatches(org cfg.Org, repo clients.Repository) bool {
+	if repo.Archived != nil && *repo.Archived && !org.Archived {
+		return false
+	}
+
+	switch org.Visibility {
+	case "public":
+		if repo.GetPrivate() {
+			return false
+		}
+	case "private":
+		if !repo.GetPrivate() {
+			return false
+		}
+	}
+
+	name := repo.GetName()
+
+	if len(org.Include) > 0 && !matchAny(org.Include, name) {
+		return false
+	}
+	if matchAny(org.Exclude, name) {
+		return false
+	}
+
+	if len(org.Topics) > 0 {
+		found := false
+		for _, topic := range org.Topics {
+			for _, t := range repo.Topics {
+				if strings.EqualFold(topic, t) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchAny returns whether name matches any of the glob patterns provided.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// Patterns are validated in the configuration, so the error can be ignored
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
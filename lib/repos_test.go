@@ -0,0 +1,49 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+func TestRepoMatches(t *testing.T) {
+	newRepo := func(name string, private, archived bool, topics ...string) clients.Repository {
+		return clients.Repository{
+			Repository: github.Repository{
+				Name:    github.String(name),
+				Private: github.Bool(private),
+			},
+			Archived: github.Bool(archived),
+			Topics:   topics,
+		}
+	}
+
+	org := cfg.Org{
+		Owner:      "coreos",
+		Include:    []string{"etcd*", "issue-sync"},
+		Exclude:    []string{"etcd-*-old"},
+		Topics:     []string{"jira"},
+		Visibility: "public",
+	}
+
+	tests := []struct {
+		repo clients.Repository
+		want bool
+	}{
+		{newRepo("etcd", false, false, "jira"), true},
+		{newRepo("issue-sync", false, false, "go", "JIRA"), true},
+		{newRepo("etcd", false, false), false},
+		{newRepo("etcd", true, false, "jira"), false},
+		{newRepo("etcd", false, true, "jira"), false},
+		{newRepo("etcd-operator-old", false, false, "jira"), false},
+		{newRepo("rkt", false, false, "jira"), false},
+	}
+
+	for _, test := range tests {
+		if got := repoMatches(org, test.repo); got != test.want {
+			t.Errorf("repoMatches(%s) = %t; want %t", test.repo.GetName(), got, test.want)
+		}
+	}
+}
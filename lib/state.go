@@ -0,0 +1,93 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// RebuildState records the key of every JIRA issue of the projects of the
+// configured repositories which mirrors a GitHub issue, forgetting everything
+// else. The content of the issues is compared again on the next run, and the
+// comments are matched by their header again.
+func RebuildState(config cfg.Config, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	log.Info("Rebuilding the state from JIRA")
+
+	config.ResetState()
+
+	seen := map[string]bool{}
+	for _, repoConfig := range config.Repos() {
+		project := repoConfig.GetProjectKey()
+		if seen[project] {
+			continue
+		}
+		seen[project] = true
+
+		jIssues, err := jClient.ListMirroredIssues(project)
+		if err != nil {
+			return err
+		}
+		for _, jIssue := range jIssues {
+			id, err := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID))
+			if err != nil {
+				continue
+			}
+			config.SetMirroredIssue(int(id), jIssue.Key, "")
+		}
+
+		log.Debugf("Recorded %d mirrored issues of JIRA project %s", len(jIssues), project)
+	}
+
+	return nil
+}
+
+// issueHash returns the hash of the content of a GitHub issue which is
+// mirrored to the fields of its JIRA issue, given the status of the issue
+// (see githubStatus), its body converted to JIRA markup, and the JIRA users
+// it maps its reporter and assignee to. The mapping configuration is part of
+// the hash, so that every issue is compared again once it changes.
+func issueHash(config cfg.Config, ghIssue github.Issue, status, description, reporter, assignee string) string {
+	var assignees []string
+	for _, a := range ghIssue.Assignees {
+		assignees = append(assignees, a.GetLogin())
+	}
+	milestone := ""
+	if ghIssue.Milestone != nil {
+		milestone = ghIssue.Milestone.GetTitle()
+	}
+
+	return contentHash(
+		config.GetMappingFingerprint(),
+		ghIssue.GetTitle(),
+		description,
+		status,
+		ghIssue.User.GetLogin(),
+		strings.Join(githubLabels(ghIssue), ","),
+		milestone,
+		strings.Join(assignees, ","),
+		reporter,
+		assignee,
+	)
+}
+
+// commentHash returns the hash of the body of a GitHub comment, along with the
+// mapping configuration, as for issueHash.
+func commentHash(config cfg.Config, ghComment github.IssueComment) string {
+	return contentHash(config.GetMappingFingerprint(), ghComment.GetBody())
+}
+
+// contentHash returns the hex-encoded SHA-256 hash of the given values.
+func contentHash(values ...string) string {
+	h := sha256.New()
+	for _, v := range values {
+		fmt.Fprintf(h, "%d:%s;", len(v), v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,178 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+	"github.com/trivago/tgo/tcontainer"
+)
+
+// testStateFile returns the path to a state file in a temporary directory,
+// which doesn't exist yet.
+func testStateFile(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "issue-sync-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "state.json")
+}
+
+func TestUpdateIssueFieldsHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		edit     func(*github.Issue)
+		want     int
+	}{
+		{"unchanged", nil, func(*github.Issue) {}, 1},
+		{"edited", nil, func(i *github.Issue) { i.Title = github.String("Edited") }, 2},
+		{"compare-all", map[string]interface{}{"compare-all": true}, func(*github.Issue) {}, 2},
+	}
+
+	for _, test := range tests {
+		settings := map[string]interface{}{"state": map[string]string{"file": testStateFile(t)}}
+		for k, v := range test.settings {
+			settings[k] = v
+		}
+		config := newTestConfig(t, settings, nil)
+		gh := &fakeGHClient{}
+		j := &fakeJIRAClient{}
+
+		ghIssue := github.Issue{
+			ID:     github.Int(1),
+			Number: github.Int(1),
+			Title:  github.String("Title"),
+			Body:   github.String("Body"),
+			User:   &github.User{Login: github.String("alice")},
+		}
+		// The JIRA issue never matches, so that every comparison updates it.
+		jIssue := jira.Issue{Key: "SYNC-1", Fields: &jira.IssueFields{Unknowns: tcontainer.MarshalMap{}}}
+		config.SetMirroredIssue(1, "SYNC-1", "")
+
+		if _, err := updateIssueFields(config, ghIssue, jIssue, "open", gh, j); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		test.edit(&ghIssue)
+		if _, err := updateIssueFields(config, ghIssue, jIssue, "open", gh, j); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if got := j.count("UpdateIssue"); got != test.want {
+			t.Errorf("%s: updated %d times; want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMappingFingerprint(t *testing.T) {
+	labels := map[string]interface{}{
+		"labels": map[string]interface{}{"rules": []map[string]string{{"label": "bug", "jira-label": "defect"}}},
+	}
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		want     bool
+	}{
+		{"same configuration", nil, true},
+		{"unrelated parameter", map[string]interface{}{"timeout": "5m"}, true},
+		{"label rules", labels, false},
+		{"milestone sync", map[string]interface{}{"milestones": map[string]bool{"sync": true}}, false},
+		{"mentions", map[string]interface{}{"users": map[string]bool{"mentions": true}}, false},
+	}
+
+	base := newTestConfig(t, nil, nil).GetMappingFingerprint()
+	for _, test := range tests {
+		got := newTestConfig(t, test.settings, nil).GetMappingFingerprint()
+		if (got == base) != test.want {
+			t.Errorf("%s: fingerprint unchanged is %t; want %t", test.name, got == base, test.want)
+		}
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	file := testStateFile(t)
+	state := map[string]interface{}{"state": map[string]string{"file": file}}
+
+	config := newTestConfig(t, state, nil)
+	if !config.IsStateRebuildDue() {
+		t.Errorf("missing state file: rebuild not due")
+	}
+	config.SetMirroredIssue(1, "SYNC-1", "hash")
+	if err := config.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		content  string
+		wantKey  string
+		rebuild  bool
+	}{
+		{"saved", nil, "", "SYNC-1", false},
+		{"rebuild-state", map[string]interface{}{"rebuild-state": true}, "", "", true},
+		{"invalid", nil, "{", "", true},
+		{"other version", nil, `{"version": 0, "issues": {}}`, "", true},
+	}
+
+	for _, test := range tests {
+		settings := map[string]interface{}{}
+		for k, v := range state {
+			settings[k] = v
+		}
+		for k, v := range test.settings {
+			settings[k] = v
+		}
+		if test.content != "" {
+			if err := ioutil.WriteFile(file, []byte(test.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		config := newTestConfig(t, settings, nil)
+		if key, _, _ := config.GetMirroredIssue(1); key != test.wantKey {
+			t.Errorf("%s: issue 1 mirrored by %q; want %q", test.name, key, test.wantKey)
+		}
+		if got := config.IsStateRebuildDue(); got != test.rebuild {
+			t.Errorf("%s: rebuild due is %t; want %t", test.name, got, test.rebuild)
+		}
+	}
+}
+
+func TestRebuildState(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{"state": map[string]string{"file": testStateFile(t)}}, nil)
+	config.SetMirroredIssue(1, "SYNC-1", "hash")
+	config.SetMirroredIssue(3, "SYNC-3", "hash")
+
+	id := config.GetFieldKey(cfg.GitHubID)
+	j := &fakeJIRAClient{issues: []jira.Issue{
+		{Key: "SYNC-1", Fields: &jira.IssueFields{Unknowns: tcontainer.MarshalMap{id: float64(1)}}},
+		{Key: "SYNC-2", Fields: &jira.IssueFields{Unknowns: tcontainer.MarshalMap{id: float64(2)}}},
+		{Key: "SYNC-9", Fields: &jira.IssueFields{Unknowns: tcontainer.MarshalMap{}}},
+	}}
+
+	if err := RebuildState(config, j); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id      int
+		wantKey string
+		wantOK  bool
+	}{
+		{1, "SYNC-1", true},
+		{2, "SYNC-2", true},
+		{3, "", false},
+	}
+	for _, test := range tests {
+		key, hash, ok := config.GetMirroredIssue(test.id)
+		if key != test.wantKey || ok != test.wantOK || hash != "" {
+			t.Errorf("issue %d: got (%q, %q, %t); want (%q, \"\", %t)", test.id, key, hash, ok, test.wantKey, test.wantOK)
+		}
+	}
+}
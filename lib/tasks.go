@@ -0,0 +1,259 @@
+package lib
+
+import (
+	"bufio"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// maxSummaryLength is the maximum length of the summary of a JIRA issue.
+const maxSummaryLength = 255
+
+// taskItemRegex matches an item of a GitHub task list. It has matching groups
+// for the checkbox (\1) and the text of the item (\2).
+var taskItemRegex = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.*\S)\s*$`)
+
+// subIssueRegex matches the text of a task list item which only references an
+// issue, by number or URL. It has matching groups for the repository (\1 or
+// \2, if one is given) and the issue number (\3).
+var subIssueRegex = regexp.MustCompile(`^(?:https://github\.com/([\w.-]+/[\w.-]+)/issues/|([\w.-]+/[\w.-]+)?#)(\d+)$`)
+
+// taskItem is an item of a GitHub task list.
+type taskItem struct {
+	// Text is the text of the item, as the summary of its sub-task.
+	Text string
+	// Checked is whether the item is checked.
+	Checked bool
+	// Number is the number of the issue the item references, or 0 if it is
+	// a plain task.
+	Number int
+}
+
+// taskItems returns the items of the task lists in the body of a GitHub issue
+// of the repository owner/repo. Items referencing issues of other repositories,
+// and lines in fenced code blocks, are skipped.
+func taskItems(body, owner, repo string) []taskItem {
+	var items []taskItem
+
+	fenced := false
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+
+		m := taskItemRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		item := taskItem{
+			Text:    m[2],
+			Checked: m[1] != " ",
+		}
+
+		if ref := subIssueRegex.FindStringSubmatch(item.Text); ref != nil {
+			r := ref[1] + ref[2]
+			if r != "" && !strings.EqualFold(r, owner+"/"+repo) {
+				continue
+			}
+			item.Number, _ = strconv.Atoi(ref[3])
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// SyncTaskList mirrors the task lists of a GitHub issue to its JIRA issue. A
+// sub-task is created for each plain item which doesn't have one, matched by
+// summary; the sub-tasks of checked items are moved to the configured status,
+// and those of unchecked items moved back if configured to. Items referencing
+// other issues of the repository link their JIRA issues to the JIRA issue as
+// its children, once they have been mirrored. Sub-tasks whose item was removed
+// are left as they are.
+func SyncTaskList(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	owner, repo := config.GetRepo()
+	items := taskItems(ghIssue.GetBody(), owner, repo)
+	if len(items) == 0 {
+		return nil
+	}
+
+	subtasks := map[string]jira.Issue{}
+	for _, s := range jIssue.Fields.Subtasks {
+		fields := s.Fields
+		subtasks[s.Fields.Summary] = jira.Issue{ID: s.ID, Key: s.Key, Self: s.Self, Fields: &fields}
+	}
+
+	var children []int
+	for _, item := range items {
+		if item.Number != 0 {
+			if item.Number != ghIssue.GetNumber() {
+				children = append(children, item.Number)
+			}
+			continue
+		}
+
+		summary := item.Text
+		if r := []rune(summary); len(r) > maxSummaryLength {
+			summary = string(r[:maxSummaryLength])
+		}
+		subtask, ok := subtasks[summary]
+		if !ok {
+			var err error
+			subtask, err = createSubtask(config, jIssue, summary, jClient)
+			if err != nil {
+				return err
+			}
+			subtasks[summary] = subtask
+			if !item.Checked {
+				continue
+			}
+			if subtask, err = jClient.GetIssue(subtask.Key); err != nil {
+				return err
+			}
+		}
+
+		if err := transitionSubtask(config, subtask, item.Checked, jClient); err != nil {
+			return err
+		}
+	}
+
+	if len(children) > 0 {
+		if err := linkChildren(config, ghIssue, jIssue, children, ghClient, jClient); err != nil {
+			return err
+		}
+	}
+
+	log.Debugf("Mirrored the task list of GitHub issue #%d to JIRA issue %s", ghIssue.GetNumber(), jIssue.Key)
+	return nil
+}
+
+// createSubtask creates a sub-task of a JIRA issue with the given summary.
+func createSubtask(config cfg.Config, jIssue jira.Issue, summary string, jClient clients.JIRAClient) (jira.Issue, error) {
+	log := config.GetLogger()
+
+	subtask, err := jClient.CreateIssue(jira.Issue{
+		Fields: &jira.IssueFields{
+			Type:    jira.IssueType{Name: config.GetTaskIssueType()},
+			Project: config.GetProject(),
+			Summary: summary,
+			Parent:  &jira.Parent{Key: jIssue.Key},
+		},
+	})
+	if err != nil {
+		return jira.Issue{}, err
+	}
+
+	log.Debugf("Created sub-task %s of JIRA issue %s", subtask.Key, jIssue.Key)
+	return subtask, nil
+}
+
+// transitionSubtask moves the sub-task of a task list item to the configured
+// status if the item is checked. If it isn't, and the sub-task is in that
+// status, it is moved back to the configured reopen status, if any.
+func transitionSubtask(config cfg.Config, subtask jira.Issue, checked bool, jClient clients.JIRAClient) error {
+	done := config.GetTaskDoneRule()
+	if checked {
+		return moveIssue(config, subtask, done, jClient)
+	}
+
+	rule, ok := config.GetTaskReopenRule()
+	if !ok || subtask.Fields.Status == nil || !strings.EqualFold(subtask.Fields.Status.Name, done.Status) {
+		return nil
+	}
+	return moveIssue(config, subtask, rule, jClient)
+}
+
+// linkChildren links the JIRA issues mirroring the GitHub issues with the
+// given numbers to the JIRA issue of ghIssue, as its children, unless they
+// are already linked. Issues which haven't been mirrored are skipped.
+func linkChildren(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, numbers []int, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	owner, repo := config.GetRepo()
+	linkType := config.GetTaskLinkType()
+
+	var ids []int
+	for _, number := range numbers {
+		child, err := ghClient.GetIssue(owner, repo, number)
+		if err == clients.ErrIssueDeleted || err == clients.ErrIssueNotFound {
+			log.Debugf("GitHub issue #%d in the task list of #%d can't be found", number, ghIssue.GetNumber())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		ids = append(ids, child.GetID())
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	jChildren, err := jClient.ListIssues(config.GetProjectKey(), ids)
+	if err != nil {
+		return err
+	}
+
+	for _, jChild := range jChildren {
+		created, err := createLink(config, jIssue, linkType, jChild, jIssue, jClient)
+		if err != nil {
+			return err
+		}
+		if created {
+			log.Debugf("Linked JIRA issue %s to %s as its child", jChild.Key, jIssue.Key)
+		}
+	}
+
+	return nil
+}
+
+// isLinked returns whether a JIRA issue has an issue link of the given type,
+// in either direction, to the issue with the given key.
+func isLinked(jIssue jira.Issue, key, linkType string) bool {
+	for _, link := range jIssue.Fields.IssueLinks {
+		if !strings.EqualFold(link.Type.Name, linkType) {
+			continue
+		}
+		if link.InwardIssue != nil && link.InwardIssue.Key == key {
+			return true
+		}
+		if link.OutwardIssue != nil && link.OutwardIssue.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// createLink links two JIRA issues, from and to, with the given link type,
+// unless jIssue, which is one of them, already has such a link to the other,
+// or it was created during this run, e.g. by another worker synchronizing the
+// other issue. It returns whether it created the link.
+func createLink(config cfg.Config, jIssue jira.Issue, linkType string, from, to jira.Issue, jClient clients.JIRAClient) (bool, error) {
+	other := to.Key
+	if other == jIssue.Key {
+		other = from.Key
+	}
+	if isLinked(jIssue, other, linkType) || !config.ClaimIssueLink(linkType, from.Key, to.Key) {
+		return false, nil
+	}
+
+	if err := jClient.LinkIssues(linkType, from, to); err != nil {
+		config.ReleaseIssueLink(linkType, from.Key, to.Key)
+		return false, err
+	}
+	return true, nil
+}
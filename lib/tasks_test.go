@@ -0,0 +1,107 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+)
+
+func TestTaskItems(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []taskItem
+	}{
+		{"no task list", "Steps:\n\n- Write the parser", nil},
+		{"unchecked", "- [ ] Write the parser", []taskItem{{Text: "Write the parser"}}},
+		{"checked", "- [x] Test it", []taskItem{{Text: "Test it", Checked: true}}},
+		{"checked uppercase", "- [X] Test it", []taskItem{{Text: "Test it", Checked: true}}},
+		{"nested", "- [ ] Write\n  * [x] Test it ", []taskItem{{Text: "Write"}, {Text: "Test it", Checked: true}}},
+		{"plus bullet", "+ [ ] Write", []taskItem{{Text: "Write"}}},
+		{"no space in checkbox", "- [] not a task", nil},
+		{"code block", "```\n- [ ] in a code block\n```\n- [ ] Write", []taskItem{{Text: "Write"}}},
+		{"number", "- [X] #12", []taskItem{{Text: "#12", Checked: true, Number: 12}}},
+		{"same repository", "- [ ] coreos/issue-sync#3", []taskItem{{Text: "coreos/issue-sync#3", Number: 3}}},
+		{"URL", "- [ ] https://github.com/coreos/issue-sync/issues/4", []taskItem{{Text: "https://github.com/coreos/issue-sync/issues/4", Number: 4}}},
+		{"other repository", "- [ ] other/repo#5", nil},
+		{"number and text", "- [ ] #6 and more", []taskItem{{Text: "#6 and more"}}},
+	}
+
+	for _, test := range tests {
+		if got := taskItems(test.body, "coreos", "issue-sync"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: taskItems(%q) = %v; want %v", test.name, test.body, got, test.want)
+		}
+	}
+}
+
+func TestSyncTaskList(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{
+		"task-lists": map[string]interface{}{
+			"sync":          true,
+			"status":        "Done",
+			"resolution":    "Done",
+			"via":           []string{"In Progress"},
+			"reopen-status": "To Do",
+		},
+	}, map[string]interface{}{
+		"/rest/api/2/issue/createmeta": jira.CreateMetaInfo{Projects: []*jira.MetaProject{{Key: "SYNC", IssueTypes: []*jira.MetaIssueType{
+			{Name: "Task"},
+			{Name: "Sub-task", Subtasks: true},
+		}}}},
+		"/rest/api/2/status":        testStatuses["/rest/api/2/status"],
+		"/rest/api/2/resolution":    testStatuses["/rest/api/2/resolution"],
+		"/rest/api/2/issueLinkType": testLinkTypes["/rest/api/2/issueLinkType"],
+	})
+
+	subtask := func(summary, status string) *jira.Subtasks {
+		return &jira.Subtasks{Key: "SYNC-2", Fields: jira.IssueFields{Summary: summary, Status: &jira.Status{Name: status}}}
+	}
+	long := strings.Repeat("a", maxSummaryLength+10)
+
+	tests := []struct {
+		name     string
+		body     string
+		subtasks []*jira.Subtasks
+		want     []string
+	}{
+		{"new unchecked", "- [ ] Write", nil, []string{"CreateIssue Write"}},
+		{"new checked", "- [x] Write", nil, []string{
+			"CreateIssue Write",
+			"DoTransition SYNC-101 In Progress",
+			"DoTransition SYNC-101 Done (Done)",
+		}},
+		{"unchanged", "- [ ] Write", []*jira.Subtasks{subtask("Write", "To Do")}, nil},
+		{"checked", "- [x] Write", []*jira.Subtasks{subtask("Write", "To Do")}, []string{
+			"DoTransition SYNC-2 In Progress",
+			"DoTransition SYNC-2 Done (Done)",
+		}},
+		{"still checked", "- [x] Write", []*jira.Subtasks{subtask("Write", "Done")}, nil},
+		{"unchecked", "- [ ] Write", []*jira.Subtasks{subtask("Write", "Done")}, []string{"DoTransition SYNC-2 To Do"}},
+		{"unchecked in progress", "- [ ] Write", []*jira.Subtasks{subtask("Write", "In Progress")}, nil},
+		{"truncated summary", "- [ ] " + long, []*jira.Subtasks{subtask(long[:maxSummaryLength], "To Do")}, nil},
+		{"removed item", "- [ ] Test", []*jira.Subtasks{subtask("Write", "To Do")}, []string{"CreateIssue Test"}},
+		{"child", "- [ ] #12\n- [ ] #1", nil, []string{"LinkIssues Blocks SYNC-12 SYNC-1"}},
+	}
+
+	for _, test := range tests {
+		config.ResetIssueLinks()
+		ghIssue := github.Issue{Number: github.Int(1), Body: github.String(test.body)}
+		jIssue := testJIRAIssue(config, "SYNC-1", 101)
+		jIssue.Fields.Subtasks = test.subtasks
+		gh := &fakeGHClient{issues: []github.Issue{{ID: github.Int(112), Number: github.Int(12)}}}
+		j := &fakeJIRAClient{
+			issues:   []jira.Issue{testJIRAIssue(config, "SYNC-12", 112)},
+			workflow: testWorkflow,
+		}
+
+		if err := SyncTaskList(config, ghIssue, jIssue, gh, j); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(j.calls, test.want) {
+			t.Errorf("%s: got JIRA calls %q; want %q", test.name, j.calls, test.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// maxTransitionSteps is the maximum number of transitions TransitionIssue
+// will perform to reach a status, to avoid looping through a workflow forever.
+const maxTransitionSteps = 10
+
+// TransitionIssue moves a JIRA issue through its workflow to the status the
+// configured transition rules map the state of the GitHub issue to. If no
+// transition leads directly to that status, it goes through the statuses or
+// transitions listed in the rule's `via`, in order, as they become available.
+// If the status can't be reached, a warning is logged and no error is returned.
+func TransitionIssue(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	rules := config.GetTransitionRules(ghIssue.GetState())
+	if len(rules) == 0 {
+		return nil
+	}
+
+	// Only look up the state reason if it can make a difference. The reason
+	// of a pull request is its review or merge state.
+	reason := ""
+	for _, rule := range rules {
+		if rule.Reason == "" {
+			continue
+		}
+		var err error
+		if isPullRequest(ghIssue) {
+			reason, err = pullRequestState(config, ghIssue, ghClient)
+			if reason == pullOpen || reason == pullClosed {
+				reason = ""
+			}
+		} else {
+			owner, repo := config.GetRepo()
+			reason, err = ghClient.GetStateReason(owner, repo, ghIssue.GetNumber())
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+
+	rule, ok := config.GetTransitionRule(ghIssue.GetState(), reason)
+	if !ok {
+		log.Debugf("No transition rule for GitHub state %s (%s) of #%d", ghIssue.GetState(), reason, ghIssue.GetNumber())
+		return nil
+	}
+
+	return moveIssue(config, jIssue, rule, jClient)
+}
+
+// doneStatusCategory is the key of the category of JIRA statuses in which
+// issues are done.
+const doneStatusCategory = "done"
+
+// statusAgrees returns whether the status of a JIRA issue agrees with the state
+// of the GitHub issue it mirrors: it is in the done category if, and only if,
+// the GitHub issue is closed. Statuses within a category aren't compared, so
+// that JIRA issues can be moved through their workflow in JIRA. If the status
+// of the JIRA issue is unknown, it is assumed to agree.
+func statusAgrees(ghIssue github.Issue, jIssue jira.Issue) bool {
+	if jIssue.Fields.Status == nil {
+		return true
+	}
+	done := jIssue.Fields.Status.StatusCategory.Key == doneStatusCategory
+	return done == (ghIssue.GetState() == "closed")
+}
+
+// moveIssue moves a JIRA issue through its workflow to the status of a
+// transition rule, going through the statuses or transitions listed in the
+// rule's `via` when no transition leads directly to it. If the status can't be
+// reached, a warning is logged and no error is returned.
+func moveIssue(config cfg.Config, jIssue jira.Issue, rule cfg.TransitionRule, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	status := ""
+	if jIssue.Fields.Status != nil {
+		status = jIssue.Fields.Status.Name
+	}
+
+	used := map[string]bool{}
+	for step := 0; step < maxTransitionSteps; step++ {
+		if strings.EqualFold(status, rule.Status) {
+			if step > 0 {
+				log.Debugf("Transitioned JIRA issue %s to %s", jIssue.Key, status)
+			}
+			return nil
+		}
+
+		transitions, err := jClient.GetTransitions(jIssue)
+		if err != nil {
+			return err
+		}
+
+		next, ok := findTransition(transitions, []string{rule.Status}, used)
+		if !ok {
+			next, ok = findTransition(transitions, rule.Via, used)
+		}
+		if !ok {
+			names := make([]string, len(transitions))
+			for i, t := range transitions {
+				names[i] = t.Name
+			}
+			log.Warnf("Cannot transition JIRA issue %s to %s for GitHub state %s: no transition available from status %s (available: %s)",
+				jIssue.Key, rule.Status, rule.State, status, strings.Join(names, ", "))
+			return nil
+		}
+
+		resolution := ""
+		if _, ok := next.Fields["resolution"]; ok {
+			resolution = rule.Resolution
+		}
+
+		log.Debugf("Performing transition %q on JIRA issue %s (%s -> %s)", next.Name, jIssue.Key, status, next.To.Name)
+		if err := jClient.DoTransition(jIssue, next, resolution); err != nil {
+			return err
+		}
+
+		used[next.ID] = true
+		status = next.To.Name
+	}
+
+	log.Warnf("Gave up transitioning JIRA issue %s to %s after %d transitions", jIssue.Key, rule.Status, maxTransitionSteps)
+	return nil
+}
+
+// findTransition returns the first transition which hasn't been used yet and
+// whose name, or the name of the status it leads to, is one of names.
+func findTransition(transitions []clients.Transition, names []string, used map[string]bool) (clients.Transition, bool) {
+	for _, name := range names {
+		for _, t := range transitions {
+			if used[t.ID] {
+				continue
+			}
+			if strings.EqualFold(t.To.Name, name) || strings.EqualFold(t.Name, name) {
+				return t, true
+			}
+		}
+	}
+	return clients.Transition{}, false
+}
@@ -0,0 +1,178 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+func TestStatusAgrees(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    string
+		category string
+		want     bool
+	}{
+		{"open and to do", "open", "new", true},
+		{"open and in progress", "open", "indeterminate", true},
+		{"open and done", "open", "done", false},
+		{"closed and done", "closed", "done", true},
+		{"closed and to do", "closed", "new", false},
+		{"closed and in progress", "closed", "indeterminate", false},
+		{"unknown status", "closed", "", true},
+	}
+
+	for _, test := range tests {
+		ghIssue := github.Issue{State: github.String(test.state)}
+		jIssue := jira.Issue{Fields: &jira.IssueFields{}}
+		if test.category != "" {
+			jIssue.Fields.Status = &jira.Status{StatusCategory: jira.StatusCategory{Key: test.category}}
+		}
+		if got := statusAgrees(ghIssue, jIssue); got != test.want {
+			t.Errorf("%s: got %t; want %t", test.name, got, test.want)
+		}
+	}
+}
+
+// testStatuses are the responses of the fake JIRA server listing the statuses
+// and resolutions used in transition rules.
+var testStatuses = map[string]interface{}{
+	"/rest/api/2/status":     []jira.Status{{Name: "To Do"}, {Name: "In Progress"}, {Name: "Done"}, {Name: "Closed"}},
+	"/rest/api/2/resolution": []jira.Resolution{{Name: "Done"}, {Name: "Won't Do"}},
+}
+
+// testWorkflow is the workflow of the fake JIRA client: To Do leads to In
+// Progress, which leads to Done or back to To Do, and Done and Closed lead
+// back to To Do.
+var testWorkflow = map[string][]string{
+	"To Do":       {"In Progress"},
+	"In Progress": {"To Do", "Done", "Closed"},
+	"Done":        {"To Do"},
+	"Closed":      {"To Do"},
+}
+
+func TestMoveIssue(t *testing.T) {
+	config := newTestConfig(t, nil, nil)
+
+	tests := []struct {
+		name   string
+		status string
+		rule   cfg.TransitionRule
+		want   []string
+	}{
+		{"already there", "Done", cfg.TransitionRule{Status: "Done"}, nil},
+		{"direct", "In Progress", cfg.TransitionRule{Status: "Done", Resolution: "Done"}, []string{
+			"DoTransition SYNC-1 Done (Done)",
+		}},
+		{"case insensitive", "In Progress", cfg.TransitionRule{Status: "done"}, []string{
+			"DoTransition SYNC-1 Done",
+		}},
+		{"via", "To Do", cfg.TransitionRule{Status: "Done", Resolution: "Done", Via: []string{"In Progress"}}, []string{
+			"DoTransition SYNC-1 In Progress",
+			"DoTransition SYNC-1 Done (Done)",
+		}},
+		{"resolution not on screen", "In Progress", cfg.TransitionRule{Status: "Closed", Resolution: "Done"}, []string{
+			"DoTransition SYNC-1 Closed",
+		}},
+		{"unreachable", "To Do", cfg.TransitionRule{Status: "Done"}, nil},
+		{"no transition reused", "To Do", cfg.TransitionRule{Status: "Review", Via: []string{"In Progress", "To Do"}}, []string{
+			"DoTransition SYNC-1 In Progress",
+			"DoTransition SYNC-1 To Do",
+		}},
+	}
+
+	for _, test := range tests {
+		jIssue := jira.Issue{Key: "SYNC-1", Fields: &jira.IssueFields{Status: &jira.Status{Name: test.status}}}
+		j := &fakeJIRAClient{workflow: testWorkflow}
+
+		if err := moveIssue(config, jIssue, test.rule, j); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(j.calls, test.want) {
+			t.Errorf("%s: got JIRA calls %q; want %q", test.name, j.calls, test.want)
+		}
+	}
+}
+
+func TestTransitionIssue(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{
+		"transitions": []map[string]interface{}{
+			{"state": "closed", "status": "Done", "resolution": "Done", "via": []string{"In Progress"}},
+			{"state": "closed", "reason": "not_planned", "status": "Closed", "resolution": "Won't Do", "via": []string{"In Progress"}},
+			{"state": "closed", "status": "Closed"},
+			{"state": "open", "status": "To Do"},
+		},
+	}, testStatuses)
+
+	tests := []struct {
+		name   string
+		state  string
+		reason string
+		status string
+		want   []string
+	}{
+		{"completed", "closed", "completed", "To Do", []string{
+			"DoTransition SYNC-1 In Progress",
+			"DoTransition SYNC-1 Done (Done)",
+		}},
+		{"exact reason", "closed", "not_planned", "To Do", []string{
+			"DoTransition SYNC-1 In Progress",
+			"DoTransition SYNC-1 Closed",
+		}},
+		{"no reason", "closed", "", "To Do", []string{
+			"DoTransition SYNC-1 In Progress",
+			"DoTransition SYNC-1 Done (Done)",
+		}},
+		{"reopened", "open", "reopened", "Done", []string{"DoTransition SYNC-1 To Do"}},
+		{"already there", "open", "", "To Do", nil},
+	}
+
+	for _, test := range tests {
+		ghIssue := github.Issue{Number: github.Int(1), State: github.String(test.state)}
+		jIssue := jira.Issue{Key: "SYNC-1", Fields: &jira.IssueFields{Status: &jira.Status{Name: test.status}}}
+		gh := &fakeGHClient{reasons: map[int]string{1: test.reason}}
+		j := &fakeJIRAClient{workflow: testWorkflow}
+
+		if err := TransitionIssue(config, ghIssue, jIssue, gh, j); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(j.calls, test.want) {
+			t.Errorf("%s: got JIRA calls %q; want %q", test.name, j.calls, test.want)
+		}
+		// The reason is only looked up if a rule for the state has one.
+		if want := map[string]int{"closed": 1, "open": 0}[test.state]; gh.count("GetStateReason") != want {
+			t.Errorf("%s: looked up the reason %d times; want %d", test.name, gh.count("GetStateReason"), want)
+		}
+	}
+}
+
+func TestGetTransitionRule(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{
+		"transitions": []map[string]interface{}{
+			{"state": "closed", "status": "Done"},
+			{"state": "closed", "reason": "not_planned", "status": "Closed"},
+			{"state": "closed", "status": "In Progress"},
+		},
+	}, testStatuses)
+
+	tests := []struct {
+		state  string
+		reason string
+		want   string
+	}{
+		{"closed", "not_planned", "Closed"},
+		{"closed", "completed", "Done"},
+		{"closed", "", "Done"},
+		{"open", "", ""},
+	}
+
+	for _, test := range tests {
+		rule, ok := config.GetTransitionRule(test.state, test.reason)
+		if ok != (test.want != "") || rule.Status != test.want {
+			t.Errorf("%s (%s): got rule for %q, %t; want %q", test.state, test.reason, rule.Status, ok, test.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// jiraUser returns the name of the JIRA user a GitHub login maps to, or the
+// empty string if it can't be mapped. The mapping file is checked first; then,
+// if email lookup is enabled, JIRA is searched for a user with the public email
+// of the GitHub user, and the result is cached.
+func jiraUser(config cfg.Config, login string, ghClient clients.GitHubClient, jClient clients.JIRAClient) (string, error) {
+	log := config.GetLogger()
+
+	if name, ok := config.GetMappedUser(login); ok {
+		return name, nil
+	}
+
+	if !config.IsEmailLookupEnabled() {
+		return "", nil
+	}
+
+	if name, ok := config.GetCachedUser(login); ok {
+		return name, nil
+	}
+
+	user, err := ghClient.GetUser(login)
+	if err == clients.ErrUserNotFound {
+		log.Debugf("There is no GitHub user %s", login)
+		config.SetCachedUser(login, "")
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	name := ""
+	if email := user.GetEmail(); email != "" {
+		users, err := jClient.SearchUsers(email)
+		if err != nil {
+			return "", err
+		}
+		for _, u := range users {
+			if strings.EqualFold(u.EmailAddress, email) {
+				name = u.Name
+				break
+			}
+		}
+	}
+
+	if name == "" {
+		log.Debugf("Could not find a JIRA user for GitHub user %s", login)
+	} else {
+		log.Debugf("Found JIRA user %s for GitHub user %s", name, login)
+	}
+
+	config.SetCachedUser(login, name)
+
+	return name, nil
+}
+
+// issueReporter returns the name of the JIRA user who should be the reporter
+// of the JIRA issue mirroring a GitHub issue: the user the author of the GitHub
+// issue maps to, or the default reporter. If it is empty, the reporter should
+// be left as it is.
+func issueReporter(config cfg.Config, ghIssue github.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) (string, error) {
+	name, err := jiraUser(config, ghIssue.User.GetLogin(), ghClient, jClient)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return config.GetDefaultReporter(), nil
+	}
+	return name, nil
+}
+
+// issueAssignee returns the name of the JIRA user who should be assigned the
+// JIRA issue mirroring a GitHub issue: the user the first mappable assignee of
+// the GitHub issue maps to, or the default assignee if none of them can be
+// mapped. If none of them can be mapped and there is no default assignee, it
+// returns the empty string, and the assignee should be left as it is. If the
+// GitHub issue has no assignees, it returns the empty string too, but the
+// JIRA issue should be unassigned (see setAssignee).
+func issueAssignee(config cfg.Config, ghIssue github.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) (string, error) {
+	if len(ghIssue.Assignees) == 0 {
+		return "", nil
+	}
+
+	for _, assignee := range ghIssue.Assignees {
+		name, err := jiraUser(config, assignee.GetLogin(), ghClient, jClient)
+		if err != nil {
+			return "", err
+		}
+		if name != "" {
+			return name, nil
+		}
+	}
+
+	return config.GetDefaultAssignee(), nil
+}
+
+// setAssignee sets the assignee in the fields of the JIRA issue mirroring a
+// GitHub issue to the JIRA user with the given name, or to no one if the GitHub
+// issue has no assignees. Otherwise, the assignee is left as it is.
+func setAssignee(fields *jira.IssueFields, ghIssue github.Issue, name string) {
+	switch {
+	case name != "":
+		fields.Assignee = &jira.User{Name: name}
+	case len(ghIssue.Assignees) == 0:
+		// A nil Assignee is omitted, which leaves the assignee as it is, or
+		// the default assignee of the project for a new issue; it has to be
+		// sent as null instead.
+		fields.Unknowns["assignee"] = nil
+	}
+}
+
+// isJIRAUser returns whether the JIRA user is set and has the given name.
+func isJIRAUser(user *jira.User, name string) bool {
+	return user != nil && strings.EqualFold(user.Name, name)
+}
+
+// mentionFuncFor returns the function converting the @mentions in body to
+// JIRA wiki markup: a JIRA mention of the user the GitHub user maps to, or a
+// link to the GitHub profile of users which can't be mapped. The users are
+// looked up beforehand, so that errors can be returned; mentions in code
+// aren't converted, so they aren't looked up.
+func mentionFuncFor(config cfg.Config, body string, ghClient clients.GitHubClient, jClient clients.JIRAClient) (func(string) string, error) {
+	users := map[string]string{}
+	for _, line := range proseLines(body) {
+		for _, m := range mdMentionRegex.FindAllStringSubmatch(line, -1) {
+			login := strings.ToLower(m[2])
+			if _, ok := users[login]; ok || m[3] != "" {
+				continue
+			}
+			name, err := jiraUser(config, m[2], ghClient, jClient)
+			if err != nil {
+				return nil, err
+			}
+			users[login] = name
+		}
+	}
+
+	return func(login string) string {
+		if name := users[strings.ToLower(login)]; name != "" {
+			return fmt.Sprintf("[~%s]", name)
+		}
+		return fmt.Sprintf("[@%s|https://github.com/%s]", login, login)
+	}, nil
+}
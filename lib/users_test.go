@@ -0,0 +1,137 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+func TestMentionFuncFor(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{
+		"users": map[string]interface{}{"email-lookup": true, "mentions": true},
+	}, nil)
+	ghClient := &fakeGHClient{users: map[string]github.User{
+		"alice": {Login: github.String("alice"), Email: github.String("alice@example.com")},
+	}}
+	jClient := &fakeJIRAClient{users: []jira.User{{Name: "asmith", EmailAddress: "alice@example.com"}}}
+
+	body := "@alice, @ghost and `@latest`\n\n```java\n@Override\n```\n@ghost again"
+	mention, err := mentionFuncFor(config, body, ghClient, jClient)
+	if err != nil {
+		t.Fatalf("mentionFuncFor(%q) failed: %v", body, err)
+	}
+
+	tests := []struct {
+		login string
+		want  string
+	}{
+		{"alice", "[~asmith]"},
+		{"Alice", "[~asmith]"},
+		{"ghost", "[@ghost|https://github.com/ghost]"},
+	}
+	for _, test := range tests {
+		if got := mention(test.login); got != test.want {
+			t.Errorf("mention(%q) = %q; want %q", test.login, got, test.want)
+		}
+	}
+
+	for _, login := range []string{"latest", "Override"} {
+		if n := ghClient.count("GetUser " + login); n != 0 {
+			t.Errorf("GitHub user %s in code was looked up %d times", login, n)
+		}
+	}
+
+	// The missing user is remembered.
+	if _, err := mentionFuncFor(config, "@ghost", ghClient, jClient); err != nil {
+		t.Fatal(err)
+	}
+	if n := ghClient.count("GetUser ghost"); n != 1 {
+		t.Errorf("Missing GitHub user was looked up %d times; want 1", n)
+	}
+}
+
+func TestSetAssignee(t *testing.T) {
+	alice := &github.User{Login: github.String("alice")}
+
+	tests := []struct {
+		name      string
+		assignees []*github.User
+		assignee  string
+		want      string
+	}{
+		{"mapped", []*github.User{alice}, "jdoe", "jdoe"},
+		{"not mapped", []*github.User{alice}, "", "omitted"},
+		{"no assignees", nil, "", "null"},
+	}
+
+	for _, test := range tests {
+		fields := jira.IssueFields{Unknowns: map[string]interface{}{}}
+		setAssignee(&fields, github.Issue{Assignees: test.assignees}, test.assignee)
+
+		b, err := json.Marshal(&fields)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		got := "omitted"
+		if raw, ok := m["assignee"]; ok {
+			var user *jira.User
+			if err := json.Unmarshal(raw, &user); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			got = "null"
+			if user != nil {
+				got = user.Name
+			}
+		}
+		if got != test.want {
+			t.Errorf("%s: got assignee %q; want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUserCacheNull(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Cache files holding null must load as empty caches.
+	cacheFile := filepath.Join(dir, "users.json")
+	profileFile := filepath.Join(dir, "profiles.json")
+	for _, file := range []string{cacheFile, profileFile} {
+		if err := ioutil.WriteFile(file, []byte("null"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := newTestConfig(t, map[string]interface{}{
+		"users": map[string]interface{}{
+			"cache-file":         cacheFile,
+			"profile-cache-file": profileFile,
+		},
+	}, nil)
+
+	if _, ok := config.GetCachedUser("alice"); ok {
+		t.Errorf("alice is cached before being looked up")
+	}
+	config.SetCachedUser("alice", "jdoe")
+	if user, ok := config.GetCachedUser("alice"); !ok || user != "jdoe" {
+		t.Errorf("got cached user %q, %t; want jdoe", user, ok)
+	}
+
+	config.SetCachedProfile(cfg.GitHubProfile{Login: "alice"})
+	if _, ok := config.GetCachedProfile("alice"); !ok {
+		t.Errorf("profile of alice is not cached")
+	}
+}
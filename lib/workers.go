@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"bytes"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// forEachIssue calls f with each of the GitHub issues, on the configured
+// number of workers in parallel, and returns the error returned by each call,
+// in the order of the issues. With more than one worker, each call is given a
+// copy of the configuration whose log is buffered; the logs are written out in
+// the order of the issues as the calls return, so that the log of each issue
+// is contiguous and the issues are logged in the same order as with a single
+// worker.
+func forEachIssue(config cfg.Config, ghIssues []github.Issue, f func(cfg.Config, github.Issue) error) []error {
+	errs := make([]error, len(ghIssues))
+
+	workers := config.GetWorkers()
+	if workers > len(ghIssues) {
+		workers = len(ghIssues)
+	}
+	if workers <= 1 {
+		for i, ghIssue := range ghIssues {
+			errs[i] = f(config, ghIssue)
+		}
+		return errs
+	}
+
+	logs := make([]bytes.Buffer, len(ghIssues))
+	done := make([]chan struct{}, len(ghIssues))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	indexes := make(chan int)
+	go func() {
+		for i := range ghIssues {
+			indexes <- i
+		}
+		close(indexes)
+	}()
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range indexes {
+				errs[i] = f(config.WithLogOutput(&logs[i]), ghIssues[i])
+				close(done[i])
+			}
+		}()
+	}
+
+	for i := range ghIssues {
+		<-done[i]
+		config.WriteLog(logs[i].Bytes())
+		logs[i] = bytes.Buffer{}
+	}
+
+	return errs
+}
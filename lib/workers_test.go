@@ -0,0 +1,145 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+func TestForEachIssue(t *testing.T) {
+	tests := []struct {
+		workers int
+		issues  int
+	}{
+		{1, 5},
+		{3, 10},
+		{4, 4},
+		{8, 3},
+		{4, 0},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		config := newTestConfig(t, map[string]interface{}{
+			"log-level":   "info",
+			"concurrency": map[string]int{"workers": test.workers},
+		}, nil).WithLogOutput(&out)
+
+		var ghIssues []github.Issue
+		for i := 1; i <= test.issues; i++ {
+			ghIssues = append(ghIssues, github.Issue{Number: github.Int(i)})
+		}
+
+		var lock sync.Mutex
+		running, maxRunning := 0, 0
+		errs := forEachIssue(config, ghIssues, func(config cfg.Config, ghIssue github.Issue) error {
+			lock.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			lock.Unlock()
+
+			log := config.GetLogger()
+			number := ghIssue.GetNumber()
+			log.Infof("start #%d", number)
+			// Later issues finish first, to shuffle the order the calls return in.
+			time.Sleep(time.Duration(test.issues-number) * time.Millisecond)
+			log.Infof("end #%d", number)
+
+			lock.Lock()
+			running--
+			lock.Unlock()
+
+			if number%2 == 0 {
+				return fmt.Errorf("#%d failed", number)
+			}
+			return nil
+		})
+
+		var got, want []string
+		for i, err := range errs {
+			if err != nil {
+				got = append(got, err.Error())
+			} else {
+				got = append(got, "")
+			}
+			if (i+1)%2 == 0 {
+				want = append(want, fmt.Sprintf("#%d failed", i+1))
+			} else {
+				want = append(want, "")
+			}
+		}
+		if len(errs) != test.issues || !reflect.DeepEqual(got, want) {
+			t.Errorf("%d workers, %d issues: errors %q; want %q", test.workers, test.issues, got, want)
+		}
+
+		wantRunning := test.workers
+		if wantRunning > test.issues {
+			wantRunning = test.issues
+		}
+		if maxRunning > wantRunning {
+			t.Errorf("%d workers, %d issues: %d calls at once", test.workers, test.issues, maxRunning)
+		}
+
+		// The log of each issue is contiguous, in the order of the issues.
+		var messages []string
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if i := strings.Index(line, `msg="`); i >= 0 {
+				messages = append(messages, strings.SplitN(line[i+5:], `"`, 2)[0])
+			}
+		}
+		var wantMessages []string
+		for i := 1; i <= test.issues; i++ {
+			wantMessages = append(wantMessages, fmt.Sprintf("start #%d", i), fmt.Sprintf("end #%d", i))
+		}
+		if !reflect.DeepEqual(messages, wantMessages) {
+			t.Errorf("%d workers, %d issues: logged %q; want %q", test.workers, test.issues, messages, wantMessages)
+		}
+	}
+}
+
+func TestForEachIssueLinksOnce(t *testing.T) {
+	config := newTestConfig(t, map[string]interface{}{
+		"references":  map[string]bool{"sync": true},
+		"concurrency": map[string]int{"workers": 2},
+	}, testLinkTypes)
+	config.SetIssueID("coreos/issue-sync", 1, 100)
+	config.SetIssueID("coreos/issue-sync", 2, 200)
+
+	// Each issue references the other, and neither JIRA issue is linked yet.
+	ghIssues := []github.Issue{
+		{ID: github.Int(100), Number: github.Int(1), Body: github.String("See #2")},
+		{ID: github.Int(200), Number: github.Int(2), Body: github.String("See #1")},
+	}
+	jIssues := map[int]jira.Issue{
+		100: testJIRAIssue(config, "SYNC-1", 100),
+		200: testJIRAIssue(config, "SYNC-2", 200),
+	}
+
+	for run := 0; run < 50; run++ {
+		config.ResetIssueLinks()
+		j := &fakeJIRAClient{issues: []jira.Issue{jIssues[100], jIssues[200]}}
+
+		errs := forEachIssue(config, ghIssues, func(config cfg.Config, ghIssue github.Issue) error {
+			return LinkReferences(config, ghIssue, jIssues[ghIssue.GetID()], true, nil, &fakeGHClient{}, j)
+		})
+		for _, err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if len(j.calls) != 1 {
+			t.Fatalf("run %d: got %q; want a single link", run, j.calls)
+		}
+	}
+}